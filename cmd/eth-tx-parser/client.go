@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// exit codes of commands, so scripts can tell a missing subscription from a failure
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// requestTimeout bounds api requests of commands, backfills and exports of long histories take a while
+const requestTimeout = time.Minute * 5
+
+// apiClient calls the HTTP API of a running instance
+type apiClient struct {
+	server  string
+	apiKey  string
+	chainID int
+	http    *http.Client
+}
+
+// clientFlags registers flags of commands talking to a running instance
+func clientFlags(flags *flag.FlagSet) *apiClient {
+	c := &apiClient{http: &http.Client{Timeout: requestTimeout}}
+	flags.StringVar(&c.server, "server", "http://localhost:8080", "url of a running instance")
+	flags.StringVar(&c.apiKey, "api-key", os.Getenv("ETH_TX_PARSER_API_KEY"), "api key of the tenant, ETH_TX_PARSER_API_KEY by default")
+	flags.IntVar(&c.chainID, "chain", 0, "chain id, the default chain of the instance when 0")
+	return c
+}
+
+// apiError is a failed api request, message is taken from the error envelope when there is one
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// do sends a request to a chain route, responses other than 2xx are returned as *apiError.
+// The caller closes the body of the returned response
+func (c *apiClient) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	if c.chainID != 0 {
+		path = fmt.Sprintf("/chains/%d%s", c.chainID, path)
+	}
+	target := strings.TrimSuffix(c.server, "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request %s: %w", req.URL.Path, err)
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil || envelope.Error.Message == "" {
+		return nil, &apiError{status: resp.StatusCode, message: fmt.Sprintf("request %s failed with http status %d", req.URL.Path, resp.StatusCode)}
+	}
+	return nil, &apiError{status: resp.StatusCode, message: envelope.Error.Message}
+}
+
+// callJSON decodes the body of a successful request into v, which is left untouched when the response has no content
+func (c *apiClient) callJSON(ctx context.Context, method, path string, query url.Values, v any) error {
+	resp, err := c.do(ctx, method, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// copyTo writes the body of a successful request to out as it is streamed
+func (c *apiClient) copyTo(ctx context.Context, path string, query url.Values, out io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, path, query)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	return nil
+}
+
+// fail reports the error of a command and returns its exit code
+func fail(stderr io.Writer, command string, err error) int {
+	fmt.Fprintf(stderr, "%s: %v\n", command, err)
+
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		return exitError
+	}
+	switch apiErr.status {
+	case http.StatusNotFound:
+		return exitNotFound
+	default:
+		return exitError
+	}
+}
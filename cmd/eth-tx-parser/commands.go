@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+// command is a subcommand of the binary, run returns the exit code
+type command struct {
+	summary string
+	run     func(ctx context.Context, args []string, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"serve":       {summary: "index chains and serve the api, the default command", run: serve},
+	"subscribe":   {summary: "subscribe to transactions of an address", run: runSubscribe},
+	"unsubscribe": {summary: "unsubscribe from an address, indexed transactions are kept", run: runUnsubscribe},
+	"txs":         {summary: "list transactions of a subscribed address", run: runTxs},
+	"head":        {summary: "print the last processed block", run: runHead},
+	"backfill":    {summary: "index transactions of a subscribed address in past blocks", run: runBackfill},
+	"export":      {summary: "export transaction history of an address as CSV or NDJSON", run: runExport},
+	"report":      {summary: "report flows, fees and counterparties of an address over a period", run: runReport},
+	"scan":        {summary: "scan a range of blocks for transactions of addresses without a running instance", run: runScan},
+}
+
+// usage lists commands and exit codes
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: eth-tx-parser [command] [flags]")
+	fmt.Fprintln(w, "\nCommands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, name := range []string{"serve", "subscribe", "unsubscribe", "txs", "head", "backfill", "export", "report", "scan"} {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].summary)
+	}
+	_ = tw.Flush()
+	fmt.Fprintln(w, "\nRun eth-tx-parser <command> -h for flags of a command.")
+	fmt.Fprintf(w, "\nExit codes: %d ok, %d error, %d usage, %d not found, not subscribed or nothing scanned\n",
+		exitOK, exitError, exitUsage, exitNotFound)
+}
+
+// commandFlags creates flags of a command, errors are reported to stderr
+func commandFlags(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
+
+// parseFlags parses args and checks the address flag when one is registered. When parsing does not succeed
+// the returned exit code is exitOK for help requests and exitUsage for reported usage errors
+func parseFlags(flags *flag.FlagSet, args []string, address *string) (int, bool) {
+	if err := flags.Parse(args); errors.Is(err, flag.ErrHelp) {
+		return exitOK, false
+	} else if err != nil {
+		return exitUsage, false
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "%s: unexpected arguments %v\n", flags.Name(), flags.Args())
+		return exitUsage, false
+	}
+	if address != nil && *address == "" {
+		fmt.Fprintf(flags.Output(), "%s: -address is required\n", flags.Name())
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// formatFlag registers the output format flag of a command
+func formatFlag(flags *flag.FlagSet) *string {
+	return flags.String("format", "table", "output format, table or json")
+}
+
+// checkFormat reports a usage error unless format is one of formats
+func checkFormat(flags *flag.FlagSet, format string, formats ...string) bool {
+	for _, f := range formats {
+		if format == f {
+			return true
+		}
+	}
+	fmt.Fprintf(flags.Output(), "%s: invalid format %q, expected one of %v\n", flags.Name(), format, formats)
+	return false
+}
+
+// createOutput opens the file output is written to, stdout when path is empty
+func createOutput(path string, stdout io.Writer) (io.Writer, func() error, error) {
+	if path == "" {
+		return stdout, func() error { return nil }, nil
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, file.Close, nil
+}
+
+func runSubscribe(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	return changeSubscription(ctx, "subscribe", args, stderr)
+}
+
+func runUnsubscribe(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	return changeSubscription(ctx, "unsubscribe", args, stderr)
+}
+
+// changeSubscription subscribes or unsubscribes the tenant, nothing is printed on success
+func changeSubscription(ctx context.Context, action string, args []string, stderr io.Writer) int {
+	flags := commandFlags(action, stderr)
+	client := clientFlags(flags)
+	address := flags.String("address", "", "address or ENS name")
+	if code, ok := parseFlags(flags, args, address); !ok {
+		return code
+	}
+
+	resp, err := client.do(ctx, http.MethodPost, "/address/"+url.PathEscape(*address)+"/"+action, nil)
+	if err != nil {
+		return fail(stderr, action, err)
+	}
+	resp.Body.Close()
+	return exitOK
+}
+
+func runTxs(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := commandFlags("txs", stderr)
+	client := clientFlags(flags)
+	address := flags.String("address", "", "address or ENS name")
+	format := formatFlag(flags)
+	if code, ok := parseFlags(flags, args, address); !ok {
+		return code
+	}
+	if !checkFormat(flags, *format, "table", "json") {
+		return exitUsage
+	}
+
+	var txs []json.RawMessage
+	if err := client.callJSON(ctx, http.MethodGet, "/transactions", url.Values{"address": {*address}}, &txs); err != nil {
+		return fail(stderr, "txs", err)
+	}
+	if *format == "json" {
+		if txs == nil {
+			txs = []json.RawMessage{}
+		}
+		return printJSON(stdout, stderr, "txs", txs)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "HASH\tBLOCK\tTIME\tSTATUS\tFROM\tTO\tTOKEN\tVALUE")
+	for _, raw := range txs {
+		var tx ethereum.Transaction
+		if err := json.Unmarshal(raw, &tx); err != nil {
+			return fail(stderr, "txs", fmt.Errorf("decode transaction: %w", err))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			tx.Hash, decimal(tx.BlockNumber), blockTime(tx.BlockTimestamp), tx.Status, tx.From, tx.To, tx.Token, value(tx))
+	}
+	if err := tw.Flush(); err != nil {
+		return fail(stderr, "txs", err)
+	}
+	return exitOK
+}
+
+func runHead(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := commandFlags("head", stderr)
+	client := clientFlags(flags)
+	format := formatFlag(flags)
+	if code, ok := parseFlags(flags, args, nil); !ok {
+		return code
+	}
+	if !checkFormat(flags, *format, "table", "json") {
+		return exitUsage
+	}
+
+	var head struct {
+		CurrentBlock int `json:"current_block"`
+	}
+	if err := client.callJSON(ctx, http.MethodGet, "/current_block", nil, &head); err != nil {
+		return fail(stderr, "head", err)
+	}
+	if *format == "json" {
+		return printJSON(stdout, stderr, "head", head)
+	}
+	fmt.Fprintln(stdout, head.CurrentBlock)
+	return exitOK
+}
+
+func runBackfill(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := commandFlags("backfill", stderr)
+	client := clientFlags(flags)
+	address := flags.String("address", "", "subscribed address or ENS name")
+	fromBlock := flags.Int("from-block", -1, "first block to index")
+	toBlock := flags.Int("to-block", -1, "last block to index, the last processed block by default")
+	format := formatFlag(flags)
+	if code, ok := parseFlags(flags, args, address); !ok {
+		return code
+	}
+	if !checkFormat(flags, *format, "table", "json") {
+		return exitUsage
+	}
+	if *fromBlock < 0 {
+		fmt.Fprintln(stderr, "backfill: -from-block is required")
+		return exitUsage
+	}
+
+	query := url.Values{"from_block": {strconv.Itoa(*fromBlock)}}
+	if *toBlock >= 0 {
+		query.Set("to_block", strconv.Itoa(*toBlock))
+	}
+	var result struct {
+		FromBlock    int `json:"from_block"`
+		ToBlock      int `json:"to_block"`
+		Transactions int `json:"transactions"`
+	}
+	if err := client.callJSON(ctx, http.MethodPost, "/address/"+url.PathEscape(*address)+"/backfill", query, &result); err != nil {
+		return fail(stderr, "backfill", err)
+	}
+	if *format == "json" {
+		return printJSON(stdout, stderr, "backfill", result)
+	}
+
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FROM\tTO\tTRANSACTIONS")
+	fmt.Fprintf(tw, "%d\t%d\t%d\n", result.FromBlock, result.ToBlock, result.Transactions)
+	if err := tw.Flush(); err != nil {
+		return fail(stderr, "backfill", err)
+	}
+	return exitOK
+}
+
+func runExport(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := commandFlags("export", stderr)
+	client := clientFlags(flags)
+	address := flags.String("address", "", "address or ENS name")
+	format := flags.String("format", "csv", "csv or ndjson")
+	output := flags.String("o", "", "file to write the export to, stdout by default")
+	if code, ok := parseFlags(flags, args, address); !ok {
+		return code
+	}
+	if !checkFormat(flags, *format, "csv", "ndjson") {
+		return exitUsage
+	}
+
+	return copyResponse(ctx, client, "export", "/address/"+url.PathEscape(*address)+"/export", url.Values{"format": {*format}}, *output, stdout, stderr)
+}
+
+// copyResponse writes the body of a GET request to the output file or stdout
+func copyResponse(ctx context.Context, client *apiClient, name, path string, query url.Values, output string, stdout, stderr io.Writer) int {
+	out, closeOutput, err := createOutput(output, stdout)
+	if err != nil {
+		return fail(stderr, name, err)
+	}
+	err = client.copyTo(ctx, path, query, out)
+	if closeErr := closeOutput(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fail(stderr, name, err)
+	}
+	return exitOK
+}
+
+// printJSON writes v as a line of JSON, so output can be piped to jq
+func printJSON(stdout, stderr io.Writer, name string, v any) int {
+	if err := json.NewEncoder(stdout).Encode(v); err != nil {
+		return fail(stderr, name, err)
+	}
+	return exitOK
+}
+
+// decimal formats a hex quantity in decimal, empty quantities stay empty
+func decimal(quantity string) string {
+	if n, err := ethereum.ParseBigQuantity(quantity); err == nil {
+		return n.String()
+	}
+	return quantity
+}
+
+// blockTime formats a hex unix time of a block as RFC 3339
+func blockTime(timestamp string) string {
+	if t, err := ethereum.ParseTimestamp(timestamp); err == nil {
+		return t.Format(time.RFC3339)
+	}
+	return ""
+}
+
+// value formats ETH values in ether and token amounts in the smallest units, as token decimals are unknown
+func value(tx ethereum.Transaction) string {
+	amount, err := ethereum.ParseBigQuantity(tx.Value)
+	if err != nil {
+		return tx.Value
+	}
+	if tx.Token != "" {
+		return amount.String()
+	}
+	return ethereum.FormatEther(amount) + " ETH"
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/server"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+type backfillerFunc func(ctx context.Context, address string, fromBlock, toBlock int) (int, error)
+
+func (f backfillerFunc) Backfill(ctx context.Context, address string, fromBlock, toBlock int) (int, error) {
+	return f(ctx, address, fromBlock, toBlock)
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	address := "0x1111111111111111111111111111111111111111"
+
+	chainStorage := storage.NewInMemoryStorage()
+	_ = chainStorage.CommitBlock(ctx, 101, []ethereum.AddressTx{{Address: address, Tx: ethereum.Transaction{
+		Hash: "0xabc", From: "0x2222222222222222222222222222222222222222", To: address, Value: "0x14d1120d7b160000",
+		BlockNumber: "0x65", BlockTimestamp: "0x6554d1c7", Status: ethereum.TransactionStatusMined,
+	}}}, nil)
+	backfiller := backfillerFunc(func(ctx context.Context, address string, fromBlock, toBlock int) (int, error) {
+		return toBlock - fromBlock, nil
+	})
+	api := httptest.NewServer(server.NewNaiveHTTPServer(server.Chains{1: chainStorage}, log,
+		server.WithDefaultChain(1), server.WithBackfiller(1, backfiller)).Handler)
+	defer api.Close()
+
+	exec := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		if len(args) > 0 && !strings.HasPrefix(args[0], "help") {
+			args = append(args, "-server", api.URL)
+		}
+		code := run(ctx, args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+		// expected in stdout, or in stderr for failed commands
+		output string
+	}{
+		{name: "subscribe", args: []string{"subscribe", "-address", address}, code: exitOK},
+		{name: "subscribe again", args: []string{"subscribe", "-address", address}, code: exitOK},
+		{name: "transactions table", args: []string{"txs", "-address", address}, code: exitOK, output: "0xabc  101    2023-11-15T14:12:23Z  mined"},
+		{name: "transactions on chain", args: []string{"txs", "-address", address, "-chain", "1"}, code: exitOK, output: "1.5 ETH"},
+		{name: "transactions of unknown chain", args: []string{"txs", "-address", address, "-chain", "5"}, code: exitNotFound, output: "unknown chain"},
+		{name: "malformed address", args: []string{"txs", "-address", "0x12"}, code: exitError, output: "invalid address"},
+		{name: "head", args: []string{"head"}, code: exitOK, output: "101\n"},
+		{name: "head json", args: []string{"head", "-format", "json"}, code: exitOK, output: `{"current_block":101}`},
+		{name: "backfill", args: []string{"backfill", "-address", address, "-from-block", "90"}, code: exitOK, output: "90    101  11"},
+		{name: "backfill without range", args: []string{"backfill", "-address", address}, code: exitUsage, output: "-from-block is required"},
+		{name: "export", args: []string{"export", "-address", address}, code: exitOK, output: "hash,log_index,block_number"},
+		{name: "report", args: []string{"report", "-address", address, "-format", "csv"}, code: exitOK, output: "total,ETH,,1500000000000000000,0,1500000000000000000,1"},
+		{name: "unsubscribe", args: []string{"unsubscribe", "-address", address}, code: exitOK},
+		{name: "unsubscribe again", args: []string{"unsubscribe", "-address", address}, code: exitNotFound, output: "is not subscribed"},
+		{name: "missing address", args: []string{"txs"}, code: exitUsage, output: "-address is required"},
+		{name: "invalid format", args: []string{"head", "-format", "xml"}, code: exitUsage, output: "invalid format"},
+		{name: "command help", args: []string{"txs", "-h"}, code: exitOK, output: "-address"},
+		{name: "unknown command", args: []string{"status"}, code: exitUsage, output: "unknown command"},
+		{name: "help", args: []string{"help"}, code: exitOK, output: "backfill"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := exec(tt.args...)
+			if code != tt.code {
+				t.Fatalf("expected exit code %d, got %d: %s", tt.code, code, stderr)
+			}
+			if output := stdout + stderr; !strings.Contains(output, tt.output) {
+				t.Fatalf("expected output to contain %q, got %q", tt.output, output)
+			}
+		})
+	}
+
+	t.Run("transactions json", func(t *testing.T) {
+		code, stdout, stderr := exec("txs", "-address", address, "-format", "json")
+		if code != exitOK {
+			t.Fatalf("expected exit code %d, got %d: %s", exitOK, code, stderr)
+		}
+		var txs []ethereum.Transaction
+		if err := json.Unmarshal([]byte(stdout), &txs); err != nil {
+			t.Fatal(err)
+		}
+		if len(txs) != 1 || txs[0].Hash != "0xabc" {
+			t.Fatalf("unexpected transactions %+v", txs)
+		}
+	})
+
+	t.Run("unreachable instance", func(t *testing.T) {
+		var stderr bytes.Buffer
+		if code := run(ctx, []string{"head", "-server", "http://127.0.0.1:1"}, io.Discard, &stderr); code != exitError {
+			t.Fatalf("expected exit code %d, got %d", exitError, code)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+// chainConfig is a chain to index and the JSON-RPC endpoint serving it
+type chainConfig struct {
+	id       int
+	endpoint string
+}
+
+// chainsFlag collects repeated -chain id=url flags
+type chainsFlag []chainConfig
+
+func (f *chainsFlag) String() string {
+	chains := make([]string, 0, len(*f))
+	for _, c := range *f {
+		chains = append(chains, fmt.Sprintf("%d=%s", c.id, c.endpoint))
+	}
+	return strings.Join(chains, ",")
+}
+
+func (f *chainsFlag) Set(value string) error {
+	id, endpoint, ok := strings.Cut(value, "=")
+	if !ok || endpoint == "" {
+		return fmt.Errorf("expected chain as id=url, got %q", value)
+	}
+
+	chainID, err := strconv.Atoi(id)
+	if err != nil || chainID <= 0 {
+		return fmt.Errorf("invalid chain id %q", id)
+	}
+
+	for _, c := range *f {
+		if c.id == chainID {
+			return fmt.Errorf("chain %d configured twice", chainID)
+		}
+	}
+
+	*f = append(*f, chainConfig{id: chainID, endpoint: endpoint})
+	return nil
+}
+
+// loadAPIKeys reads API keys of tenants from a file with a tenant:key pair per line. Empty lines and
+// lines starting with # are skipped
+func loadAPIKeys(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read api keys: %w", err)
+	}
+
+	keys := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tenantID, key, ok := strings.Cut(line, ":")
+		if !ok || tenantID == "" || key == "" {
+			return nil, fmt.Errorf("api keys line %d: expected tenant:key", i+1)
+		}
+		if _, ok := keys[key]; ok {
+			return nil, fmt.Errorf("api keys line %d: key is used twice", i+1)
+		}
+		keys[key] = tenantID
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no api keys in %s", path)
+	}
+	return keys, nil
+}
+
+// loadWatchlist reads addresses to subscribe at startup from a file with an address or a tenant:address pair
+// per line, addresses without a tenant belong to tenant.Default. Empty lines and lines starting with # are skipped.
+// Returned addresses are lower cased and grouped by tenant
+func loadWatchlist(path string) (map[string][]string, int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read watchlist: %w", err)
+	}
+
+	watchlist := make(map[string][]string)
+	total := 0
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tenantID, address, ok := strings.Cut(line, ":")
+		if !ok {
+			tenantID, address = tenant.Default, line
+		}
+		if tenantID == "" {
+			return nil, 0, fmt.Errorf("watchlist line %d: expected address or tenant:address", i+1)
+		}
+		address, err := ethereum.ParseAddress(address)
+		if err != nil {
+			return nil, 0, fmt.Errorf("watchlist line %d: %w", i+1, err)
+		}
+		watchlist[tenantID] = append(watchlist[tenantID], address)
+		total++
+	}
+	return watchlist, total, nil
+}
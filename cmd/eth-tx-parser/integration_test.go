@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/fakenode"
+)
+
+// TestServe runs the service against a fake node and checks transactions of a subscribed address
+// show up in the api once they are mined
+func TestServe(t *testing.T) {
+	chainID := 1337
+	address := "0x1111111111111111111111111111111111111111"
+	sender := "0x2222222222222222222222222222222222222222"
+
+	node := fakenode.New(fakenode.WithChainID(chainID))
+	defer node.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	exited := make(chan int)
+	go func() {
+		exited <- run(ctx, []string{
+			"serve", "-addr", addr, "-chain", fmt.Sprintf("%d=%s", chainID, node.URL),
+			"-grpc-addr", "", "-rate-limit", "0", "-poll-interval", "50ms",
+		}, io.Discard, io.Discard)
+	}()
+	defer func() {
+		cancel()
+		if code := <-exited; code != exitOK {
+			t.Errorf("expected exit code %d, got %d", exitOK, code)
+		}
+	}()
+
+	call := func(method, path string, v any) (int, error) {
+		req, err := http.NewRequest(method, "http://"+addr+path, nil)
+		if err != nil {
+			return 0, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			return resp.StatusCode, json.NewDecoder(resp.Body).Decode(v)
+		}
+		return resp.StatusCode, nil
+	}
+	eventually := func(t *testing.T, what string, condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second * 10)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond * 20)
+		}
+	}
+	// transaction waits for the transaction to be listed for the address
+	transaction := func(t *testing.T, hash string) ethereum.Transaction {
+		t.Helper()
+		var found []ethereum.Transaction
+		eventually(t, "transaction "+hash, func() bool {
+			var txs []ethereum.Transaction
+			if _, err := call(http.MethodGet, "/transactions?"+url.Values{"address": {address}}.Encode(), &txs); err != nil {
+				return false
+			}
+			found = found[:0]
+			for _, tx := range txs {
+				if tx.Hash == hash {
+					found = append(found, tx)
+				}
+			}
+			return len(found) > 0
+		})
+		if len(found) != 1 {
+			t.Fatalf("expected transaction %s once, got %+v", hash, found)
+		}
+		return found[0]
+	}
+
+	eventually(t, "the service to process the head", func() bool {
+		var head struct {
+			CurrentBlock int `json:"current_block"`
+		}
+		status, err := call(http.MethodGet, "/current_block", &head)
+		return err == nil && status == http.StatusOK && head.CurrentBlock == node.Head()
+	})
+	if status, err := call(http.MethodPost, "/address/"+address+"/subscribe", nil); err != nil || status != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %v", http.StatusOK, status, err)
+	}
+
+	t.Run("subscribe, poll and list", func(t *testing.T) {
+		number := node.Mine(
+			ethereum.Transaction{Hash: "0xa1", From: sender, To: address, Value: "0xde0b6b3a7640000"},
+			ethereum.Transaction{Hash: "0xa2", From: address, To: sender, Value: "0x1", Failed: true},
+			ethereum.Transaction{Hash: "0xa3", From: sender, To: "0x3333333333333333333333333333333333333333", Value: "0x1"},
+		)
+
+		received := transaction(t, "0xa1")
+		if received.BlockNumber != ethereum.FormatQuantity(number) || received.Status != ethereum.TransactionStatusMined ||
+			received.GasUsed != "0x5208" || received.BlockTimestamp == "" || received.Failed {
+			t.Fatalf("unexpected transaction %+v", received)
+		}
+		if sent := transaction(t, "0xa2"); !sent.Failed {
+			t.Fatalf("expected a failed transaction, got %+v", sent)
+		}
+
+		var txs []ethereum.Transaction
+		_, _ = call(http.MethodGet, "/transactions?address="+address, &txs)
+		for _, tx := range txs {
+			if tx.Hash == "0xa3" {
+				t.Fatalf("expected transactions of other addresses to be skipped, got %+v", tx)
+			}
+		}
+	})
+
+	t.Run("reorg of blocks not processed yet", func(t *testing.T) {
+		node.Pause()
+		number := node.Mine(ethereum.Transaction{Hash: "0xb1", From: sender, To: address, Value: "0x1"})
+		dropped := node.Reorg(1)
+		node.Mine()
+		node.Mine(dropped...)
+		node.Resume()
+
+		if tx := transaction(t, "0xb1"); tx.BlockNumber != ethereum.FormatQuantity(number+1) {
+			t.Fatalf("expected the transaction in block %d of the winning fork, got %s", number+1, tx.BlockNumber)
+		}
+	})
+
+	t.Run("lagging node", func(t *testing.T) {
+		blockRequests := node.Requests("eth_getBlockByNumber")
+		node.Lag()
+		number := node.Mine(ethereum.Transaction{Hash: "0xc1", From: sender, To: address, Value: "0x1"})
+		// the block is in the head, but the node answers null for it
+		eventually(t, "the poller to request the withheld block", func() bool {
+			return node.Requests("eth_getBlockByNumber") > blockRequests+1
+		})
+		node.CatchUp()
+
+		if tx := transaction(t, "0xc1"); tx.BlockNumber != ethereum.FormatQuantity(number) {
+			t.Fatalf("expected the transaction in block %d, got %s", number, tx.BlockNumber)
+		}
+	})
+
+	t.Run("rate limited node", func(t *testing.T) {
+		node.SetRateLimit(1, time.Millisecond*200)
+		defer node.SetRateLimit(0, 0)
+		limited := node.RateLimited()
+		node.Mine(ethereum.Transaction{Hash: "0xd1", From: sender, To: address, Value: "0x1"})
+
+		transaction(t, "0xd1")
+		if node.RateLimited() == limited {
+			t.Fatal("expected rate limited requests to be retried")
+		}
+	})
+
+	t.Run("node errors", func(t *testing.T) {
+		blockRequests := node.Requests("eth_getBlockByNumber")
+		node.FailNext("eth_getBlockByNumber", 2, http.StatusOK)
+		node.FailNext("eth_getTransactionReceipt", 1, http.StatusBadGateway)
+		node.Mine(ethereum.Transaction{Hash: "0xe1", From: sender, To: address, Value: "0x1"})
+
+		if tx := transaction(t, "0xe1"); tx.GasUsed == "" {
+			t.Fatalf("expected the receipt to be attached, got %+v", tx)
+		}
+		if requests := node.Requests("eth_getBlockByNumber") - blockRequests; requests < 3 {
+			t.Fatalf("expected the failed block to be requested again, got %d requests", requests)
+		}
+	})
+}
+
+// TestServe_ChainSetupFailure checks a chain failing to set up stops serve before workers of the chains
+// set up earlier start polling their nodes
+func TestServe_ChainSetupFailure(t *testing.T) {
+	node := fakenode.New(fakenode.WithChainID(1337))
+	defer node.Close()
+	// reports chain 1 while configured as chain 5
+	wrongNode := fakenode.New()
+	defer wrongNode.Close()
+
+	code := run(context.Background(), []string{
+		"serve", "-addr", "127.0.0.1:0", "-grpc-addr", "", "-poll-interval", "10ms",
+		"-chain", fmt.Sprintf("1337=%s", node.URL), "-chain", fmt.Sprintf("5=%s", wrongNode.URL),
+	}, io.Discard, io.Discard)
+	if code != exitError {
+		t.Fatalf("expected exit code %d, got %d", exitError, code)
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	if requests := node.Requests("eth_blockNumber"); requests != 0 {
+		t.Fatalf("expected no polling after serve returned, got %d requests", requests)
+	}
+}
@@ -2,42 +2,298 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/mkorolyov/go-eth-tx-parser/internal/abi"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/bus"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ens"
 	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/grpcapi"
 	"github.com/mkorolyov/go-eth-tx-parser/internal/poller"
 	"github.com/mkorolyov/go-eth-tx-parser/internal/server"
 	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+	"google.golang.org/grpc"
+)
+
+const (
+	// poller runs every 12 seconds by default, a few missed runs make the service not ready
+	maxPollerStaleness = time.Minute
+	maxPollerLag       = 10
+	// time given to in-flight http requests and block processing to finish on shutdown
+	shutdownTimeout = time.Second * 30
+	rpcTimeout      = time.Second * 10
 )
 
 func main() {
-	ctx := context.Background()
-	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
+}
+
+// run dispatches args to a command. Serve runs when args start with flags, as before commands were added
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		usage(stdout)
+		return exitOK
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", name)
+		usage(stderr)
+		return exitUsage
+	}
+	return cmd.run(ctx, args, stdout, stderr)
+}
+
+// serve indexes configured chains and serves the api until ctx is cancelled
+func serve(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := commandFlags("serve", stderr)
+	addr := flags.String("addr", ":8080", "address to serve the http api on")
+	var chains chainsFlag
+	flags.Var(&chains, "chain", "chain to index as id=url, repeat for several chains. The first one is served on unprefixed routes (default mainnet on a public endpoint)")
+	abiDir := flags.String("abi-dir", "", "directory with contract ABIs to decode transactions input, laid out as <chain id>/<contract address>.json")
+	apiKeys := flags.String("api-keys", "", "file with api keys of tenants as tenant:key lines. Without it the api is unauthenticated and single-tenant")
+	rateLimit := flags.Float64("rate-limit", 10, "requests per second allowed to a client, 0 disables rate limiting")
+	rateBurst := flags.Int("rate-burst", 20, "requests a client can make at once before being rate limited")
+	transactionsRateLimit := flags.Float64("transactions-rate-limit", 1, "requests per second allowed to a client to list, export, report or backfill transactions, which read whole histories")
+	maxSubscriptions := flags.Int("max-subscriptions", 0, "address and event subscriptions allowed to a tenant per chain, 0 for unlimited. Without api keys the quota applies to the whole deployment")
+	grpcAddr := flags.String("grpc-addr", ":9090", "address to serve the grpc api on, empty disables it")
+	pollInterval := flags.Duration("poll-interval", time.Second*12, "how often new blocks are polled")
+	watchlistPath := flags.String("watchlist", "", "file with addresses subscribed on every chain at startup as address or tenant:address lines, addresses without a tenant are subscribed for the default tenant")
+	if code, ok := parseFlags(flags, args, nil); !ok {
+		return code
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
-	ethClient := ethereum.NewJsonRPCClient(ethereum.WithHTTPClient(&http.Client{}), ethereum.WithLog(logger))
-	inMemStorage := storage.NewInMemoryStorage()
-	transactionPoller := poller.NewTransactionPoller(inMemStorage, inMemStorage, inMemStorage, ethClient, logger)
+	logger := slog.New(slog.NewJSONHandler(stdout, nil))
+
+	defaultChain := ethereum.MainnetChainID
+	if len(chains) > 0 {
+		defaultChain = chains[0].id
+	}
+	serverOptions := []server.Option{server.WithDefaultChain(defaultChain)}
+	grpcOptions := []grpcapi.Option{grpcapi.WithDefaultChain(defaultChain)}
+	parsers := server.Chains{}
+
+	if *rateLimit > 0 {
+		serverOptions = append(serverOptions,
+			server.WithRateLimit(server.RateLimit{Rate: *rateLimit, Burst: *rateBurst}),
+			server.WithRouteRateLimit("GET /transactions", server.RateLimit{Rate: *transactionsRateLimit, Burst: max(1, int(*transactionsRateLimit))}),
+			server.WithRouteRateLimit("GET /address/{address}/export", server.RateLimit{Rate: *transactionsRateLimit, Burst: max(1, int(*transactionsRateLimit))}),
+			server.WithRouteRateLimit("GET /address/{address}/report", server.RateLimit{Rate: *transactionsRateLimit, Burst: max(1, int(*transactionsRateLimit))}),
+			server.WithRouteRateLimit("POST /address/{address}/backfill", server.RateLimit{Rate: *transactionsRateLimit, Burst: max(1, int(*transactionsRateLimit))}),
+		)
+	}
+	serverOptions = append(serverOptions, server.WithSubscriptionQuota(*maxSubscriptions))
+	grpcOptions = append(grpcOptions, grpcapi.WithSubscriptionQuota(*maxSubscriptions))
+
+	if *apiKeys != "" {
+		keys, err := loadAPIKeys(*apiKeys)
+		if err != nil {
+			logger.Error("failed to load api keys", "error", err)
+			return exitError
+		}
+		serverOptions = append(serverOptions, server.WithAPIKeys(keys))
+		grpcOptions = append(grpcOptions, grpcapi.WithAPIKeys(keys))
+	} else {
+		logger.Warn("api keys are not configured, api is unauthenticated")
+	}
+
+	var watchlist map[string][]string
+	var storageOptions []storage.Option
+	if *watchlistPath != "" {
+		var size int
+		var err error
+		if watchlist, size, err = loadWatchlist(*watchlistPath); err != nil {
+			logger.Error("failed to load watchlist", "error", err)
+			return exitError
+		}
+		storageOptions = append(storageOptions, storage.WithExpectedSubscriptions(size))
+	}
+
+	// workers are queued while chains are set up and started once all of them are, so a chain which fails
+	// to set up never leaves workers of the previous ones running after serve returns
+	var workers sync.WaitGroup
+	var queued []func()
+	startWorker := func(name string, chainID int, start func(ctx context.Context) error) {
+		queued = append(queued, func() {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				if err := start(ctx); err != nil {
+					logger.Error(name+" stopped", "chain_id", chainID, "error", err)
+				}
+			}()
+		})
+	}
+
+	for _, chain := range chainsOrDefault(chains) {
+		clientOptions := []ethereum.Option{
+			ethereum.WithHTTPClient(&http.Client{Timeout: rpcTimeout}),
+			ethereum.WithLog(logger),
+			ethereum.WithRetries(3, time.Millisecond*500),
+			ethereum.WithChainID(chain.id),
+		}
+		if chain.endpoint != "" {
+			clientOptions = append(clientOptions, ethereum.WithEndpoint(chain.endpoint))
+		}
+		ethClient := ethereum.NewJsonRPCClient(clientOptions...)
+
+		if err := ethClient.VerifyChainID(ctx); err != nil {
+			logger.Error("failed to verify chain endpoint", "chain_id", chain.id, "error", err)
+			return exitError
+		}
+		// ENS registry lives on mainnet, names resolve to the same addresses on other chains
+		if chain.id == ethereum.MainnetChainID {
+			serverOptions = append(serverOptions, server.WithNameResolver(ens.NewResolver(ethClient)))
+		}
 
-	// Start polling for new transactions
-	go transactionPoller.Start(ctx)
+		// every chain has its own storage, so transactions and blocks of different chains never mix
+		inMemStorage := storage.NewInMemoryStorage(append(storageOptions, storage.WithChainID(chain.id))...)
+		for tenantID, addresses := range watchlist {
+			if err := inMemStorage.LoadSubscriptions(ctx, tenantID, addresses); err != nil {
+				logger.Error("failed to load watchlist", "chain_id", chain.id, "tenant", tenantID, "error", err)
+				return exitError
+			}
+		}
+		chainLogger := logger.With("chain_id", chain.id)
+		// stored transactions are fanned out to streaming api clients
+		transactions := bus.New()
+		grpcOptions = append(grpcOptions, grpcapi.WithBus(chain.id, transactions))
+		transactionPoller := poller.NewTransactionPoller(inMemStorage, inMemStorage, ethClient, chainLogger,
+			poller.WithChainID(chain.id),
+			poller.WithPollInterval(*pollInterval),
+			poller.WithTokenTransfers(ethClient, inMemStorage),
+			poller.WithBalances(ethClient, inMemStorage, inMemStorage),
+			poller.WithReceipts(ethClient),
+			poller.WithEvents(ethClient, inMemStorage),
+			poller.WithPublisher(transactions),
+		)
+		mempoolWatcher := poller.NewMempoolWatcher(inMemStorage, inMemStorage, ethClient, chainLogger,
+			poller.WithMempoolChainID(chain.id),
+			poller.WithMempoolPublisher(transactions),
+		)
+		balanceReconciler := poller.NewBalanceReconciler(inMemStorage, ethClient, chainLogger,
+			poller.WithReconcilerChainID(chain.id),
+		)
+
+		// Start polling for new transactions
+		startWorker("poller", chain.id, transactionPoller.Start)
+		// Start watching mempool for pending transactions
+		startWorker("mempool watcher", chain.id, mempoolWatcher.Start)
+		// Start comparing balances reconstructed from indexed history with the node ones
+		startWorker("balance reconciler", chain.id, balanceReconciler.Start)
+
+		registry := abi.NewRegistry()
+		if *abiDir != "" {
+			if err := registry.LoadDir(filepath.Join(*abiDir, strconv.Itoa(chain.id))); err != nil {
+				logger.Error("failed to load contract abis", "chain_id", chain.id, "error", err)
+				return exitError
+			}
+		}
 
-	httpServer := server.NewNaiveHTTPServer(inMemStorage, logger)
+		parsers[chain.id] = inMemStorage
+		serverOptions = append(serverOptions,
+			server.WithABIRegistry(chain.id, registry),
+			server.WithBackfiller(chain.id, poller.NewBackfiller(transactionPoller, inMemStorage)),
+			server.WithReadinessCheck(fmt.Sprintf("storage_%d", chain.id), inMemStorage.Ping),
+			server.WithReadinessCheck(fmt.Sprintf("poller_%d", chain.id), func(ctx context.Context) error {
+				return transactionPoller.CheckSync(ctx, max(maxPollerStaleness, *pollInterval*5), maxPollerLag)
+			}),
+		)
+	}
+
+	httpServer := server.NewNaiveHTTPServer(parsers, logger, serverOptions...)
+	httpServer.Addr = *addr
 	go func() {
-		if err := httpServer.ListenAndServe(); err != nil {
-			logger.Info("server stopped", "error", err)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("server stopped", "error", err)
+			cancel()
 		}
 	}()
 
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		listener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			logger.Error("failed to listen for grpc", "address", *grpcAddr, "error", err)
+			return exitError
+		}
+		grpcServer = grpcapi.NewServer(parsers, logger, grpcOptions...)
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				logger.Error("grpc server stopped", "error", err)
+				cancel()
+			}
+		}()
+	}
+
+	for _, start := range queued {
+		start()
+	}
+
 	<-ctx.Done()
 	logger.Info("shutting down server...")
-	// ctx already closed but it is not a problem here
-	if err := httpServer.Shutdown(ctx); err != nil {
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		logger.Error("shutdown server", "error", err)
 	}
+	if grpcServer != nil {
+		// streams only end with their clients, so they are cut off once the shutdown timeout passes
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			grpcServer.Stop()
+		}
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		logger.Error("timed out waiting for pollers to finish in-flight blocks")
+	}
 	logger.Info("exiting...")
+	return exitOK
+}
+
+// chainsOrDefault falls back to mainnet on the client default endpoint when no chain is configured
+func chainsOrDefault(chains chainsFlag) chainsFlag {
+	if len(chains) == 0 {
+		return chainsFlag{{id: ethereum.MainnetChainID}}
+	}
+	return chains
 }
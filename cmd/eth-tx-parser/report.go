@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/url"
+)
+
+// runReport fetches the accounting report of an address from a running instance
+func runReport(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := commandFlags("report", stderr)
+	client := clientFlags(flags)
+	address := flags.String("address", "", "address or ENS name to report on")
+	from := flags.String("from", "", "start of the period as a date or an RFC 3339 time, the first block by default")
+	to := flags.String("to", "", "exclusive end of the period as a date or an RFC 3339 time, now by default")
+	format := flags.String("format", "json", "json or csv")
+	output := flags.String("o", "", "file to write the report to, stdout by default")
+	if code, ok := parseFlags(flags, args, address); !ok {
+		return code
+	}
+	if !checkFormat(flags, *format, "json", "csv") {
+		return exitUsage
+	}
+
+	query := url.Values{"format": {*format}}
+	if *from != "" {
+		query.Set("from", *from)
+	}
+	if *to != "" {
+		query.Set("to", *to)
+	}
+	return copyResponse(ctx, client, "report", "/address/"+url.PathEscape(*address)+"/report", query, *output, stdout, stderr)
+}
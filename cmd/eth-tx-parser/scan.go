@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/poller"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+// addressesFlag collects repeated -address flags
+type addressesFlag []string
+
+func (f *addressesFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *addressesFlag) Set(value string) error {
+	address, err := ethereum.ParseAddress(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, address)
+	return nil
+}
+
+// scanResult is a transaction of a scanned address
+type scanResult struct {
+	Address string `json:"address"`
+	ethereum.Transaction
+}
+
+// scanWriter writes transactions of scanned blocks as the poller commits them
+type scanWriter struct {
+	out   io.Writer
+	table *tabwriter.Writer
+	found int
+	// first write error, the poller does not expect publishing to fail
+	err error
+}
+
+func newScanWriter(format string, out io.Writer) *scanWriter {
+	w := &scanWriter{out: out}
+	if format == "table" {
+		w.table = tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w.table, "ADDRESS\tBLOCK\tTIME\tHASH\tFROM\tTO\tTOKEN\tVALUE")
+	}
+	return w
+}
+
+func (w *scanWriter) Publish(txs []ethereum.AddressTx) {
+	w.found += len(txs)
+	if w.err != nil {
+		return
+	}
+
+	encoder := json.NewEncoder(w.out)
+	for _, addressTx := range txs {
+		tx := addressTx.Tx
+		if w.table == nil {
+			w.err = encoder.Encode(scanResult{Address: addressTx.Address, Transaction: tx})
+		} else {
+			_, w.err = fmt.Fprintf(w.table, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				addressTx.Address, decimal(tx.BlockNumber), blockTime(tx.BlockTimestamp), tx.Hash, tx.From, tx.To, tx.Token, value(tx))
+		}
+		if w.err != nil {
+			return
+		}
+	}
+}
+
+// flush writes buffered table rows and returns the first write error
+func (w *scanWriter) flush() error {
+	if w.err == nil && w.table != nil {
+		w.err = w.table.Flush()
+	}
+	return w.err
+}
+
+// runScan processes a range of blocks once with the poller for the given addresses, without storage or the api.
+// Exits with exitNotFound when the addresses have no transactions in the range
+func runScan(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	flags := commandFlags("scan", stderr)
+	var chains chainsFlag
+	flags.Var(&chains, "chain", "chain to scan as id=url (default mainnet on a public endpoint)")
+	var addresses addressesFlag
+	flags.Var(&addresses, "address", "address to scan for, repeat for several addresses")
+	fromBlock := flags.Int("from-block", -1, "first block to scan")
+	toBlock := flags.Int("to-block", -1, "last block to scan, the chain head by default")
+	receipts := flags.Bool("receipts", true, "record fees and failures of transactions from their receipts")
+	tokens := flags.Bool("tokens", true, "scan ERC-20 transfers")
+	format := formatFlag(flags)
+	output := flags.String("o", "", "file to write transactions to, stdout by default")
+	verbose := flags.Bool("v", false, "log processed blocks to stderr")
+	if code, ok := parseFlags(flags, args, nil); !ok {
+		return code
+	}
+	if !checkFormat(flags, *format, "table", "json") {
+		return exitUsage
+	}
+	switch {
+	case len(addresses) == 0:
+		fmt.Fprintln(stderr, "scan: -address is required")
+		return exitUsage
+	case len(chains) > 1:
+		fmt.Fprintln(stderr, "scan: a single -chain is scanned at once")
+		return exitUsage
+	case *fromBlock < 0:
+		fmt.Fprintln(stderr, "scan: -from-block is required")
+		return exitUsage
+	case *toBlock >= 0 && *toBlock < *fromBlock:
+		fmt.Fprintln(stderr, "scan: -to-block is before -from-block")
+		return exitUsage
+	}
+
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: level}))
+
+	chain := chainsOrDefault(chains)[0]
+	clientOptions := []ethereum.Option{
+		ethereum.WithHTTPClient(&http.Client{Timeout: rpcTimeout}),
+		ethereum.WithLog(logger),
+		ethereum.WithRetries(3, time.Millisecond*500),
+		ethereum.WithChainID(chain.id),
+	}
+	if chain.endpoint != "" {
+		clientOptions = append(clientOptions, ethereum.WithEndpoint(chain.endpoint))
+	}
+	ethClient := ethereum.NewJsonRPCClient(clientOptions...)
+	if err := ethClient.VerifyChainID(ctx); err != nil {
+		return fail(stderr, "scan", err)
+	}
+	if *toBlock < 0 {
+		head, err := ethClient.GetBlockNumber(ctx)
+		if err != nil {
+			return fail(stderr, "scan", err)
+		}
+		*toBlock = head
+	}
+
+	// scanned addresses are subscribed in a throwaway storage, so blocks are processed the same way the service does
+	scanStorage := storage.NewInMemoryStorage()
+	for _, address := range addresses {
+		if err := scanStorage.Subscribe(ctx, address); err != nil {
+			return fail(stderr, "scan", err)
+		}
+	}
+
+	out, closeOutput, err := createOutput(*output, stdout)
+	if err != nil {
+		return fail(stderr, "scan", err)
+	}
+	writer := newScanWriter(*format, out)
+	pollerOptions := []poller.Option{poller.WithChainID(chain.id), poller.WithPublisher(writer)}
+	if *tokens {
+		pollerOptions = append(pollerOptions, poller.WithTokenTransfers(ethClient, scanStorage))
+	}
+	if *receipts {
+		pollerOptions = append(pollerOptions, poller.WithReceipts(ethClient))
+	}
+	transactionPoller := poller.NewTransactionPoller(scanStorage, scanStorage, ethClient, logger, pollerOptions...)
+
+	err = transactionPoller.ProcessRange(ctx, *fromBlock, *toBlock)
+	if flushErr := writer.flush(); err == nil {
+		err = flushErr
+	}
+	if closeErr := closeOutput(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fail(stderr, "scan", err)
+	}
+
+	fmt.Fprintf(stderr, "scanned blocks %d to %d, found %d transactions\n", *fromBlock, *toBlock, writer.found)
+	if writer.found == 0 {
+		return exitNotFound
+	}
+	return exitOK
+}
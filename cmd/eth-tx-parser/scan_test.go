@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+	address := "0x1111111111111111111111111111111111111111"
+	sender := "0x2222222222222222222222222222222222222222"
+
+	// node with blocks 1 to 12, block 11 pays the address
+	var fetched []string
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     int               `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var result any
+		switch req.Method {
+		case "eth_chainId":
+			result = "0x1"
+		case "eth_blockNumber":
+			result = "0xc"
+		case "eth_getBlockByNumber":
+			var number string
+			_ = json.Unmarshal(req.Params[0], &number)
+			fetched = append(fetched, number)
+			block := ethereum.EthereumBlock{Number: number, Timestamp: "0x6554d1c7", Transactions: []ethereum.Transaction{}}
+			if number == "0xb" {
+				block.Transactions = append(block.Transactions, ethereum.Transaction{
+					Hash: "0xabc", From: sender, To: address, Value: "0x14d1120d7b160000", BlockNumber: number,
+				})
+			}
+			result = block
+		case "eth_getTransactionReceipt":
+			result = ethereum.Receipt{TransactionHash: "0xabc", BlockNumber: "0xb", GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00", Status: "0x1"}
+		default:
+			result = []any{}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer node.Close()
+
+	exec := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"scan", "-chain", "1=" + node.URL}, args...)
+		code := run(ctx, args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	t.Run("table up to the head", func(t *testing.T) {
+		fetched = nil
+		code, stdout, stderr := exec("-address", address, "-from-block", "10")
+		if code != exitOK {
+			t.Fatalf("expected exit code %d, got %d: %s", exitOK, code, stderr)
+		}
+		if fmt.Sprint(fetched) != "[0xa 0xb 0xc]" {
+			t.Fatalf("expected blocks 10 to 12 to be fetched, got %v", fetched)
+		}
+		if !strings.Contains(stdout, address+"  11     2023-11-15T14:12:23Z  0xabc") || !strings.Contains(stdout, "1.5 ETH") {
+			t.Fatalf("unexpected output %q", stdout)
+		}
+		if !strings.Contains(stderr, "scanned blocks 10 to 12, found 1 transactions") {
+			t.Fatalf("unexpected summary %q", stderr)
+		}
+	})
+
+	t.Run("json to a file", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "scan.ndjson")
+		code, _, stderr := exec("-address", sender, "-address", address, "-from-block", "11", "-to-block", "11", "-format", "json", "-o", output)
+		if code != exitOK {
+			t.Fatalf("expected exit code %d, got %d: %s", exitOK, code, stderr)
+		}
+		content, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected a line per address, got %q", content)
+		}
+		var result scanResult
+		if err := json.Unmarshal([]byte(lines[0]), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Hash != "0xabc" || result.GasUsed != "0x5208" || result.Address == "" {
+			t.Fatalf("unexpected transaction %+v", result)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		output string
+	}{
+		{name: "nothing found", args: []string{"-address", address, "-from-block", "1", "-to-block", "10"}, code: exitNotFound, output: "found 0 transactions"},
+		{name: "missing address", args: []string{"-from-block", "1"}, code: exitUsage, output: "-address is required"},
+		{name: "malformed address", args: []string{"-address", "0x12", "-from-block", "1"}, code: exitUsage, output: "invalid address"},
+		{name: "missing range", args: []string{"-address", address}, code: exitUsage, output: "-from-block is required"},
+		{name: "reversed range", args: []string{"-address", address, "-from-block", "5", "-to-block", "4"}, code: exitUsage, output: "-to-block is before -from-block"},
+		{name: "other chain", args: []string{"-address", address, "-from-block", "1", "-chain", "5=" + node.URL}, code: exitUsage, output: "a single -chain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := exec(tt.args...)
+			if code != tt.code {
+				t.Fatalf("expected exit code %d, got %d: %s", tt.code, code, stderr)
+			}
+			if output := stdout + stderr; !strings.Contains(output, tt.output) {
+				t.Fatalf("expected output to contain %q, got %q", tt.output, output)
+			}
+		})
+	}
+
+	t.Run("wrong chain", func(t *testing.T) {
+		var stderr bytes.Buffer
+		code := run(ctx, []string{"scan", "-chain", "5=" + node.URL, "-address", address, "-from-block", "1"}, &bytes.Buffer{}, &stderr)
+		if code != exitError || !strings.Contains(stderr.String(), "serves chain 1, expected 5") {
+			t.Fatalf("expected exit code %d, got %d: %s", exitError, code, stderr.String())
+		}
+	})
+}
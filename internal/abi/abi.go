@@ -0,0 +1,175 @@
+// Package abi decodes contract call input data with Solidity JSON ABI definitions.
+package abi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// SelectorLength is the length of the method selector prefixing call input
+const SelectorLength = 4
+
+// Selector identifies a method, first 4 bytes of keccak256 of its signature
+type Selector [SelectorLength]byte
+
+func (s Selector) String() string {
+	return "0x" + hex.EncodeToString(s[:])
+}
+
+// Argument is a method parameter as defined by a JSON ABI
+type Argument struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// fields of tuple types
+	Components []Argument `json:"components,omitempty"`
+}
+
+// canonicalType returns the type as used in signatures, tuples are spelled out with their components
+func (a Argument) canonicalType() string {
+	suffix, ok := strings.CutPrefix(a.Type, "tuple")
+	if !ok {
+		return a.Type
+	}
+	types := make([]string, len(a.Components))
+	for i, c := range a.Components {
+		types[i] = c.canonicalType()
+	}
+	return "(" + strings.Join(types, ",") + ")" + suffix
+}
+
+// Method is a contract function callable with a transaction
+type Method struct {
+	Name   string
+	Inputs []Argument
+}
+
+// Signature returns the canonical signature, e.g. transfer(address,uint256)
+func (m Method) Signature() string {
+	types := make([]string, len(m.Inputs))
+	for i, input := range m.Inputs {
+		types[i] = input.canonicalType()
+	}
+	return m.Name + "(" + strings.Join(types, ",") + ")"
+}
+
+// Selector returns the selector calls of the method start with
+func (m Method) Selector() Selector {
+	h := sha3.NewLegacyKeccak256()
+	h.Write([]byte(m.Signature()))
+	var s Selector
+	copy(s[:], h.Sum(nil))
+	return s
+}
+
+// ABI is a set of contract methods indexed by selector
+type ABI struct {
+	methods map[Selector]Method
+}
+
+// Parse reads a JSON ABI. Entries other than functions, like events and errors, are ignored
+func Parse(data []byte) (*ABI, error) {
+	var entries []struct {
+		Type   string     `json:"type"`
+		Name   string     `json:"name"`
+		Inputs []Argument `json:"inputs"`
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parse abi: %w", err)
+	}
+
+	a := &ABI{methods: make(map[Selector]Method)}
+	for _, entry := range entries {
+		// type defaults to function
+		if entry.Type != "" && entry.Type != "function" {
+			continue
+		}
+		m := Method{Name: entry.Name, Inputs: entry.Inputs}
+		for _, input := range m.Inputs {
+			if _, err := parseType(input); err != nil {
+				return nil, fmt.Errorf("method %s: %w", m.Name, err)
+			}
+		}
+		a.methods[m.Selector()] = m
+	}
+	return a, nil
+}
+
+// MethodBySelector looks up a method of the ABI
+func (a *ABI) MethodBySelector(selector Selector) (Method, bool) {
+	m, ok := a.methods[selector]
+	return m, ok
+}
+
+// ParseSignature builds a method from a signature like transfer(address,uint256). Parameters are unnamed
+func ParseSignature(signature string) (Method, error) {
+	name, params, ok := strings.Cut(signature, "(")
+	if !ok || name == "" || !strings.HasSuffix(params, ")") {
+		return Method{}, fmt.Errorf("invalid signature %q", signature)
+	}
+
+	inputs, err := parseTypeList(strings.TrimSuffix(params, ")"))
+	if err != nil {
+		return Method{}, fmt.Errorf("invalid signature %q: %w", signature, err)
+	}
+	return Method{Name: name, Inputs: inputs}, nil
+}
+
+// parseTypeList parses comma separated canonical types, tuples are written as (t1,t2)
+func parseTypeList(list string) ([]Argument, error) {
+	var args []Argument
+	if list == "" {
+		return args, nil
+	}
+
+	depth, start := 0, 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch list[i] {
+			case '(':
+				depth++
+				continue
+			case ')':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+
+		arg, err := parseCanonicalType(list[start:i])
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		start = i + 1
+	}
+	return args, nil
+}
+
+func parseCanonicalType(s string) (Argument, error) {
+	if !strings.HasPrefix(s, "(") {
+		arg := Argument{Type: s}
+		_, err := parseType(arg)
+		return arg, err
+	}
+
+	end := strings.LastIndex(s, ")")
+	if end < 0 {
+		return Argument{}, fmt.Errorf("unbalanced tuple %q", s)
+	}
+	components, err := parseTypeList(s[1:end])
+	if err != nil {
+		return Argument{}, err
+	}
+	arg := Argument{Type: "tuple" + s[end+1:], Components: components}
+	_, err = parseType(arg)
+	return arg, err
+}
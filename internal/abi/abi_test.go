@@ -0,0 +1,253 @@
+package abi
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelector(t *testing.T) {
+	tests := []struct {
+		signature string
+		selector  string
+	}{
+		{signature: "transfer(address,uint256)", selector: "0xa9059cbb"},
+		{signature: "f(uint256,uint32[],bytes10,bytes)", selector: "0x8be65246"},
+		{signature: "deposit()", selector: "0xd0e30db0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.signature, func(t *testing.T) {
+			m, err := ParseSignature(tt.signature)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if m.Signature() != tt.signature {
+				t.Fatalf("expected signature %s, got %s", tt.signature, m.Signature())
+			}
+			if m.Selector().String() != tt.selector {
+				t.Fatalf("expected selector %s, got %s", tt.selector, m.Selector())
+			}
+		})
+	}
+
+	t.Run("tuple", func(t *testing.T) {
+		m, err := ParseSignature("submit((address,uint256)[],bool)")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if m.Inputs[0].Type != "tuple[]" || len(m.Inputs[0].Components) != 2 || m.Signature() != "submit((address,uint256)[],bool)" {
+			t.Fatalf("unexpected method %+v", m)
+		}
+	})
+}
+
+func words(ws ...string) string {
+	var b strings.Builder
+	for _, w := range ws {
+		b.WriteString(strings.Repeat("0", 64-len(w)) + w)
+	}
+	return b.String()
+}
+
+func TestDecode(t *testing.T) {
+	t.Run("dynamic types", func(t *testing.T) {
+		// example from the Solidity ABI specification
+		m, _ := ParseSignature("f(uint256,uint32[],bytes10,bytes)")
+		input := words("123", "80") +
+			"3132333435363738393000000000000000000000000000000000000000000000" +
+			words("e0", "2", "456", "789", "d") +
+			"48656c6c6f2c20776f726c642100000000000000000000000000000000000000"
+		data, _ := hex.DecodeString(input)
+
+		call, err := m.Decode(data)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		expected := []any{"291", []any{"1110", "1929"}, "0x31323334353637383930", "0x48656c6c6f2c20776f726c6421"}
+		for i, arg := range call.Args {
+			if !reflect.DeepEqual(arg.Value, expected[i]) {
+				t.Fatalf("argument %d: expected %v, got %v", i, expected[i], arg.Value)
+			}
+		}
+	})
+
+	t.Run("tuple and negative integer", func(t *testing.T) {
+		a, err := Parse([]byte(`[{"type":"function","name":"g","inputs":[
+			{"name":"order","type":"tuple","components":[{"name":"maker","type":"address"},{"name":"amount","type":"uint256"}]},
+			{"name":"delta","type":"int8"},
+			{"name":"memo","type":"string"}
+		]},{"type":"event","name":"E","inputs":[]}]`))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		m, ok := a.MethodBySelector(mustMethod(t, "g((address,uint256),int8,string)").Selector())
+		if !ok {
+			t.Fatalf("expected method g to be found by selector")
+		}
+
+		input := words("1111111111111111111111111111111111111111", "5", strings.Repeat("f", 64), "80", "2") +
+			"6869000000000000000000000000000000000000000000000000000000000000"
+		data, _ := hex.DecodeString(input)
+
+		call, err := m.Decode(data)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		order := []Value{
+			{Name: "maker", Type: "address", Value: "0x1111111111111111111111111111111111111111"},
+			{Name: "amount", Type: "uint256", Value: "5"},
+		}
+		if !reflect.DeepEqual(call.Args[0].Value, order) || call.Args[1].Value != "-1" || call.Args[2].Value != "hi" {
+			t.Fatalf("unexpected arguments %+v", call.Args)
+		}
+	})
+
+	t.Run("malformed input", func(t *testing.T) {
+		m, _ := ParseSignature("f(bytes)")
+		tests := map[string]string{
+			"short head":          "01",
+			"offset out of input": words("ffff"),
+			"length out of input": words("20", "ffff"),
+		}
+		for name, input := range tests {
+			data, _ := hex.DecodeString(input)
+			if _, err := m.Decode(data); err == nil {
+				t.Fatalf("%s: expected error", name)
+			}
+		}
+	})
+
+	t.Run("oversized arrays", func(t *testing.T) {
+		for _, signature := range []string{
+			"f(string[1000000000])",
+			"f(uint256[65537])",
+			// head size overflows int
+			"f(uint256[65536][65536][65536][65536])",
+			"f((uint256,bool)[65536][65536][65536][65536])",
+		} {
+			if _, err := ParseSignature(signature); err == nil {
+				t.Fatalf("%s: expected error", signature)
+			}
+		}
+		if _, err := Parse([]byte(`[{"type":"function","name":"f","inputs":[{"name":"s","type":"string[1000000000]"}]}]`)); err == nil {
+			t.Fatal("expected error for an abi with an oversized array")
+		}
+	})
+
+	t.Run("tuples without components", func(t *testing.T) {
+		for _, signature := range []string{"f(())", "f(()[2])", "f((uint256,())[2])"} {
+			if _, err := ParseSignature(signature); err == nil {
+				t.Fatalf("%s: expected error", signature)
+			}
+		}
+		if _, err := Parse([]byte(`[{"type":"function","name":"f","inputs":[{"name":"t","type":"tuple[2]","components":[]}]}]`)); err == nil {
+			t.Fatal("expected error for an abi with an empty tuple")
+		}
+		// decoding does not rely on the abi being validated
+		m := Method{Name: "f", Inputs: []Argument{{Name: "t", Type: "tuple[2]"}}}
+		if _, err := m.Decode(nil); err == nil {
+			t.Fatal("expected error decoding an empty tuple")
+		}
+		// a zero size tuple passes the head size check of the enclosing tuple
+		empty := typ{kind: kindTuple}
+		if _, err := decodeTuple([]typ{{kind: kindArray, size: 2, elem: &empty}}, nil); err == nil {
+			t.Fatal("expected error decoding an array of zero size tuples")
+		}
+	})
+
+	t.Run("arrays longer than input", func(t *testing.T) {
+		tests := map[string]string{
+			"f(string[65536])":          words("20", "0"),
+			"f(uint256[65536])":         words("1", "2"),
+			"f(uint256[3])":             words("1", "2"),
+			"f(uint256[1024][64])":      words("1"),
+			"f((uint256,string)[4096])": words("20"),
+		}
+		for signature, input := range tests {
+			m := mustMethod(t, signature)
+			data, _ := hex.DecodeString(input)
+			if _, err := m.Decode(data); err == nil {
+				t.Fatalf("%s: expected error", signature)
+			}
+		}
+	})
+}
+
+func mustMethod(t *testing.T, signature string) Method {
+	m, err := ParseSignature(signature)
+	if err != nil {
+		t.Fatalf("failed to parse signature %s: %v", signature, err)
+	}
+	return m
+}
+
+func TestRegistry(t *testing.T) {
+	contract := "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	dir := t.TempDir()
+	abiJSON := `[{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]}]`
+	if err := os.WriteFile(filepath.Join(dir, contract+".json"), []byte(abiJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry()
+	if err := r.LoadDir(dir); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	input := "0xa9059cbb" + words("1111111111111111111111111111111111111111", "3e8")
+
+	t.Run("registered contract", func(t *testing.T) {
+		call, ok := r.Decode(strings.ToLower(contract), input)
+		if !ok || call.Method != "transfer" || call.Args[0].Name != "to" || call.Args[1].Value != "1000" {
+			t.Fatalf("unexpected call %+v", call)
+		}
+	})
+
+	t.Run("selector fallback", func(t *testing.T) {
+		call, ok := r.Decode("0x2222222222222222222222222222222222222222", input)
+		if !ok || call.Signature != "transfer(address,uint256)" || call.Args[0].Name != "" {
+			t.Fatalf("unexpected call %+v", call)
+		}
+	})
+
+	t.Run("tenant abi", func(t *testing.T) {
+		renamed := `[{"type":"function","name":"send","inputs":[{"name":"recipient","type":"address"},{"name":"amount","type":"uint256"}]}]`
+		a, err := Parse([]byte(renamed))
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		r.RegisterTenant("team-a", contract, a)
+
+		// the selector of send(address,uint256) differs from transfer, so it is decoded with the loaded abi
+		if call, ok := r.DecodeTenant("team-a", contract, input); !ok || call.Method != "transfer" {
+			t.Fatalf("expected fallback to the shared abi, got %+v", call)
+		}
+		sendInput := mustMethod(t, "send(address,uint256)").Selector().String() + input[10:]
+		if call, ok := r.DecodeTenant("team-a", contract, sendInput); !ok || call.Args[0].Name != "recipient" {
+			t.Fatalf("expected call decoded with the tenant abi, got %+v", call)
+		}
+		if _, ok := r.DecodeTenant("team-b", contract, sendInput); ok {
+			t.Fatal("expected the abi of team-a not to be used for team-b")
+		}
+		if _, ok := r.Decode(contract, sendInput); ok {
+			t.Fatal("expected the abi of team-a not to be used without a tenant")
+		}
+	})
+
+	t.Run("unknown method", func(t *testing.T) {
+		if _, ok := r.Decode(contract, "0x12345678"); ok {
+			t.Fatalf("expected unknown selector not to be decoded")
+		}
+	})
+
+	t.Run("plain transfer", func(t *testing.T) {
+		if _, ok := r.Decode(contract, "0x"); ok {
+			t.Fatalf("expected empty input not to be decoded")
+		}
+	})
+}
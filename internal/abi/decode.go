@@ -0,0 +1,358 @@
+package abi
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const wordSize = 32
+
+// maxArrayLength bounds lengths of fixed arrays, so an uploaded ABI cannot make decoding allocate
+// unbounded memory. Calldata of a block would not fit larger arrays anyway
+const maxArrayLength = 1 << 16
+
+var errTypeTooLarge = errors.New("type too large")
+
+type kind int
+
+const (
+	kindUint kind = iota
+	kindInt
+	kindAddress
+	kindBool
+	kindFixedBytes
+	kindBytes
+	kindString
+	kindSlice
+	kindArray
+	kindTuple
+)
+
+// typ is a parsed ABI type
+type typ struct {
+	kind kind
+	// byte size of fixed bytes, element count of fixed arrays
+	size       int
+	elem       *typ
+	components []Argument
+	fields     []typ
+}
+
+func parseType(arg Argument) (typ, error) {
+	if strings.HasSuffix(arg.Type, "]") {
+		i := strings.LastIndex(arg.Type, "[")
+		if i < 0 {
+			return typ{}, fmt.Errorf("invalid type %q", arg.Type)
+		}
+		elem, err := parseType(Argument{Type: arg.Type[:i], Components: arg.Components})
+		if err != nil {
+			return typ{}, err
+		}
+
+		dims := arg.Type[i+1 : len(arg.Type)-1]
+		if dims == "" {
+			return typ{kind: kindSlice, elem: &elem}, nil
+		}
+		n, err := strconv.Atoi(dims)
+		if err != nil || n <= 0 || n > maxArrayLength {
+			return typ{}, fmt.Errorf("invalid array length in type %q", arg.Type)
+		}
+		t := typ{kind: kindArray, size: n, elem: &elem}
+		// nested static arrays can overflow the head size even with bounded lengths
+		if _, err := t.headSize(); err != nil {
+			return typ{}, fmt.Errorf("invalid type %q: %w", arg.Type, err)
+		}
+		return t, nil
+	}
+
+	switch t := arg.Type; {
+	case t == "address":
+		return typ{kind: kindAddress}, nil
+	case t == "bool":
+		return typ{kind: kindBool}, nil
+	case t == "string":
+		return typ{kind: kindString}, nil
+	case t == "bytes":
+		return typ{kind: kindBytes}, nil
+	case t == "function":
+		// address and selector
+		return typ{kind: kindFixedBytes, size: 24}, nil
+	case t == "tuple":
+		// an empty tuple takes no space, so arrays of it would decode without consuming input
+		if len(arg.Components) == 0 {
+			return typ{}, fmt.Errorf("invalid type %q: tuple without components", arg.Type)
+		}
+		fields := make([]typ, len(arg.Components))
+		for i, c := range arg.Components {
+			field, err := parseType(c)
+			if err != nil {
+				return typ{}, err
+			}
+			fields[i] = field
+		}
+		return typ{kind: kindTuple, components: arg.Components, fields: fields}, nil
+	case strings.HasPrefix(t, "uint"):
+		return sizedType(kindUint, t, "uint")
+	case strings.HasPrefix(t, "int"):
+		return sizedType(kindInt, t, "int")
+	case strings.HasPrefix(t, "bytes"):
+		n, err := strconv.Atoi(strings.TrimPrefix(t, "bytes"))
+		if err != nil || n < 1 || n > wordSize {
+			return typ{}, fmt.Errorf("invalid type %q", t)
+		}
+		return typ{kind: kindFixedBytes, size: n}, nil
+	}
+	return typ{}, fmt.Errorf("unsupported type %q", arg.Type)
+}
+
+// sizedType parses integer types, size defaults to 256 bits
+func sizedType(k kind, t string, prefix string) (typ, error) {
+	bits := strings.TrimPrefix(t, prefix)
+	if bits == "" {
+		return typ{kind: k, size: 256}, nil
+	}
+	n, err := strconv.Atoi(bits)
+	if err != nil || n < 8 || n > 256 || n%8 != 0 {
+		return typ{}, fmt.Errorf("invalid type %q", t)
+	}
+	return typ{kind: k, size: n}, nil
+}
+
+// dynamic types are encoded in the tail with an offset in the head
+func (t typ) dynamic() bool {
+	switch t.kind {
+	case kindBytes, kindString, kindSlice:
+		return true
+	case kindArray:
+		return t.elem.dynamic()
+	case kindTuple:
+		for _, f := range t.fields {
+			if f.dynamic() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headSize is the size a value takes in the head of the enclosing tuple, errTypeTooLarge when it overflows
+func (t typ) headSize() (int, error) {
+	if t.dynamic() {
+		return wordSize, nil
+	}
+	switch t.kind {
+	case kindArray:
+		elem, err := t.elem.headSize()
+		if err != nil {
+			return 0, err
+		}
+		if elem > math.MaxInt/t.size {
+			return 0, errTypeTooLarge
+		}
+		return t.size * elem, nil
+	case kindTuple:
+		size := 0
+		for _, f := range t.fields {
+			n, err := f.headSize()
+			if err != nil {
+				return 0, err
+			}
+			if size > math.MaxInt-n {
+				return 0, errTypeTooLarge
+			}
+			size += n
+		}
+		return size, nil
+	}
+	return wordSize, nil
+}
+
+// Value is a decoded argument. Integers are decimal strings, bytes and addresses are 0x prefixed hex,
+// arrays are lists of values and tuples are lists of Value
+type Value struct {
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// Call is a decoded method call
+type Call struct {
+	Method    string  `json:"method"`
+	Signature string  `json:"signature"`
+	Args      []Value `json:"args"`
+}
+
+var errShortData = errors.New("input data too short")
+
+// Decode decodes call arguments, data is call input without the selector
+func (m Method) Decode(data []byte) (Call, error) {
+	values, err := decodeArguments(m.Inputs, data)
+	if err != nil {
+		return Call{}, fmt.Errorf("decode %s: %w", m.Signature(), err)
+	}
+	return Call{Method: m.Name, Signature: m.Signature(), Args: values}, nil
+}
+
+func decodeArguments(args []Argument, data []byte) ([]Value, error) {
+	types := make([]typ, len(args))
+	for i, arg := range args {
+		t, err := parseType(arg)
+		if err != nil {
+			return nil, err
+		}
+		types[i] = t
+	}
+
+	decoded, err := decodeTuple(types, data)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		values[i] = Value{Name: arg.Name, Type: arg.canonicalType(), Value: decoded[i]}
+	}
+	return values, nil
+}
+
+// decodeTuple decodes consecutive values, offsets of dynamic values are relative to the tuple start
+func decodeTuple(types []typ, data []byte) ([]any, error) {
+	values := make([]any, len(types))
+	pos := 0
+	for i, t := range types {
+		var err error
+		if t.dynamic() {
+			var offset int
+			offset, err = readInt(data, pos)
+			if err == nil {
+				values[i], err = decodeDynamic(t, data[offset:])
+			}
+			pos += wordSize
+		} else {
+			var size int
+			size, err = t.headSize()
+			if err != nil {
+				return nil, err
+			}
+			if size > len(data)-pos {
+				return nil, errShortData
+			}
+			values[i], err = decodeStatic(t, data[pos:])
+			pos += size
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// readInt reads a length or an offset, which must point within data
+func readInt(data []byte, pos int) (int, error) {
+	if pos+wordSize > len(data) {
+		return 0, errShortData
+	}
+	n := new(big.Int).SetBytes(data[pos : pos+wordSize])
+	if !n.IsInt64() || n.Int64() > int64(len(data)) {
+		return 0, fmt.Errorf("offset or length %s out of input data", n)
+	}
+	return int(n.Int64()), nil
+}
+
+func decodeDynamic(t typ, data []byte) (any, error) {
+	switch t.kind {
+	case kindBytes, kindString:
+		n, err := readInt(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		if wordSize+n > len(data) {
+			return nil, errShortData
+		}
+		content := data[wordSize : wordSize+n]
+		if t.kind == kindString {
+			return string(content), nil
+		}
+		return "0x" + hex.EncodeToString(content), nil
+	case kindSlice:
+		n, err := readInt(data, 0)
+		if err != nil {
+			return nil, err
+		}
+		// every element takes at least a word, bounds allocation by input size
+		if n*wordSize > len(data)-wordSize {
+			return nil, errShortData
+		}
+		return decodeTuple(repeat(*t.elem, n), data[wordSize:])
+	case kindArray:
+		// every element takes at least a word, bounds allocation by input size
+		if t.size > len(data)/wordSize {
+			return nil, errShortData
+		}
+		return decodeTuple(repeat(*t.elem, t.size), data)
+	case kindTuple:
+		return decodeFields(t, data)
+	}
+	return nil, fmt.Errorf("unexpected dynamic type kind %d", t.kind)
+}
+
+func decodeStatic(t typ, data []byte) (any, error) {
+	switch t.kind {
+	case kindArray:
+		// every element takes at least a word, bounds allocation by input size
+		if t.size > len(data)/wordSize {
+			return nil, errShortData
+		}
+		return decodeTuple(repeat(*t.elem, t.size), data)
+	case kindTuple:
+		return decodeFields(t, data)
+	}
+
+	if len(data) < wordSize {
+		return nil, errShortData
+	}
+	word := data[:wordSize]
+	switch t.kind {
+	case kindUint:
+		return new(big.Int).SetBytes(word).String(), nil
+	case kindInt:
+		n := new(big.Int).SetBytes(word)
+		// two's complement over the whole word
+		if word[0]&0x80 != 0 {
+			n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 8*wordSize))
+		}
+		return n.String(), nil
+	case kindAddress:
+		return "0x" + hex.EncodeToString(word[12:]), nil
+	case kindBool:
+		return word[wordSize-1] != 0, nil
+	case kindFixedBytes:
+		return "0x" + hex.EncodeToString(word[:t.size]), nil
+	}
+	return nil, fmt.Errorf("unexpected static type kind %d", t.kind)
+}
+
+func decodeFields(t typ, data []byte) (any, error) {
+	decoded, err := decodeTuple(t.fields, data)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]Value, len(t.components))
+	for i, c := range t.components {
+		values[i] = Value{Name: c.Name, Type: c.canonicalType(), Value: decoded[i]}
+	}
+	return values, nil
+}
+
+func repeat(t typ, n int) []typ {
+	types := make([]typ, n)
+	for i := range types {
+		types[i] = t
+	}
+	return types
+}
@@ -0,0 +1,154 @@
+package abi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// commonSignatures are decoded for any contract without a registered ABI
+var commonSignatures = []string{
+	"transfer(address,uint256)",
+	"transferFrom(address,address,uint256)",
+	"approve(address,uint256)",
+	"increaseAllowance(address,uint256)",
+	"decreaseAllowance(address,uint256)",
+	"setApprovalForAll(address,bool)",
+	"safeTransferFrom(address,address,uint256)",
+	"safeTransferFrom(address,address,uint256,bytes)",
+	"safeTransferFrom(address,address,uint256,uint256,bytes)",
+	"safeBatchTransferFrom(address,address,uint256[],uint256[],bytes)",
+	"deposit()",
+	"withdraw(uint256)",
+	"mint(address,uint256)",
+	"burn(uint256)",
+	"multicall(bytes[])",
+	"multicall(uint256,bytes[])",
+	"swapExactETHForTokens(uint256,address[],address,uint256)",
+	"swapExactTokensForETH(uint256,uint256,address[],address,uint256)",
+	"swapExactTokensForTokens(uint256,uint256,address[],address,uint256)",
+	"swapETHForExactTokens(uint256,address[],address,uint256)",
+	"swapTokensForExactTokens(uint256,uint256,address[],address,uint256)",
+	"execute(bytes,bytes[],uint256)",
+}
+
+var commonMethods = func() map[Selector]Method {
+	methods := make(map[Selector]Method, len(commonSignatures))
+	for _, signature := range commonSignatures {
+		m, err := ParseSignature(signature)
+		if err != nil {
+			panic(err)
+		}
+		methods[m.Selector()] = m
+	}
+	return methods
+}()
+
+// Registry holds ABIs of known contracts by address. Calls of other contracts are decoded
+// with a table of common method signatures
+type Registry struct {
+	mu sync.RWMutex
+	// lower case address -> abi
+	contracts map[string]*ABI
+	// tenant -> lower case address -> abi registered by the tenant
+	tenants map[string]map[string]*ABI
+}
+
+func NewRegistry() *Registry {
+	return &Registry{contracts: make(map[string]*ABI), tenants: make(map[string]map[string]*ABI)}
+}
+
+// Register sets the ABI of a contract, replacing a previously registered one
+func (r *Registry) Register(address string, abi *ABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.contracts[strings.ToLower(address)] = abi
+}
+
+// RegisterTenant sets the ABI of a contract used only to decode calls for the tenant. It takes precedence
+// over an ABI set with Register, so tenants can not change how calls are decoded for others
+func (r *Registry) RegisterTenant(tenant, address string, abi *ABI) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	contracts, ok := r.tenants[tenant]
+	if !ok {
+		contracts = make(map[string]*ABI)
+		r.tenants[tenant] = contracts
+	}
+	contracts[strings.ToLower(address)] = abi
+}
+
+// LoadDir registers ABI files of a directory named after contract addresses, e.g. 0xdac1...1ec7.json
+func (r *Registry) LoadDir(dir string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "0x*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		abi, err := Parse(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		r.Register(strings.TrimSuffix(filepath.Base(path), ".json"), abi)
+	}
+	return nil
+}
+
+// Decode decodes call input of a transaction sent to the contract. False is returned for plain transfers,
+// unknown methods and input which does not match the method arguments
+func (r *Registry) Decode(to string, input string) (Call, bool) {
+	return r.decode(nil, to, input)
+}
+
+// DecodeTenant decodes call input like Decode, preferring ABIs registered by the tenant
+func (r *Registry) DecodeTenant(tenant, to, input string) (Call, bool) {
+	return r.decode(&tenant, to, input)
+}
+
+func (r *Registry) decode(tenant *string, to string, input string) (Call, bool) {
+	data, err := hex.DecodeString(strings.TrimPrefix(input, "0x"))
+	if err != nil || len(data) < SelectorLength {
+		return Call{}, false
+	}
+	selector := Selector(data[:SelectorLength])
+
+	method, ok := r.method(tenant, to, selector)
+	if !ok {
+		return Call{}, false
+	}
+
+	call, err := method.Decode(data[SelectorLength:])
+	if err != nil {
+		return Call{}, false
+	}
+	return call, true
+}
+
+func (r *Registry) method(tenant *string, to string, selector Selector) (Method, bool) {
+	to = strings.ToLower(to)
+	r.mu.RLock()
+	abis := []*ABI{r.contracts[to]}
+	if tenant != nil {
+		abis = append([]*ABI{r.tenants[*tenant][to]}, abis...)
+	}
+	r.mu.RUnlock()
+
+	for _, abi := range abis {
+		if abi == nil {
+			continue
+		}
+		if m, ok := abi.MethodBySelector(selector); ok {
+			return m, true
+		}
+	}
+	m, ok := commonMethods[selector]
+	return m, ok
+}
@@ -0,0 +1,260 @@
+// Package addrset provides a concurrent set of Ethereum addresses optimised for membership checks
+// of every transaction participant in a block against large watchlists.
+package addrset
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/maphash"
+	"math/bits"
+	"strings"
+	"sync"
+)
+
+// Address is a decoded 20-byte Ethereum address. Keys do not depend on hex letters case
+type Address [20]byte
+
+// ParseAddress decodes a 0x prefixed hex address
+func ParseAddress(s string) (Address, error) {
+	var a Address
+
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok {
+		digits, ok = strings.CutPrefix(s, "0X")
+	}
+	if !ok || len(digits) != 2*len(a) {
+		return a, fmt.Errorf("invalid address %q: expected 0x prefixed 20 bytes hex", s)
+	}
+
+	// decoded by hand, hex.Decode would need the string copied into a byte slice on every lookup
+	for i := range a {
+		hi, lo := hexValues[digits[2*i]], hexValues[digits[2*i+1]]
+		if hi == invalidHex || lo == invalidHex {
+			return a, fmt.Errorf("invalid address %q: %w", s, hex.InvalidByteError(digits[2*i]))
+		}
+		a[i] = hi<<4 | lo
+	}
+	return a, nil
+}
+
+const invalidHex = 0xff
+
+// hexValues maps hex characters to their values, other characters to invalidHex
+var hexValues = func() (values [256]byte) {
+	for i := range values {
+		values[i] = invalidHex
+	}
+	for c := byte('0'); c <= '9'; c++ {
+		values[c] = c - '0'
+	}
+	for c := byte('a'); c <= 'f'; c++ {
+		values[c] = c - 'a' + 10
+		values[c-'a'+'A'] = c - 'a' + 10
+	}
+	return values
+}()
+
+type Option func(*Set)
+
+// WithBloomFilter enables a bloom filter checked before the set itself. Most checked addresses are not
+// watched, and the filter answers for them from a few cache lines instead of probing a large map.
+// Expected is the number of addresses the filter is initially sized for, it grows with the set
+func WithBloomFilter(expected int) Option {
+	return func(s *Set) {
+		s.bloom = newBloom(expected)
+	}
+}
+
+// Set is a thread-safe set of addresses
+type Set struct {
+	mu        sync.RWMutex
+	addresses map[Address]struct{}
+	bloom     *bloom
+	// addresses removed since the bloom filter was built, their bits are still set
+	removed int
+}
+
+func New(options ...Option) *Set {
+	s := &Set{addresses: make(map[Address]struct{})}
+
+	for _, option := range options {
+		option(s)
+	}
+
+	return s
+}
+
+// Load adds addresses in bulk, e.g. to hydrate the set from persistent storage at startup
+func (s *Set) Load(addresses []string) error {
+	parsed := make([]Address, 0, len(addresses))
+	for _, address := range addresses {
+		a, err := ParseAddress(address)
+		if err != nil {
+			return err
+		}
+		parsed = append(parsed, a)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, a := range parsed {
+		s.add(a)
+	}
+	return nil
+}
+
+// Add adds an address to the set
+func (s *Set) Add(address string) error {
+	a, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(a)
+	return nil
+}
+
+func (s *Set) add(a Address) {
+	if _, ok := s.addresses[a]; ok {
+		return
+	}
+	s.addresses[a] = struct{}{}
+
+	if s.bloom == nil {
+		return
+	}
+	// keep false positives rate low, rebuilding is rare as capacity doubles
+	if len(s.addresses) > s.bloom.capacity {
+		s.rebuildBloom(2 * s.bloom.capacity)
+		return
+	}
+	s.bloom.add(a)
+}
+
+// rebuildBloom replaces the bloom filter with one of the addresses in the set only
+func (s *Set) rebuildBloom(capacity int) {
+	s.bloom = newBloom(capacity)
+	for a := range s.addresses {
+		s.bloom.add(a)
+	}
+	s.removed = 0
+}
+
+// Remove removes an address from the set. The bloom filter keeps its bits, removed addresses
+// only cost a map lookup until the filter is rebuilt once they make up a share of its capacity
+func (s *Set) Remove(address string) error {
+	a, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.addresses[a]; !ok {
+		return nil
+	}
+	delete(s.addresses, a)
+	if s.bloom == nil {
+		return nil
+	}
+	// churning watchlists would otherwise fill the filter with bits of removed addresses,
+	// rebuilds are amortized over capacity/bloomRemovedShare removals
+	s.removed++
+	if s.removed > s.bloom.capacity/bloomRemovedShare {
+		s.rebuildBloom(s.bloom.capacity)
+	}
+	return nil
+}
+
+// Contains checks if the address is in the set. Malformed addresses, including empty
+// recipient of contract creation transactions, are never contained
+func (s *Set) Contains(address string) bool {
+	a, err := ParseAddress(address)
+	if err != nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.bloom != nil && !s.bloom.mayContain(a) {
+		return false
+	}
+	_, ok := s.addresses[a]
+	return ok
+}
+
+// Addresses returns all addresses of the set as lower case hex
+func (s *Set) Addresses() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	addresses := make([]string, 0, len(s.addresses))
+	for a := range s.addresses {
+		addresses = append(addresses, "0x"+hex.EncodeToString(a[:]))
+	}
+	return addresses
+}
+
+// Len returns the number of addresses in the set
+func (s *Set) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.addresses)
+}
+
+const (
+	bloomBitsPerAddress = 16
+	// with 16 bits per address 4 hash functions give ~0.2% false positives
+	bloomHashes = 4
+	// the filter is rebuilt once addresses removed since it was built exceed a quarter of its capacity
+	bloomRemovedShare = 4
+)
+
+// bloom is a bloom filter over addresses using double hashing of a single seeded hash,
+// so vanity addresses sharing prefixes do not collide
+type bloom struct {
+	bits     []uint64
+	mask     uint64
+	capacity int
+	seed     maphash.Seed
+}
+
+func newBloom(capacity int) *bloom {
+	capacity = max(capacity, 1024)
+	// power of two size, so bit position is taken with a mask
+	size := uint64(1) << bits.Len64(uint64(capacity*bloomBitsPerAddress-1))
+	return &bloom{
+		bits:     make([]uint64, size/64),
+		mask:     size - 1,
+		capacity: capacity,
+		seed:     maphash.MakeSeed(),
+	}
+}
+
+func (b *bloom) add(a Address) {
+	h1, h2 := b.hash(a)
+	for i := uint64(0); i < bloomHashes; i++ {
+		pos := (h1 + i*h2) & b.mask
+		b.bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func (b *bloom) mayContain(a Address) bool {
+	h1, h2 := b.hash(a)
+	for i := uint64(0); i < bloomHashes; i++ {
+		pos := (h1 + i*h2) & b.mask
+		if b.bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloom) hash(a Address) (uint64, uint64) {
+	h := maphash.Bytes(b.seed, a[:])
+	// odd step visits distinct positions within a power of two table
+	return h, (h >> 32) | 1
+}
@@ -0,0 +1,155 @@
+package addrset
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name      string
+		address   string
+		expectErr bool
+	}{
+		{name: "lower case", address: "0x1234567890abcdef1234567890abcdef12345678"},
+		{name: "checksummed", address: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+		{name: "empty", address: "", expectErr: true},
+		{name: "no prefix", address: "1234567890abcdef1234567890abcdef12345678", expectErr: true},
+		{name: "too short", address: "0xabc", expectErr: true},
+		{name: "not hex", address: "0x1234567890abcdef1234567890abcdef1234567z", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAddress(tt.address)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	for _, withBloom := range []bool{false, true} {
+		t.Run(fmt.Sprintf("bloom filter %v", withBloom), func(t *testing.T) {
+			var options []Option
+			if withBloom {
+				// tiny filter to exercise growing
+				options = append(options, WithBloomFilter(1))
+			}
+			s := New(options...)
+
+			addresses := testAddresses(5000)
+			if err := s.Load(addresses[:2500]); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			for _, address := range addresses[2500:] {
+				if err := s.Add(address); err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+			}
+
+			if s.Len() != len(addresses) {
+				t.Fatalf("expected %d addresses, got %d", len(addresses), s.Len())
+			}
+			for _, address := range addresses {
+				if !s.Contains(address) {
+					t.Fatalf("expected %s to be contained", address)
+				}
+			}
+			if !s.Contains("0x000000000000000000000000000000000000000A") {
+				t.Fatalf("expected lookup to ignore case")
+			}
+			if s.Contains("0x1000000000000000000000000000000000000000") {
+				t.Fatalf("expected unknown address not to be contained")
+			}
+			if s.Contains("") {
+				t.Fatalf("expected empty address not to be contained")
+			}
+
+			if err := s.Remove(addresses[0]); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if s.Contains(addresses[0]) || s.Len() != len(addresses)-1 {
+				t.Fatalf("expected %s to be removed", addresses[0])
+			}
+		})
+	}
+}
+
+func TestSet_BloomChurn(t *testing.T) {
+	s := New(WithBloomFilter(1024))
+	// many subscribe and unsubscribe cycles of different addresses, the set never outgrows the filter
+	addresses := testAddresses(100_000)
+	for i := 0; i < len(addresses); i += 1000 {
+		cycle := addresses[i : i+1000]
+		if err := s.Load(cycle); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, address := range cycle {
+			if err := s.Remove(address); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+	}
+
+	hits := 0
+	for _, address := range addresses {
+		a, _ := ParseAddress(address)
+		if s.bloom.mayContain(a) {
+			hits++
+		}
+	}
+	// bits of at most a quarter of the capacity of removed addresses are left in the filter
+	if hits > len(addresses)/100 {
+		t.Fatalf("expected at most 1%% of removed addresses to pass the bloom filter, got %d of %d", hits, len(addresses))
+	}
+	if s.bloom.capacity != 1024 {
+		t.Fatalf("expected the filter not to grow, got capacity %d", s.bloom.capacity)
+	}
+}
+
+func TestSet_AddInvalid(t *testing.T) {
+	s := New()
+	if err := s.Add("0xabc"); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+	if err := s.Load([]string{"0xabc"}); err == nil {
+		t.Fatalf("expected error, got none")
+	}
+}
+
+func BenchmarkSet_Contains(b *testing.B) {
+	watched := testAddresses(100_000)
+	// addresses which are not watched, as most of the checked ones
+	checked := make([]string, 1000)
+	for i := range checked {
+		checked[i] = fmt.Sprintf("0x%040x", len(watched)+i*7919)
+	}
+
+	for _, withBloom := range []bool{false, true} {
+		b.Run(fmt.Sprintf("bloom filter %v", withBloom), func(b *testing.B) {
+			var options []Option
+			if withBloom {
+				options = append(options, WithBloomFilter(len(watched)))
+			}
+			s := New(options...)
+			if err := s.Load(watched); err != nil {
+				b.Fatal(err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Contains(checked[i%len(checked)])
+			}
+		})
+	}
+}
+
+func testAddresses(n int) []string {
+	addresses := make([]string, n)
+	for i := range addresses {
+		addresses[i] = fmt.Sprintf("0x%040x", i)
+	}
+	return addresses
+}
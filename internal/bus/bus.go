@@ -0,0 +1,88 @@
+// Package bus fans out stored transactions of subscribed addresses to watchers, e.g. streaming API clients.
+// Watchers which do not keep up are dropped, so a slow client never blocks block processing.
+package bus
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+// watcherBuffer is the number of transactions a watcher can fall behind by before it is dropped
+const watcherBuffer = 256
+
+// Watcher receives transactions of an address. C is closed when the watcher is dropped or stopped
+type Watcher struct {
+	C <-chan ethereum.Transaction
+
+	address string
+	ch      chan ethereum.Transaction
+	// set when the watcher fell behind, read after C is closed
+	dropped bool
+}
+
+// Dropped reports whether the watcher was closed because it fell behind
+func (w *Watcher) Dropped() bool {
+	return w.dropped
+}
+
+type Bus struct {
+	mu sync.Mutex
+	// address -> watchers
+	watchers map[string]map[*Watcher]struct{}
+}
+
+func New() *Bus {
+	return &Bus{watchers: make(map[string]map[*Watcher]struct{})}
+}
+
+// Watch starts receiving transactions of address published from now on
+func (b *Bus) Watch(address string) *Watcher {
+	ch := make(chan ethereum.Transaction, watcherBuffer)
+	w := &Watcher{C: ch, address: strings.ToLower(address), ch: ch}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.watchers[w.address] == nil {
+		b.watchers[w.address] = make(map[*Watcher]struct{})
+	}
+	b.watchers[w.address][w] = struct{}{}
+	return w
+}
+
+// Stop closes the watcher, stopping a dropped watcher is a no-op
+func (b *Bus) Stop(w *Watcher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(w)
+}
+
+// Publish sends stored transactions to watchers of their addresses
+func (b *Bus) Publish(txs []ethereum.AddressTx) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, tx := range txs {
+		for w := range b.watchers[tx.Address] {
+			select {
+			case w.ch <- tx.Tx:
+			default:
+				w.dropped = true
+				b.remove(w)
+			}
+		}
+	}
+}
+
+func (b *Bus) remove(w *Watcher) {
+	watchers, ok := b.watchers[w.address]
+	if _, watching := watchers[w]; !ok || !watching {
+		return
+	}
+	delete(watchers, w)
+	if len(watchers) == 0 {
+		delete(b.watchers, w.address)
+	}
+	close(w.ch)
+}
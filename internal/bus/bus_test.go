@@ -0,0 +1,52 @@
+package bus
+
+import (
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+func TestBus(t *testing.T) {
+	t.Run("watchers receive transactions of their address", func(t *testing.T) {
+		b := New()
+		w := b.Watch("0xAAAA")
+		other := b.Watch("0xbbbb")
+
+		b.Publish([]ethereum.AddressTx{{Address: "0xaaaa", Tx: ethereum.Transaction{Hash: "0x1"}}})
+		if tx := <-w.C; tx.Hash != "0x1" {
+			t.Fatalf("unexpected transaction %+v", tx)
+		}
+		select {
+		case tx := <-other.C:
+			t.Fatalf("unexpected transaction of another address %+v", tx)
+		default:
+		}
+
+		b.Stop(w)
+		if _, ok := <-w.C; ok || w.Dropped() {
+			t.Fatal("expected stopped watcher closed and not dropped")
+		}
+		b.Stop(w)
+	})
+
+	t.Run("watcher falling behind is dropped", func(t *testing.T) {
+		b := New()
+		w := b.Watch("0xaaaa")
+
+		txs := make([]ethereum.AddressTx, watcherBuffer+1)
+		for i := range txs {
+			txs[i] = ethereum.AddressTx{Address: "0xaaaa"}
+		}
+		b.Publish(txs)
+
+		received := 0
+		for range w.C {
+			received++
+		}
+		if received != watcherBuffer || !w.Dropped() {
+			t.Fatalf("expected %d transactions and dropped watcher, got %d", watcherBuffer, received)
+		}
+		// publishing after the drop does not panic on the closed channel
+		b.Publish(txs[:1])
+	})
+}
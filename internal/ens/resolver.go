@@ -0,0 +1,120 @@
+// Package ens caches ENS name resolution, so API requests with names do not query the node each time.
+package ens
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+type Client interface {
+	ResolveName(ctx context.Context, name string) (string, error)
+	LookupAddress(ctx context.Context, address string) (string, error)
+}
+
+// IsName reports whether s looks like an ENS name rather than a hex address
+func IsName(s string) bool {
+	return !strings.HasPrefix(s, "0x") && strings.Contains(s, ".")
+}
+
+type Option func(*Resolver)
+
+// WithTTL sets how long resolved names and addresses are cached, including missing records
+func WithTTL(ttl time.Duration) Option {
+	return func(r *Resolver) {
+		r.ttl = ttl
+	}
+}
+
+const (
+	defaultTTL = time.Minute * 10
+	// cache size expired entries are evicted at, reverse lookups of counterparties add up
+	maxEntries = 10000
+)
+
+func NewResolver(client Client, options ...Option) *Resolver {
+	r := &Resolver{
+		client:  client,
+		ttl:     defaultTTL,
+		now:     time.Now,
+		names:   make(map[string]entry),
+		reverse: make(map[string]entry),
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// Resolver resolves ENS names and reverse records with a TTL cache
+type Resolver struct {
+	client Client
+	ttl    time.Duration
+	now    func() time.Time
+
+	mu sync.Mutex
+	// name -> address
+	names map[string]entry
+	// address -> name
+	reverse map[string]entry
+}
+
+// entry is a cached resolution, empty value for a missing record
+type entry struct {
+	value   string
+	expires time.Time
+}
+
+// ResolveName returns the address of an ENS name, ethereum.ErrNameNotFound if it has none
+func (r *Resolver) ResolveName(ctx context.Context, name string) (string, error) {
+	name = strings.ToLower(name)
+	return r.cached(ctx, r.names, name, r.client.ResolveName)
+}
+
+// LookupAddress returns the primary ENS name of an address, ethereum.ErrNameNotFound if it has none
+func (r *Resolver) LookupAddress(ctx context.Context, address string) (string, error) {
+	address = strings.ToLower(address)
+	return r.cached(ctx, r.reverse, address, r.client.LookupAddress)
+}
+
+func (r *Resolver) cached(ctx context.Context, cache map[string]entry, key string, resolve func(context.Context, string) (string, error)) (string, error) {
+	r.mu.Lock()
+	e, ok := cache[key]
+	r.mu.Unlock()
+
+	if !ok || r.now().After(e.expires) {
+		// concurrent misses of the same key may query the node twice, which is harmless
+		value, err := resolve(ctx, key)
+		if err != nil && !errors.Is(err, ethereum.ErrNameNotFound) {
+			return "", err
+		}
+
+		e = entry{value: strings.ToLower(value), expires: r.now().Add(r.ttl)}
+		r.mu.Lock()
+		if len(cache) >= maxEntries {
+			r.evictExpired(cache)
+		}
+		cache[key] = e
+		r.mu.Unlock()
+	}
+
+	if e.value == "" {
+		return "", ethereum.ErrNameNotFound
+	}
+	return e.value, nil
+}
+
+func (r *Resolver) evictExpired(cache map[string]entry) {
+	now := r.now()
+	for key, e := range cache {
+		if now.After(e.expires) {
+			delete(cache, key)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package ens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+type MockClient struct {
+	ResolveNameFunc   func(ctx context.Context, name string) (string, error)
+	LookupAddressFunc func(ctx context.Context, address string) (string, error)
+}
+
+func (m *MockClient) ResolveName(ctx context.Context, name string) (string, error) {
+	return m.ResolveNameFunc(ctx, name)
+}
+
+func (m *MockClient) LookupAddress(ctx context.Context, address string) (string, error) {
+	return m.LookupAddressFunc(ctx, address)
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	client := &MockClient{ResolveNameFunc: func(ctx context.Context, name string) (string, error) {
+		calls++
+		switch name {
+		case "vitalik.eth":
+			return "0xD8DA6BF26964AF9D7EED9E03E53415D37AA96045", nil
+		case "down.eth":
+			return "", errors.New("node is down")
+		}
+		return "", ethereum.ErrNameNotFound
+	}}
+	now := time.Now()
+	r := NewResolver(client, WithTTL(time.Minute))
+	r.now = func() time.Time { return now }
+
+	t.Run("cached within ttl", func(t *testing.T) {
+		for range 2 {
+			address, err := r.ResolveName(ctx, "Vitalik.eth")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if address != "0xd8da6bf26964af9d7eed9e03e53415d37aa96045" {
+				t.Fatalf("unexpected address %s", address)
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected one resolution, got %d", calls)
+		}
+
+		now = now.Add(time.Minute * 2)
+		_, _ = r.ResolveName(ctx, "vitalik.eth")
+		if calls != 2 {
+			t.Fatalf("expected expired name to be resolved again, got %d resolutions", calls)
+		}
+	})
+
+	t.Run("missing record is cached", func(t *testing.T) {
+		calls = 0
+		for range 2 {
+			if _, err := r.ResolveName(ctx, "missing.eth"); !errors.Is(err, ethereum.ErrNameNotFound) {
+				t.Fatalf("expected ErrNameNotFound, got %v", err)
+			}
+		}
+		if calls != 1 {
+			t.Fatalf("expected one resolution, got %d", calls)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		calls = 0
+		for range 2 {
+			if _, err := r.ResolveName(ctx, "down.eth"); err == nil || errors.Is(err, ethereum.ErrNameNotFound) {
+				t.Fatalf("expected node error, got %v", err)
+			}
+		}
+		if calls != 2 {
+			t.Fatalf("expected every failed resolution to be retried, got %d", calls)
+		}
+	})
+}
+
+func TestIsName(t *testing.T) {
+	tests := map[string]bool{
+		"vitalik.eth": true,
+		"0xd8da6bf26964af9d7eed9e03e53415d37aa96045": false,
+		"abc": false,
+	}
+	for s, expected := range tests {
+		if IsName(s) != expected {
+			t.Fatalf("IsName(%q): expected %v", s, expected)
+		}
+	}
+}
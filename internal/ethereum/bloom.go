@@ -0,0 +1,196 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Keccak256 hashes data with the legacy Keccak-256 used across Ethereum
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// BloomLength is the size of a block logs bloom filter in bytes
+const BloomLength = 256
+
+// Bloom is the 2048 bits logs bloom filter of a block header. It includes addresses of contracts
+// which emitted logs and all topics of the logs, so a negative test means no such log in the block
+type Bloom [BloomLength]byte
+
+// BloomPositions are the three bits a value sets in a bloom filter.
+// Positions of watched values can be computed once and tested against every block
+type BloomPositions [3]uint16
+
+// BloomPositionsOf computes bloom positions of a value, e.g. a 20 bytes address or a 32 bytes topic
+func BloomPositionsOf(data []byte) BloomPositions {
+	hash := Keccak256(data)
+
+	var positions BloomPositions
+	for i := range positions {
+		// low 11 bits of each of the first three pairs of hash bytes
+		positions[i] = (uint16(hash[2*i])<<8 | uint16(hash[2*i+1])) & (BloomLength*8 - 1)
+	}
+	return positions
+}
+
+// Test checks if the value may be in the filter. False positives are possible, false negatives are not
+func (b Bloom) Test(data []byte) bool {
+	return b.TestPositions(BloomPositionsOf(data))
+}
+
+// TestPositions checks precomputed positions of a value
+func (b Bloom) TestPositions(positions BloomPositions) bool {
+	for _, bit := range positions {
+		if !b.testBit(bit) {
+			return false
+		}
+	}
+	return true
+}
+
+// testBit checks a single bit of the filter
+func (b Bloom) testBit(bit uint16) bool {
+	// bit 0 is the lowest bit of the last byte
+	return b[BloomLength-1-bit/8]&(1<<(bit%8)) != 0
+}
+
+// BloomIndex holds precomputed bloom positions of watched values grouped by their first bit, so values
+// whose first bit is not set in a block bloom are skipped as a group instead of being tested one by one
+type BloomIndex struct {
+	mu sync.RWMutex
+	// first bit -> key -> positions
+	byBit map[uint16]map[string]BloomPositions
+	// key -> positions, to remove keys
+	positions map[string]BloomPositions
+}
+
+func NewBloomIndex() *BloomIndex {
+	return &BloomIndex{byBit: make(map[uint16]map[string]BloomPositions), positions: make(map[string]BloomPositions)}
+}
+
+// Add watches positions of a value under the key, replacing positions previously added under it
+func (i *BloomIndex) Add(key string, positions BloomPositions) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(key)
+	values, ok := i.byBit[positions[0]]
+	if !ok {
+		values = make(map[string]BloomPositions)
+		i.byBit[positions[0]] = values
+	}
+	values[key] = positions
+	i.positions[key] = positions
+}
+
+// Remove stops watching the value added under the key
+func (i *BloomIndex) Remove(key string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.remove(key)
+}
+
+func (i *BloomIndex) remove(key string) {
+	positions, ok := i.positions[key]
+	if !ok {
+		return
+	}
+	delete(i.positions, key)
+	delete(i.byBit[positions[0]], key)
+	if len(i.byBit[positions[0]]) == 0 {
+		delete(i.byBit, positions[0])
+	}
+}
+
+// MayContainAny checks if the bloom may contain any of the watched values
+func (i *BloomIndex) MayContainAny(b Bloom) bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for bit, values := range i.byBit {
+		if !b.testBit(bit) {
+			continue
+		}
+		for _, positions := range values {
+			if b.TestPositions(positions) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Add sets bits of the value, used to build filters in tests
+func (b *Bloom) Add(data []byte) {
+	for _, bit := range BloomPositionsOf(data) {
+		b[BloomLength-1-bit/8] |= 1 << (bit % 8)
+	}
+}
+
+func (b Bloom) MarshalJSON() ([]byte, error) {
+	return json.Marshal("0x" + hex.EncodeToString(b[:]))
+}
+
+func (b *Bloom) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid logs bloom: %w", err)
+	}
+
+	// absent for pending blocks
+	if s == "" {
+		*b = Bloom{}
+		return nil
+	}
+
+	decoded, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid logs bloom: %w", err)
+	}
+	if len(decoded) != BloomLength {
+		return fmt.Errorf("invalid logs bloom: expected %d bytes, got %d", BloomLength, len(decoded))
+	}
+
+	copy(b[:], decoded)
+	return nil
+}
+
+// DecodeHex decodes 0x prefixed hex data, e.g. addresses, topics or log data
+func DecodeHex(s string) ([]byte, error) {
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok {
+		return nil, fmt.Errorf("invalid hex %q: expected 0x prefix", s)
+	}
+	return hex.DecodeString(digits)
+}
+
+// AddressTopic returns the 32 bytes topic an indexed address event parameter is logged as
+func AddressTopic(address string) (string, error) {
+	decoded, err := DecodeHex(address)
+	if err != nil {
+		return "", err
+	}
+	if len(decoded) != 20 {
+		return "", fmt.Errorf("invalid address %q: expected 20 bytes", address)
+	}
+	return "0x" + strings.Repeat("0", 24) + hex.EncodeToString(decoded), nil
+}
+
+// TopicAddress extracts an address from an indexed address event parameter
+func TopicAddress(topic string) (string, error) {
+	decoded, err := DecodeHex(topic)
+	if err != nil {
+		return "", err
+	}
+	if len(decoded) != 32 {
+		return "", fmt.Errorf("invalid topic %q: expected 32 bytes", topic)
+	}
+	return "0x" + hex.EncodeToString(decoded[12:]), nil
+}
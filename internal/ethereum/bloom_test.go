@@ -0,0 +1,119 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestKeccak256(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{data: "", expected: "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{data: "Transfer(address,address,uint256)", expected: "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+	}
+
+	for _, tt := range tests {
+		if got := hex.EncodeToString(Keccak256([]byte(tt.data))); got != tt.expected {
+			t.Fatalf("expected keccak256(%q) = %s, got %s", tt.data, tt.expected, got)
+		}
+	}
+}
+
+func TestBloom(t *testing.T) {
+	address, _ := DecodeHex("0x1234567890abcdef1234567890abcdef12345678")
+	other, _ := DecodeHex("0xabcdef1234567890abcdef1234567890abcdef12")
+
+	var bloom Bloom
+	if bloom.Test(address) {
+		t.Fatalf("expected empty bloom not to contain address")
+	}
+
+	bloom.Add(address)
+	if !bloom.Test(address) {
+		t.Fatalf("expected bloom to contain added address")
+	}
+	if bloom.Test(other) {
+		t.Fatalf("expected bloom not to contain other address")
+	}
+
+	t.Run("json round trip", func(t *testing.T) {
+		data, err := json.Marshal(bloom)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		var decoded Bloom
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if decoded != bloom {
+			t.Fatalf("expected decoded bloom to match")
+		}
+	})
+
+	t.Run("invalid length", func(t *testing.T) {
+		var decoded Bloom
+		if err := json.Unmarshal([]byte(`"0x`+strings.Repeat("00", 10)+`"`), &decoded); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+	})
+}
+
+func TestBloomIndex(t *testing.T) {
+	positionsOf := func(s string) BloomPositions {
+		decoded, _ := DecodeHex(s)
+		return BloomPositionsOf(decoded)
+	}
+	watched := "0x1234567890abcdef1234567890abcdef12345678"
+	other := "0xabcdef1234567890abcdef1234567890abcdef12"
+
+	var bloom Bloom
+	decoded, _ := DecodeHex(watched)
+	bloom.Add(decoded)
+
+	index := NewBloomIndex()
+	if index.MayContainAny(bloom) {
+		t.Fatal("expected empty index not to match")
+	}
+
+	index.Add(other, positionsOf(other))
+	if index.MayContainAny(bloom) {
+		t.Fatal("expected index not to match a bloom without its values")
+	}
+
+	index.Add(watched, positionsOf(watched))
+	if !index.MayContainAny(bloom) {
+		t.Fatal("expected index to match a bloom with its value")
+	}
+
+	index.Remove(watched)
+	if index.MayContainAny(bloom) {
+		t.Fatal("expected removed value not to match")
+	}
+	if len(index.positions) != 1 || len(index.byBit) != 1 {
+		t.Fatalf("expected only positions of %s kept, got %v", other, index.positions)
+	}
+}
+func TestAddressTopic(t *testing.T) {
+	address := "0x1234567890abcdef1234567890abcdef12345678"
+
+	topic, err := AddressTopic(address)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if topic != "0x0000000000000000000000001234567890abcdef1234567890abcdef12345678" {
+		t.Fatalf("unexpected topic %s", topic)
+	}
+
+	decoded, err := TopicAddress(topic)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if decoded != address {
+		t.Fatalf("expected %s, got %s", address, decoded)
+	}
+}
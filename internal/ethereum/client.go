@@ -6,17 +6,25 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math/big"
 	"math/rand"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // JsonRPCClient interacts with the Ethereum JSON-RPC endpoint
 type JsonRPCClient struct {
-	endpoint string
-	http     *http.Client
-	log      *slog.Logger
+	endpoint     string
+	chainID      int
+	http         *http.Client
+	log          *slog.Logger
+	retries      int
+	retryBackoff time.Duration
 }
 
 type Option func(*JsonRPCClient)
@@ -33,10 +41,38 @@ func WithLog(log *slog.Logger) Option {
 	}
 }
 
-const defaultEndpoint = "https://ethereum-rpc.publicnode.com"
+// WithEndpoint sets JSON-RPC endpoint url of the node
+func WithEndpoint(endpoint string) Option {
+	return func(c *JsonRPCClient) {
+		c.endpoint = endpoint
+	}
+}
+
+// WithChainID sets the chain the endpoint is expected to serve. It labels client metrics
+// and is checked by VerifyChainID
+func WithChainID(chainID int) Option {
+	return func(c *JsonRPCClient) {
+		c.chainID = chainID
+	}
+}
+
+// WithRetries retries requests failed with transport errors, 429 or 5xx responses
+// up to retries times, doubling the backoff between attempts
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *JsonRPCClient) {
+		c.retries = retries
+		c.retryBackoff = backoff
+	}
+}
+
+const (
+	defaultEndpoint = "https://ethereum-rpc.publicnode.com"
+	// MainnetChainID is the chain id of Ethereum mainnet served by the default endpoint
+	MainnetChainID = 1
+)
 
 func NewJsonRPCClient(options ...Option) JsonRPCClient {
-	c := JsonRPCClient{endpoint: defaultEndpoint, log: slog.New(slog.NewJSONHandler(os.Stdout, nil))}
+	c := JsonRPCClient{endpoint: defaultEndpoint, chainID: MainnetChainID, log: slog.New(slog.NewJSONHandler(os.Stdout, nil))}
 
 	for _, option := range options {
 		option(&c)
@@ -45,28 +81,119 @@ func NewJsonRPCClient(options ...Option) JsonRPCClient {
 	return c
 }
 
-// GetBlockNumber fetches the latest block number
-func (c JsonRPCClient) GetBlockNumber(ctx context.Context) (int, error) {
+// call sends a single JSON-RPC request and decodes its result into T
+func call[T any](ctx context.Context, c JsonRPCClient, method string, params ...interface{}) (T, error) {
+	var result T
+
+	if params == nil {
+		params = []interface{}{}
+	}
+
 	requestBody := EthereumJSONRPCRequest{
 		JSONRPC: "2.0",
-		Method:  "eth_blockNumber",
-		Params:  []interface{}{},
+		Method:  method,
+		Params:  params,
 		ID:      rand.Int(),
 	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal request body: %w", err)
+		return result, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	err = post(ctx, c, method, jsonData, func(body io.Reader) (string, error) {
+		var rpcResponse EthereumJSONRPCResponse[T]
+		if err := json.NewDecoder(body).Decode(&rpcResponse); err != nil {
+			return callStatusDecodeError, fmt.Errorf("failed to decode response: %w", err)
+		}
+		if rpcResponse.Error != nil {
+			return callStatusRPCError, fmt.Errorf("%s failed: %w", method, rpcResponse.Error)
+		}
+		result = rpcResponse.Result
+		return callStatusOK, nil
+	})
+	return result, err
+}
+
+// callBatch sends requests of the method with each of params in a single JSON-RPC batch and decodes their
+// results in the order of params. A failed request fails the whole batch
+func callBatch[T any](ctx context.Context, c JsonRPCClient, method string, params [][]interface{}) ([]T, error) {
+	requests := make([]EthereumJSONRPCRequest, len(params))
+	for i, p := range params {
+		// ids are positions of requests, responses of a batch may come in any order
+		requests[i] = EthereumJSONRPCRequest{JSONRPC: "2.0", Method: method, Params: p, ID: i}
+	}
+
+	jsonData, err := json.Marshal(requests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
+	results := make([]T, len(params))
+	err = post(ctx, c, method, jsonData, func(body io.Reader) (string, error) {
+		var responses []EthereumJSONRPCResponse[T]
+		if err := json.NewDecoder(body).Decode(&responses); err != nil {
+			return callStatusDecodeError, fmt.Errorf("failed to decode batch response: %w", err)
+		}
+		if len(responses) != len(params) {
+			return callStatusDecodeError, fmt.Errorf("got %d responses to a batch of %d %s requests", len(responses), len(params), method)
+		}
+
+		answered := make([]bool, len(params))
+		for _, response := range responses {
+			if response.ID < 0 || response.ID >= len(params) || answered[response.ID] {
+				return callStatusDecodeError, fmt.Errorf("unexpected response id %d in a batch of %d %s requests", response.ID, len(params), method)
+			}
+			if response.Error != nil {
+				return callStatusRPCError, fmt.Errorf("%s request %d of batch failed: %w", method, response.ID, response.Error)
+			}
+			answered[response.ID] = true
+			results[response.ID] = response.Result
+		}
+		return callStatusOK, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// post sends a request body, retrying transport errors, 429 and 5xx responses, and decodes the response
+// with decode, which returns one of callStatus* values
+func post(ctx context.Context, c JsonRPCClient, method string, jsonData []byte, decode func(body io.Reader) (string, error)) error {
+	backoff := c.retryBackoff
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		status, err := doCall(ctx, c, method, jsonData, decode)
+		rpcRequestDuration.WithLabelValues(strconv.Itoa(c.chainID), method, status).Observe(time.Since(start).Seconds())
+
+		retryable := status == callStatusTransportError || status == callStatusHTTPError
+		if err == nil || !retryable || attempt >= c.retries || ctx.Err() != nil {
+			return err
+		}
+
+		rpcRetries.WithLabelValues(strconv.Itoa(c.chainID), method).Inc()
+		c.log.Warn("retrying json-rpc request", "method", method, "attempt", attempt+1, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// doCall performs a single attempt of the request. Status is one of callStatus* values
+func doCall(ctx context.Context, c JsonRPCClient, method string, jsonData []byte, decode func(body io.Reader) (string, error)) (string, error) {
 	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
-		return 0, fmt.Errorf("failed to create http request: %w", err)
+		return callStatusTransportError, fmt.Errorf("failed to create http request: %w", err)
 	}
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to make eht_blockNumber request: %w", err)
+		return callStatusTransportError, fmt.Errorf("failed to make %s request: %w", method, err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -74,61 +201,214 @@ func (c JsonRPCClient) GetBlockNumber(ctx context.Context) (int, error) {
 		}
 	}()
 
-	var rpcResponse EthereumJSONRPCResponse[string]
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResponse); err != nil {
-		return 0, fmt.Errorf("failed to decode response: %w", err)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+		return callStatusHTTPError, fmt.Errorf("%s request failed with http status %d", method, resp.StatusCode)
+	}
+
+	return decode(resp.Body)
+}
+
+// ChainID fetches id of the chain served by the endpoint
+func (c JsonRPCClient) ChainID(ctx context.Context) (int, error) {
+	result, err := call[string](ctx, c, "eth_chainId")
+	if err != nil {
+		return 0, err
+	}
+
+	chainID, err := ParseQuantity(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse chain id %s : %w", result, err)
+	}
+	return chainID, nil
+}
+
+// VerifyChainID checks the endpoint serves the configured chain, so a misconfigured endpoint
+// does not mix transactions of different chains
+func (c JsonRPCClient) VerifyChainID(ctx context.Context) error {
+	chainID, err := c.ChainID(ctx)
+	if err != nil {
+		return err
+	}
+
+	if chainID != c.chainID {
+		return fmt.Errorf("endpoint %s serves chain %d, expected %d", c.endpoint, chainID, c.chainID)
+	}
+	return nil
+}
+
+// GetBlockNumber fetches the latest block number
+func (c JsonRPCClient) GetBlockNumber(ctx context.Context) (int, error) {
+	result, err := call[string](ctx, c, "eth_blockNumber")
+	if err != nil {
+		return 0, err
 	}
 
-	if rpcResponse.Result == "" {
+	if result == "" {
 		return 0, errors.New("got empty block number")
 	}
 
 	// Convert hex to integer
-	var blockNumber int
-	_, err = fmt.Sscanf(rpcResponse.Result, "0x%x", &blockNumber)
+	blockNumber, err := ParseQuantity(result)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse block number %s : %w", rpcResponse.Result, err)
+		return 0, fmt.Errorf("failed to parse block number %s : %w", result, err)
 	}
 	return blockNumber, nil
 }
 
 const returnFullTx = true
 
+// ErrBlockNotFound is returned for blocks the node answers null for, e.g. a load balanced node whose backend
+// is behind the head reported by another one. The block is to be requested again later
+var ErrBlockNotFound = errors.New("block not found")
+
 // GetBlockByNumber fetches a block and its transactions by block number
 func (c JsonRPCClient) GetBlockByNumber(ctx context.Context, blockNumber int) (EthereumBlock, error) {
-	blockNumberHex := fmt.Sprintf("0x%x", blockNumber)
+	block, err := call[*EthereumBlock](ctx, c, "eth_getBlockByNumber", FormatQuantity(blockNumber), returnFullTx)
+	if err != nil {
+		return EthereumBlock{}, err
+	}
 
-	requestBody := EthereumJSONRPCRequest{
-		JSONRPC: "2.0",
-		Method:  "eth_getBlockByNumber",
-		Params:  []interface{}{blockNumberHex, returnFullTx},
-		ID:      rand.Int(),
+	if block == nil {
+		return EthereumBlock{}, fmt.Errorf("block %d: %w", blockNumber, ErrBlockNotFound)
 	}
+	return *block, nil
+}
 
-	jsonData, err := json.Marshal(requestBody)
+// NewPendingTransactionFilter installs a filter on the node which collects hashes of
+// transactions entering the mempool. Returns the filter id to be used with GetFilterChanges
+func (c JsonRPCClient) NewPendingTransactionFilter(ctx context.Context) (string, error) {
+	filterID, err := call[string](ctx, c, "eth_newPendingTransactionFilter")
 	if err != nil {
-		return EthereumBlock{}, fmt.Errorf("failed to marshal request body: %w", err)
+		return "", err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewBuffer(jsonData))
+	if filterID == "" {
+		return "", errors.New("got empty filter id")
+	}
+	return filterID, nil
+}
+
+// GetFilterChanges returns hashes of pending transactions seen by the filter since the last poll
+func (c JsonRPCClient) GetFilterChanges(ctx context.Context, filterID string) ([]string, error) {
+	return call[[]string](ctx, c, "eth_getFilterChanges", filterID)
+}
+
+// GetTransactionByHash fetches a transaction by its hash. The second return value is false
+// when the node does not know the transaction, e.g. it was dropped from the mempool
+func (c JsonRPCClient) GetTransactionByHash(ctx context.Context, hash string) (Transaction, bool, error) {
+	tx, err := call[*Transaction](ctx, c, "eth_getTransactionByHash", hash)
 	if err != nil {
-		return EthereumBlock{}, fmt.Errorf("failed to create http request: %w", err)
+		return Transaction{}, false, err
 	}
 
-	resp, err := c.http.Do(req)
+	if tx == nil {
+		return Transaction{}, false, nil
+	}
+	return *tx, true, nil
+}
+
+// GetTransactionReceipt fetches the receipt of a mined transaction
+func (c JsonRPCClient) GetTransactionReceipt(ctx context.Context, hash string) (Receipt, error) {
+	receipt, err := call[*Receipt](ctx, c, "eth_getTransactionReceipt", hash)
 	if err != nil {
-		return EthereumBlock{}, err
+		return Receipt{}, err
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			c.log.Error("failed to close http response body", "error", err)
+
+	if receipt == nil {
+		return Receipt{}, fmt.Errorf("receipt of transaction %s not found", hash)
+	}
+	return *receipt, nil
+}
+
+// GetTransactionCount returns the number of transactions sent from an address, i.e. its next nonce,
+// as of the latest mined block
+func (c JsonRPCClient) GetTransactionCount(ctx context.Context, address string) (int, error) {
+	result, err := call[string](ctx, c, "eth_getTransactionCount", address, "latest")
+	if err != nil {
+		return 0, err
+	}
+
+	nonce, err := ParseQuantity(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse transaction count %s : %w", result, err)
+	}
+	return nonce, nil
+}
+
+// GetLogs fetches event logs matching the filter
+func (c JsonRPCClient) GetLogs(ctx context.Context, filter LogFilter) ([]Log, error) {
+	return call[[]Log](ctx, c, "eth_getLogs", filter)
+}
+
+// GetBalance fetches ETH balance of an address in wei as of the block
+func (c JsonRPCClient) GetBalance(ctx context.Context, address string, blockNumber int) (*big.Int, error) {
+	result, err := call[string](ctx, c, "eth_getBalance", address, FormatQuantity(blockNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := ParseBigQuantity(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse balance %s : %w", result, err)
+	}
+	return balance, nil
+}
+
+// GetBalances fetches ETH balances of addresses in wei as of the block with a single batch request,
+// balances are in the order of addresses
+func (c JsonRPCClient) GetBalances(ctx context.Context, addresses []string, blockNumber int) ([]*big.Int, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	params := make([][]interface{}, len(addresses))
+	for i, address := range addresses {
+		params[i] = []interface{}{address, FormatQuantity(blockNumber)}
+	}
+	results, err := callBatch[string](ctx, c, "eth_getBalance", params)
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make([]*big.Int, len(results))
+	for i, result := range results {
+		balance, err := ParseBigQuantity(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse balance %s of %s : %w", result, addresses[i], err)
 		}
-	}()
+		balances[i] = balance
+	}
+	return balances, nil
+}
+
+// Call executes a read-only contract call as of the block and returns hex encoded output
+func (c JsonRPCClient) Call(ctx context.Context, msg CallMsg, blockNumber int) (string, error) {
+	return call[string](ctx, c, "eth_call", msg, FormatQuantity(blockNumber))
+}
+
+// balanceOfSelector is the selector of ERC-20 balanceOf(address)
+var balanceOfSelector = selector("balanceOf(address)")
 
-	var rpcResponse EthereumJSONRPCResponse[EthereumBlock]
-	if err := json.NewDecoder(resp.Body).Decode(&rpcResponse); err != nil {
-		return EthereumBlock{}, fmt.Errorf("failed to decode response: %w", err)
+// GetTokenBalance fetches ERC-20 token balance of an owner as of the block with balanceOf call
+func (c JsonRPCClient) GetTokenBalance(ctx context.Context, token string, owner string, blockNumber int) (*big.Int, error) {
+	// an address argument is abi encoded the same way as an indexed address topic
+	arg, err := AddressTopic(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.Call(ctx, CallMsg{To: token, Data: balanceOfSelector + strings.TrimPrefix(arg, "0x")}, blockNumber)
+	if err != nil {
+		return nil, err
 	}
 
-	return rpcResponse.Result, nil
+	output, err := DecodeHex(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode balanceOf output %s : %w", result, err)
+	}
+	// calls to accounts without code succeed with empty output
+	if len(output) != 32 {
+		return nil, fmt.Errorf("unexpected balanceOf output %s of token %s", result, token)
+	}
+	return new(big.Int).SetBytes(output), nil
 }
@@ -3,12 +3,18 @@ package ethereum
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math/rand"
 	"net/http"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/rpcreplay"
 )
 
 type MockHttpTransport struct {
@@ -19,8 +25,25 @@ func (m *MockHttpTransport) RoundTrip(req *http.Request) (*http.Response, error)
 	return m.DoFunc(req)
 }
 
+// mainnetFixtures are hand-written responses for mainnet block 46147 in the shape nodes return them, they were not
+// recorded. Set rpcreplay.RecordEnv to an endpoint, e.g. with make record-fixtures, to replace them with a recording
+const mainnetFixtures = "testdata/mainnet"
+
+// londonFixtures are recorded responses for mainnet block 12965000, the first block of the London fork,
+// they are written by make record-fixtures and the test of the block is skipped until they are committed
+const londonFixtures = "testdata/london"
+
+// typedFixtures are hand-written responses for a synthetic post-London block with typed transactions, token transfer
+// logs and effective gas prices. They are replayed only, a recording of a live node can not reproduce the synthetic block
+const typedFixtures = "testdata/typed"
+
+// newMainnetClient replays responses of mainnetFixtures
+func newMainnetClient() JsonRPCClient {
+	return NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: rpcreplay.FromEnv(mainnetFixtures)}))
+}
+
 func TestEthClient_GetBlockNumberSmoke(t *testing.T) {
-	client := NewJsonRPCClient(WithHTTPClient(&http.Client{}))
+	client := newMainnetClient()
 
 	blockNumber, err := client.GetBlockNumber(context.Background())
 	if err != nil {
@@ -100,7 +123,7 @@ func TestEthClient_GetBlockNumber(t *testing.T) {
 }
 
 func TestEthClient_GetBlockByNumberSmoke(t *testing.T) {
-	client := NewJsonRPCClient(WithHTTPClient(&http.Client{}))
+	client := newMainnetClient()
 	ctx := context.Background()
 
 	blockNumber, err := client.GetBlockNumber(ctx)
@@ -118,6 +141,131 @@ func TestEthClient_GetBlockByNumberSmoke(t *testing.T) {
 	}
 }
 
+func TestEthClient_MainnetBlock(t *testing.T) {
+	client := newMainnetClient()
+	ctx := context.Background()
+	// block of the first value transfer on mainnet, its receipt predates the status field
+	txHash := "0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"
+
+	if err := client.VerifyChainID(ctx); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	block, err := client.GetBlockByNumber(ctx, 46147)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(block.Transactions) != 1 {
+		t.Fatalf("expected a single transaction, got %+v", block.Transactions)
+	}
+	tx := block.Transactions[0]
+	if tx.Hash != txHash || tx.From != "0xa1e4380a3b1f749673e270229993ee55f35663b4" ||
+		tx.To != "0x5df9b87991262f6ba471f09758cde1c0fc1de734" || tx.Value != "0x7a69" || tx.BlockNumber != "0xb443" {
+		t.Fatalf("unexpected transaction %+v", tx)
+	}
+	if blockTime, err := ParseTimestamp(block.Timestamp); err != nil || blockTime.Year() != 2015 {
+		t.Fatalf("expected a block of 2015, got %s: %v", block.Timestamp, err)
+	}
+
+	receipt, err := client.GetTransactionReceipt(ctx, txHash)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if receipt.GasUsed != "0x5208" || receipt.Status != "" || receipt.Failed() {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+}
+
+func TestEthClient_LondonBlock(t *testing.T) {
+	if _, err := os.Stat(londonFixtures); os.Getenv(rpcreplay.RecordEnv) == "" && errors.Is(err, fs.ErrNotExist) {
+		t.Skipf("no recording of %s, record it with make record-fixtures", londonFixtures)
+	}
+	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: rpcreplay.FromEnv(londonFixtures)}))
+	ctx := context.Background()
+	transferTopic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	blockNumber := "0xc5d488"
+
+	block, err := client.GetBlockByNumber(ctx, 12965000)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(block.Transactions) == 0 {
+		t.Fatal("expected transactions, got none")
+	}
+	for _, tx := range block.Transactions {
+		if tx.BlockNumber != blockNumber || tx.Hash == "" || tx.From == "" {
+			t.Fatalf("unexpected transaction %+v", tx)
+		}
+	}
+
+	// receipts of the fork block carry the effective gas price of EIP-1559
+	receipt, err := client.GetTransactionReceipt(ctx, block.Transactions[0].Hash)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if receipt.BlockNumber != blockNumber || receipt.GasUsed == "" || receipt.EffectiveGasPrice == "" || receipt.Status == "" {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+
+	logs, err := client.GetLogs(ctx, LogFilter{FromBlock: blockNumber, ToBlock: blockNumber, Topics: [][]string{{transferTopic}}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(logs) == 0 {
+		t.Fatal("expected token transfer logs, got none")
+	}
+	for _, log := range logs {
+		if log.BlockNumber != blockNumber || len(log.Topics) == 0 || log.Topics[0] != transferTopic {
+			t.Fatalf("unexpected log %+v", log)
+		}
+	}
+}
+
+func TestEthClient_TypedTransactions(t *testing.T) {
+	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: rpcreplay.NewReplayer(typedFixtures)}))
+	ctx := context.Background()
+	transferTopic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+	block, err := client.GetBlockByNumber(ctx, 0xc5d490)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(block.Transactions) != 3 || block.Transactions[0].Value != "0xde0b6b3a7640000" || block.Transactions[1].Input[:10] != "0xa9059cbb" {
+		t.Fatalf("unexpected transactions %+v", block.Transactions)
+	}
+	if token, _ := DecodeHex("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"); !block.LogsBloom.Test(token) {
+		t.Fatal("expected the token contract in the logs bloom")
+	}
+
+	tests := []struct {
+		hash              string
+		gasUsed           string
+		effectiveGasPrice string
+		failed            bool
+	}{
+		{hash: block.Transactions[0].Hash, gasUsed: "0x5208", effectiveGasPrice: "0x4a817c800"},
+		{hash: block.Transactions[1].Hash, gasUsed: "0xb411", effectiveGasPrice: "0x4a817c800"},
+		{hash: block.Transactions[2].Hash, gasUsed: "0x6d60", effectiveGasPrice: "0x4a817c800", failed: true},
+	}
+	for _, tt := range tests {
+		receipt, err := client.GetTransactionReceipt(ctx, tt.hash)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if receipt.GasUsed != tt.gasUsed || receipt.EffectiveGasPrice != tt.effectiveGasPrice || receipt.Failed() != tt.failed {
+			t.Fatalf("unexpected receipt %+v", receipt)
+		}
+	}
+
+	logs, err := client.GetLogs(ctx, LogFilter{FromBlock: "0xc5d490", ToBlock: "0xc5d490", Topics: [][]string{{transferTopic}}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(logs) != 1 || len(logs[0].Topics) != 3 || logs[0].TransactionHash != block.Transactions[1].Hash || logs[0].LogIndex != "0x0" {
+		t.Fatalf("unexpected logs %+v", logs)
+	}
+}
+
 func TestGetBlockByNumber(t *testing.T) {
 	mockHTTPTransport := &MockHttpTransport{}
 	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}))
@@ -139,6 +287,19 @@ func TestGetBlockByNumber(t *testing.T) {
 		}
 	})
 
+	t.Run("null block", func(t *testing.T) {
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":null}`)),
+			}, nil
+		}
+
+		if _, err := client.GetBlockByNumber(ctx, blockNumber); !errors.Is(err, ErrBlockNotFound) {
+			t.Fatalf("expected block not found, got %v", err)
+		}
+	})
+
 	t.Run("failed to decode response", func(t *testing.T) {
 		response := `invalid json`
 		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
@@ -165,3 +326,343 @@ func TestGetBlockByNumber(t *testing.T) {
 		}
 	})
 }
+
+func TestGetTransactionByHash(t *testing.T) {
+	mockHTTPTransport := &MockHttpTransport{}
+	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}))
+	ctx := context.Background()
+
+	t.Run("pending transaction", func(t *testing.T) {
+		response := `{"jsonrpc":"2.0","id":1,"result":{"hash":"0x1","from":"0xabc","nonce":"0x5","blockNumber":null}}`
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(response)),
+			}, nil
+		}
+
+		tx, found, err := client.GetTransactionByHash(ctx, "0x1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !found {
+			t.Fatalf("expected transaction to be found")
+		}
+		if tx.Hash != "0x1" || tx.Nonce != "0x5" || tx.BlockNumber != "" {
+			t.Fatalf("unexpected transaction %+v", tx)
+		}
+	})
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		response := `{"jsonrpc":"2.0","id":1,"result":null}`
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(response)),
+			}, nil
+		}
+
+		_, found, err := client.GetTransactionByHash(ctx, "0x1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if found {
+			t.Fatalf("expected transaction not to be found")
+		}
+	})
+}
+
+func TestGetFilterChanges(t *testing.T) {
+	mockHTTPTransport := &MockHttpTransport{}
+	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}))
+	ctx := context.Background()
+
+	t.Run("successful response", func(t *testing.T) {
+		response := `{"jsonrpc":"2.0","id":1,"result":["0x1","0x2"]}`
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(response)),
+			}, nil
+		}
+
+		hashes, err := client.GetFilterChanges(ctx, "0xfilter")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(hashes) != 2 {
+			t.Fatalf("expected 2 hashes, got %v", hashes)
+		}
+	})
+
+	t.Run("filter not found", func(t *testing.T) {
+		response := `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"filter not found"}}`
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(response)),
+			}, nil
+		}
+
+		_, err := client.GetFilterChanges(ctx, "0xfilter")
+		var rpcErr *EthereumJSONRPCError
+		if !errors.As(err, &rpcErr) {
+			t.Fatalf("expected json-rpc error, got %v", err)
+		}
+	})
+}
+
+func TestEthClient_Retries(t *testing.T) {
+	mockHTTPTransport := &MockHttpTransport{}
+	client := NewJsonRPCClient(
+		WithHTTPClient(&http.Client{Transport: mockHTTPTransport}),
+		WithRetries(2, time.Millisecond),
+	)
+	ctx := context.Background()
+
+	t.Run("retried until success", func(t *testing.T) {
+		attempts := 0
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, errors.New("connection reset")
+			}
+			if attempts == 2 {
+				return &http.Response{
+					StatusCode: http.StatusTooManyRequests,
+					Body:       io.NopCloser(bytes.NewBufferString("")),
+				}, nil
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":"0x10"}`)),
+			}, nil
+		}
+
+		blockNumber, err := client.GetBlockNumber(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if blockNumber != 0x10 || attempts != 3 {
+			t.Fatalf("expected block 0x10 after 3 attempts, got %d after %d", blockNumber, attempts)
+		}
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		attempts := 0
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return &http.Response{
+				StatusCode: http.StatusServiceUnavailable,
+				Body:       io.NopCloser(bytes.NewBufferString("")),
+			}, nil
+		}
+
+		if _, err := client.GetBlockNumber(ctx); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+		if attempts != 3 {
+			t.Fatalf("expected 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("rpc errors are not retried", func(t *testing.T) {
+		attempts := 0
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"method not found"}}`)),
+			}, nil
+		}
+
+		if _, err := client.GetBlockNumber(ctx); err == nil {
+			t.Fatalf("expected error, got none")
+		}
+		if attempts != 1 {
+			t.Fatalf("expected 1 attempt, got %d", attempts)
+		}
+	})
+}
+
+func TestVerifyChainID(t *testing.T) {
+	mockHTTPTransport := &MockHttpTransport{}
+	mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":"0x89"}`)),
+		}, nil
+	}
+	ctx := context.Background()
+
+	t.Run("matching chain", func(t *testing.T) {
+		client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}), WithChainID(137))
+		if err := client.VerifyChainID(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	})
+
+	t.Run("endpoint serves another chain", func(t *testing.T) {
+		client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}))
+		if err := client.VerifyChainID(ctx); err == nil {
+			t.Fatalf("expected chain mismatch error")
+		}
+	})
+}
+
+func TestGetBalances(t *testing.T) {
+	mockHTTPTransport := &MockHttpTransport{}
+	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}))
+	ctx := context.Background()
+
+	t.Run("eth balance", func(t *testing.T) {
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			var request EthereumJSONRPCRequest
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+			if request.Method != "eth_getBalance" || request.Params[1] != "0x64" {
+				t.Fatalf("unexpected request %+v", request)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				// 20 ETH does not fit into int64 wei
+				Body: io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":"0x1158e460913d00000"}`)),
+			}, nil
+		}
+
+		balance, err := client.GetBalance(ctx, "0x1234567890abcdef1234567890abcdef12345678", 100)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if balance.String() != "20000000000000000000" {
+			t.Fatalf("unexpected balance %s", balance)
+		}
+	})
+
+	t.Run("token balance", func(t *testing.T) {
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			var request struct {
+				Method string            `json:"method"`
+				Params []json.RawMessage `json:"params"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				t.Fatalf("failed to decode request: %v", err)
+			}
+			var msg CallMsg
+			if err := json.Unmarshal(request.Params[0], &msg); err != nil {
+				t.Fatalf("failed to decode call: %v", err)
+			}
+			expectedData := "0x70a08231" + "0000000000000000000000001234567890abcdef1234567890abcdef12345678"
+			if request.Method != "eth_call" || msg.To != "0xtoken" || msg.Data != expectedData {
+				t.Fatalf("unexpected call %s %+v", request.Method, msg)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":"0x00000000000000000000000000000000000000000000000000000000000003e8"}`)),
+			}, nil
+		}
+
+		balance, err := client.GetTokenBalance(ctx, "0xtoken", "0x1234567890abcdef1234567890abcdef12345678", 100)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if balance.Int64() != 1000 {
+			t.Fatalf("unexpected balance %s", balance)
+		}
+	})
+
+	t.Run("token without code", func(t *testing.T) {
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":"0x"}`)),
+			}, nil
+		}
+
+		if _, err := client.GetTokenBalance(ctx, "0xtoken", "0x1234567890abcdef1234567890abcdef12345678", 100); err == nil {
+			t.Fatalf("expected error for empty output")
+		}
+	})
+
+	t.Run("eth balances in a batch", func(t *testing.T) {
+		requests := 0
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			requests++
+			var batch []EthereumJSONRPCRequest
+			if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+				t.Fatalf("failed to decode batch: %v", err)
+			}
+			if len(batch) != 2 || batch[0].Method != "eth_getBalance" || batch[1].Params[0] != "0x2" || batch[1].Params[1] != "0x64" {
+				t.Fatalf("unexpected batch %+v", batch)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				// responses of a batch come in any order
+				Body: io.NopCloser(bytes.NewBufferString(`[{"jsonrpc":"2.0","id":1,"result":"0x2"},{"jsonrpc":"2.0","id":0,"result":"0x1"}]`)),
+			}, nil
+		}
+
+		balances, err := client.GetBalances(ctx, []string{"0x1", "0x2"}, 100)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if requests != 1 || len(balances) != 2 || balances[0].Int64() != 1 || balances[1].Int64() != 2 {
+			t.Fatalf("unexpected balances %v in %d requests", balances, requests)
+		}
+	})
+
+	t.Run("failed request fails the batch", func(t *testing.T) {
+		tests := map[string]string{
+			"rpc error":         `[{"jsonrpc":"2.0","id":0,"result":"0x1"},{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}]`,
+			"missing response":  `[{"jsonrpc":"2.0","id":0,"result":"0x1"}]`,
+			"duplicate id":      `[{"jsonrpc":"2.0","id":0,"result":"0x1"},{"jsonrpc":"2.0","id":0,"result":"0x1"}]`,
+			"single error body": `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batches are not supported"}}`,
+		}
+		for name, body := range tests {
+			mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewBufferString(body))}, nil
+			}
+			if _, err := client.GetBalances(ctx, []string{"0x1", "0x2"}, 100); err == nil {
+				t.Fatalf("%s: expected error", name)
+			}
+		}
+	})
+}
+
+func TestGetTransactionReceipt(t *testing.T) {
+	mockHTTPTransport := &MockHttpTransport{}
+	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}))
+	ctx := context.Background()
+
+	t.Run("reverted transaction", func(t *testing.T) {
+		response := `{"jsonrpc":"2.0","id":1,"result":{"transactionHash":"0x1","blockNumber":"0x10","gasUsed":"0x5208","effectiveGasPrice":"0x2","status":"0x0"}}`
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(response)),
+			}, nil
+		}
+
+		receipt, err := client.GetTransactionReceipt(ctx, "0x1")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !receipt.Failed() || receipt.GasUsed != "0x5208" {
+			t.Fatalf("unexpected receipt %+v", receipt)
+		}
+	})
+
+	t.Run("unknown transaction", func(t *testing.T) {
+		mockHTTPTransport.DoFunc = func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":null}`)),
+			}, nil
+		}
+
+		if _, err := client.GetTransactionReceipt(ctx, "0x1"); err == nil {
+			t.Fatalf("expected error for missing receipt")
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package ethereum
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ENSRegistryAddress is the address of the ENS registry on mainnet
+const ENSRegistryAddress = "0x00000000000c2e074ec69a0dfb2997ba6c7d2e1e"
+
+// ErrNameNotFound is returned for ENS names and addresses without a record
+var ErrNameNotFound = errors.New("ens record not found")
+
+var (
+	ensResolverSelector = selector("resolver(bytes32)")
+	ensAddrSelector     = selector("addr(bytes32)")
+	ensNameSelector     = selector("name(bytes32)")
+)
+
+func selector(signature string) string {
+	return "0x" + hex.EncodeToString(Keccak256([]byte(signature))[:4])
+}
+
+// Namehash computes the ENS node of a name, e.g. vitalik.eth. Names are lower cased,
+// full UTS-46 normalization is not applied
+func Namehash(name string) string {
+	node := make([]byte, 32)
+	if name != "" {
+		labels := strings.Split(strings.ToLower(name), ".")
+		for i := len(labels) - 1; i >= 0; i-- {
+			node = Keccak256(node, Keccak256([]byte(labels[i])))
+		}
+	}
+	return "0x" + hex.EncodeToString(node)
+}
+
+// ResolveName resolves an ENS name to an address with the resolver set for the name in the registry
+func (c JsonRPCClient) ResolveName(ctx context.Context, name string) (string, error) {
+	node := Namehash(name)
+
+	resolver, err := c.ensResolver(ctx, node)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := c.callLatest(ctx, CallMsg{To: resolver, Data: ensAddrSelector + strings.TrimPrefix(node, "0x")})
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", name, err)
+	}
+	address, err := outputAddress(output)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", name, err)
+	}
+	if address == "" {
+		return "", fmt.Errorf("resolve %s: %w", name, ErrNameNotFound)
+	}
+	return address, nil
+}
+
+// LookupAddress finds the primary ENS name of an address with a reverse record. The name is verified
+// to resolve back to the address, as anyone can set any name as their reverse record
+func (c JsonRPCClient) LookupAddress(ctx context.Context, address string) (string, error) {
+	node := Namehash(strings.TrimPrefix(strings.ToLower(address), "0x") + ".addr.reverse")
+
+	resolver, err := c.ensResolver(ctx, node)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := c.callLatest(ctx, CallMsg{To: resolver, Data: ensNameSelector + strings.TrimPrefix(node, "0x")})
+	if err != nil {
+		return "", fmt.Errorf("lookup %s: %w", address, err)
+	}
+	name, err := outputString(output)
+	if err != nil {
+		return "", fmt.Errorf("lookup %s: %w", address, err)
+	}
+	if name == "" {
+		return "", fmt.Errorf("lookup %s: %w", address, ErrNameNotFound)
+	}
+
+	resolved, err := c.ResolveName(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !strings.EqualFold(resolved, address) {
+		return "", fmt.Errorf("lookup %s: name %s resolves to %s: %w", address, name, resolved, ErrNameNotFound)
+	}
+	return name, nil
+}
+
+// ensResolver returns the resolver contract set for the node in the registry
+func (c JsonRPCClient) ensResolver(ctx context.Context, node string) (string, error) {
+	output, err := c.callLatest(ctx, CallMsg{To: ENSRegistryAddress, Data: ensResolverSelector + strings.TrimPrefix(node, "0x")})
+	if err != nil {
+		return "", fmt.Errorf("load ens resolver: %w", err)
+	}
+	resolver, err := outputAddress(output)
+	if err != nil {
+		return "", fmt.Errorf("load ens resolver: %w", err)
+	}
+	if resolver == "" {
+		return "", ErrNameNotFound
+	}
+	return resolver, nil
+}
+
+func (c JsonRPCClient) callLatest(ctx context.Context, msg CallMsg) (string, error) {
+	return call[string](ctx, c, "eth_call", msg, "latest")
+}
+
+// outputAddress decodes an address returned by a call, empty for the zero address
+func outputAddress(output string) (string, error) {
+	data, err := DecodeHex(output)
+	if err != nil || len(data) != 32 {
+		return "", fmt.Errorf("unexpected address output %q", output)
+	}
+	if new(big.Int).SetBytes(data).Sign() == 0 {
+		return "", nil
+	}
+	return "0x" + hex.EncodeToString(data[12:]), nil
+}
+
+// outputString decodes a string returned by a call
+func outputString(output string) (string, error) {
+	data, err := DecodeHex(output)
+	if err != nil || len(data) < 64 {
+		return "", fmt.Errorf("unexpected string output %q", output)
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsInt64() || offset.Int64()+32 > int64(len(data)) {
+		return "", fmt.Errorf("unexpected string output %q", output)
+	}
+	start := int(offset.Int64()) + 32
+	length := new(big.Int).SetBytes(data[start-32 : start])
+	if !length.IsInt64() || int64(start)+length.Int64() > int64(len(data)) {
+		return "", fmt.Errorf("unexpected string output %q", output)
+	}
+	return string(data[start : start+int(length.Int64())]), nil
+}
@@ -0,0 +1,89 @@
+package ethereum
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestNamehash(t *testing.T) {
+	tests := map[string]string{
+		"":        "0x0000000000000000000000000000000000000000000000000000000000000000",
+		"eth":     "0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae",
+		"foo.eth": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+		"Foo.ETH": "0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f",
+	}
+	for name, expected := range tests {
+		if node := Namehash(name); node != expected {
+			t.Fatalf("namehash(%q): expected %s, got %s", name, expected, node)
+		}
+	}
+}
+
+func TestENS(t *testing.T) {
+	resolver := "0x4976fb03c32e5b8cfe2b6ccb31c09ba78ebaba41"
+	owner := "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+	word := func(hex string) string {
+		return strings.Repeat("0", 64-len(hex)) + hex
+	}
+	nameOutput := "0x" + word("20") + word("b") + "766974616c696b2e657468" + strings.Repeat("0", 42)
+
+	// call data -> output
+	outputs := map[string]string{
+		ENSRegistryAddress + ensResolverSelector + strings.TrimPrefix(Namehash("vitalik.eth"), "0x"):             "0x" + word(resolver[2:]),
+		resolver + ensAddrSelector + strings.TrimPrefix(Namehash("vitalik.eth"), "0x"):                           "0x" + word(owner[2:]),
+		ENSRegistryAddress + ensResolverSelector + strings.TrimPrefix(Namehash(owner[2:]+".addr.reverse"), "0x"): "0x" + word(resolver[2:]),
+		resolver + ensNameSelector + strings.TrimPrefix(Namehash(owner[2:]+".addr.reverse"), "0x"):               nameOutput,
+		ENSRegistryAddress + ensResolverSelector + strings.TrimPrefix(Namehash("missing.eth"), "0x"):             "0x" + word(""),
+	}
+	mockHTTPTransport := &MockHttpTransport{DoFunc: func(req *http.Request) (*http.Response, error) {
+		var request struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&request)
+		var msg CallMsg
+		_ = json.Unmarshal(request.Params[0], &msg)
+
+		output, ok := outputs[msg.To+msg.Data]
+		if !ok {
+			t.Fatalf("unexpected call %+v", msg)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"jsonrpc":"2.0","id":1,"result":"` + output + `"}`)),
+		}, nil
+	}}
+	client := NewJsonRPCClient(WithHTTPClient(&http.Client{Transport: mockHTTPTransport}))
+	ctx := context.Background()
+
+	t.Run("resolve name", func(t *testing.T) {
+		address, err := client.ResolveName(ctx, "vitalik.eth")
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if address != owner {
+			t.Fatalf("expected %s, got %s", owner, address)
+		}
+	})
+
+	t.Run("name without resolver", func(t *testing.T) {
+		if _, err := client.ResolveName(ctx, "missing.eth"); !errors.Is(err, ErrNameNotFound) {
+			t.Fatalf("expected ErrNameNotFound, got %v", err)
+		}
+	})
+
+	t.Run("reverse lookup", func(t *testing.T) {
+		name, err := client.LookupAddress(ctx, owner)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if name != "vitalik.eth" {
+			t.Fatalf("expected vitalik.eth, got %s", name)
+		}
+	})
+}
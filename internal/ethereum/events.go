@@ -0,0 +1,79 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// maxTopics is the number of indexed topics a log can have, including the event signature
+const maxTopics = 4
+
+// EventFilter selects logs of a contract by topics. Empty address matches any contract,
+// an empty topic matches any value at its position
+type EventFilter struct {
+	// derived from the filter, so subscribing the same filter twice is idempotent
+	ID      string   `json:"id"`
+	Address string   `json:"address,omitempty"`
+	Topics  []string `json:"topics,omitempty"`
+}
+
+// Normalize validates the filter, lower cases it and assigns its id
+func (f EventFilter) Normalize() (EventFilter, error) {
+	f.Address = strings.ToLower(f.Address)
+	if f.Address != "" {
+		decoded, err := DecodeHex(f.Address)
+		if err != nil || len(decoded) != 20 {
+			return EventFilter{}, fmt.Errorf("invalid contract address %q", f.Address)
+		}
+	}
+
+	if len(f.Topics) > maxTopics {
+		return EventFilter{}, fmt.Errorf("expected at most %d topics, got %d", maxTopics, len(f.Topics))
+	}
+	topics := make([]string, len(f.Topics))
+	for i, topic := range f.Topics {
+		topics[i] = strings.ToLower(topic)
+		if topic == "" {
+			continue
+		}
+		decoded, err := DecodeHex(topic)
+		if err != nil || len(decoded) != 32 {
+			return EventFilter{}, fmt.Errorf("invalid topic %q: expected 32 bytes hex", topic)
+		}
+	}
+	// trailing wildcards do not change matching
+	for len(topics) > 0 && topics[len(topics)-1] == "" {
+		topics = topics[:len(topics)-1]
+	}
+	f.Topics = topics
+
+	if f.Address == "" && len(f.Topics) == 0 {
+		return EventFilter{}, fmt.Errorf("filter matches every log, expected contract address or topics")
+	}
+
+	f.ID = hex.EncodeToString(Keccak256([]byte(f.Address + "/" + strings.Join(f.Topics, ",")))[:8])
+	return f, nil
+}
+
+// LogFilter returns the eth_getLogs filter for a block range
+func (f EventFilter) LogFilter(fromBlock, toBlock int) LogFilter {
+	filter := LogFilter{FromBlock: FormatQuantity(fromBlock), ToBlock: FormatQuantity(toBlock)}
+	if f.Address != "" {
+		filter.Address = []string{f.Address}
+	}
+	for _, topic := range f.Topics {
+		if topic == "" {
+			filter.Topics = append(filter.Topics, nil)
+			continue
+		}
+		filter.Topics = append(filter.Topics, []string{topic})
+	}
+	return filter
+}
+
+// Event is a log matched by an event filter
+type Event struct {
+	FilterID string `json:"filterId"`
+	Log
+}
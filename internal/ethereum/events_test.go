@@ -0,0 +1,52 @@
+package ethereum
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEventFilter(t *testing.T) {
+	topic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	contract := "0xdac17f958d2ee523a2206206994597c13d831ec7"
+
+	t.Run("id does not depend on case and trailing wildcards", func(t *testing.T) {
+		a, err := EventFilter{Address: contract, Topics: []string{topic}}.Normalize()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		b, err := EventFilter{Address: "0xDAC17F958D2EE523A2206206994597C13D831EC7", Topics: []string{topic, "", ""}}.Normalize()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if a.ID == "" || a.ID != b.ID {
+			t.Fatalf("expected equal ids, got %s and %s", a.ID, b.ID)
+		}
+	})
+
+	t.Run("wildcard topic", func(t *testing.T) {
+		f, err := EventFilter{Topics: []string{topic, "", topic}}.Normalize()
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		data, _ := json.Marshal(f.LogFilter(1, 100))
+		expected := `{"fromBlock":"0x1","toBlock":"0x64","topics":[["` + topic + `"],null,["` + topic + `"]]}`
+		if string(data) != expected {
+			t.Fatalf("expected %s, got %s", expected, data)
+		}
+	})
+
+	invalid := map[string]EventFilter{
+		"matches everything": {},
+		"invalid address":    {Address: "0x01"},
+		"invalid topic":      {Topics: []string{"0x01"}},
+		"too many topics":    {Topics: []string{topic, topic, topic, topic, topic}},
+	}
+	for name, f := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := f.Normalize(); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
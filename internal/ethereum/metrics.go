@@ -0,0 +1,34 @@
+package ethereum
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "eth_tx_parser"
+
+// json-rpc call outcomes used as status label values
+const (
+	callStatusOK             = "ok"
+	callStatusTransportError = "transport_error"
+	callStatusHTTPError      = "http_error"
+	callStatusDecodeError    = "decode_error"
+	callStatusRPCError       = "rpc_error"
+)
+
+var (
+	rpcRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rpc",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of Ethereum JSON-RPC requests by chain, method and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"chain_id", "method", "status"})
+
+	rpcRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "rpc",
+		Name:      "retries_total",
+		Help:      "Number of retried Ethereum JSON-RPC requests by chain and method.",
+	}, []string{"chain_id", "method"})
+)
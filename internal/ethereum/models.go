@@ -1,5 +1,10 @@
 package ethereum
 
+import (
+	"fmt"
+	"math/big"
+)
+
 // EthereumJSONRPCRequest models JSON-RPC requests
 type EthereumJSONRPCRequest struct {
 	JSONRPC string        `json:"jsonrpc"`
@@ -10,11 +15,36 @@ type EthereumJSONRPCRequest struct {
 
 // EthereumJSONRPCResponse models JSON-RPC responses
 type EthereumJSONRPCResponse[T any] struct {
-	JSONRPC string `json:"jsonrpc"`
-	ID      int    `json:"id"`
-	Result  T      `json:"result"`
+	JSONRPC string                `json:"jsonrpc"`
+	ID      int                   `json:"id"`
+	Result  T                     `json:"result"`
+	Error   *EthereumJSONRPCError `json:"error,omitempty"`
+}
+
+// EthereumJSONRPCError models JSON-RPC error object returned by the node
+type EthereumJSONRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
 }
 
+func (e *EthereumJSONRPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// TransactionStatus is the lifecycle state of an observed transaction
+type TransactionStatus string
+
+const (
+	// TransactionStatusPending transaction was seen in the mempool but is not mined yet
+	TransactionStatusPending TransactionStatus = "pending"
+	// TransactionStatusMined transaction is included in a block
+	TransactionStatusMined TransactionStatus = "mined"
+	// TransactionStatusReplaced another transaction with the same sender and nonce was mined instead
+	TransactionStatusReplaced TransactionStatus = "replaced"
+	// TransactionStatusDropped transaction disappeared from the mempool without being mined
+	TransactionStatusDropped TransactionStatus = "dropped"
+)
+
 // Transaction represents an Ethereum transaction
 type Transaction struct {
 	From string `json:"from"`
@@ -22,9 +52,114 @@ type Transaction struct {
 	// amount of ETH to transfer from sender to recipient (denominated in WEI, where 1ETH equals 1e+18wei)
 	Value string `json:"value"`
 	Hash  string `json:"hash"`
+	Nonce string `json:"nonce"`
+	// call data, empty for plain ETH transfers and token transfers
+	Input string `json:"input,omitempty"`
+	// empty for transactions which are not mined yet
+	BlockNumber string `json:"blockNumber"`
+	// unix time quantity of the block, filled in by the service for mined transactions
+	BlockTimestamp string `json:"blockTimestamp,omitempty"`
+	// index of the event log a transfer is derived from, empty for plain transactions
+	LogIndex string `json:"logIndex,omitempty"`
+	// contract address of the transferred token, empty for plain ETH transactions
+	Token string `json:"token,omitempty"`
+	// not part of the JSON-RPC model, filled in by the service
+	Status TransactionStatus `json:"status,omitempty"`
+	// taken from the receipt of a mined plain transaction, sender pays GasUsed * EffectiveGasPrice in fees
+	GasUsed           string `json:"gasUsed,omitempty"`
+	EffectiveGasPrice string `json:"effectiveGasPrice,omitempty"`
+	// reverted transactions pay fees but do not transfer value
+	Failed bool `json:"failed,omitempty"`
+}
+
+// Fee returns wei paid by the sender for a transaction, false if the receipt was not recorded
+func (tx Transaction) Fee() (*big.Int, bool) {
+	gasUsed, err := ParseBigQuantity(tx.GasUsed)
+	if err != nil {
+		return nil, false
+	}
+	gasPrice, err := ParseBigQuantity(tx.EffectiveGasPrice)
+	if err != nil {
+		return nil, false
+	}
+	return gasUsed.Mul(gasUsed, gasPrice), true
+}
+
+const receiptStatusFailure = "0x0"
+
+// Receipt is the outcome of a mined transaction
+type Receipt struct {
+	TransactionHash   string `json:"transactionHash"`
+	BlockNumber       string `json:"blockNumber"`
+	GasUsed           string `json:"gasUsed"`
+	EffectiveGasPrice string `json:"effectiveGasPrice"`
+	// 0x1 for success, 0x0 for reverted transactions. Empty for pre-Byzantium receipts
+	Status string `json:"status"`
+}
+
+// Failed reports whether the transaction was reverted
+func (r Receipt) Failed() bool {
+	return r.Status == receiptStatusFailure
+}
+
+// AddressTx is a transaction stored for one of its subscribed participants
+type AddressTx struct {
+	Address string
+	Tx      Transaction
 }
 
 // EthereumBlock represents an Ethereum block
 type EthereumBlock struct {
+	Number       string        `json:"number"`
+	LogsBloom    Bloom         `json:"logsBloom"`
 	Transactions []Transaction `json:"transactions"`
+	// unix time quantity, e.g. "0x6554d1c7"
+	Timestamp string `json:"timestamp"`
+}
+
+// Log represents an event log emitted by a contract
+type Log struct {
+	// contract which emitted the log
+	Address         string   `json:"address"`
+	Topics          []string `json:"topics"`
+	Data            string   `json:"data"`
+	BlockNumber     string   `json:"blockNumber"`
+	TransactionHash string   `json:"transactionHash"`
+	LogIndex        string   `json:"logIndex"`
+	// true when the log was reverted by a chain reorganization
+	Removed bool `json:"removed"`
+}
+
+// LogFilter selects logs for eth_getLogs. Topics are matched by position,
+// each position accepts any of the listed values and an empty position matches anything
+type LogFilter struct {
+	FromBlock string     `json:"fromBlock,omitempty"`
+	ToBlock   string     `json:"toBlock,omitempty"`
+	Address   []string   `json:"address,omitempty"`
+	Topics    [][]string `json:"topics,omitempty"`
+}
+
+// Balances of an address as of Block. Amounts are decimal strings in the smallest units, i.e. wei for ETH
+type Balances struct {
+	Address string `json:"address"`
+	Block   int    `json:"block"`
+	ETH     string `json:"eth"`
+	// token contract address -> balance
+	Tokens map[string]string `json:"tokens,omitempty"`
+}
+
+// BalanceDiscrepancy is a mismatch between ETH balance reconstructed from indexed transactions
+// and the one reported by the node, e.g. caused by internal transfers which are not indexed
+type BalanceDiscrepancy struct {
+	Address string `json:"address"`
+	// balances are compared as of the block
+	Block         int    `json:"block"`
+	Reconstructed string `json:"reconstructed"`
+	Actual        string `json:"actual"`
+}
+
+// CallMsg is a read-only contract call executed by eth_call
+type CallMsg struct {
+	To   string `json:"to"`
+	Data string `json:"data"`
 }
@@ -0,0 +1,78 @@
+package ethereum
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseQuantity parses a hex encoded JSON-RPC quantity, e.g. "0x10d4f"
+func ParseQuantity(s string) (int, error) {
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok || digits == "" {
+		return 0, fmt.Errorf("invalid quantity %q: expected 0x prefixed hex", s)
+	}
+
+	n, err := strconv.ParseInt(digits, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q: %w", s, err)
+	}
+	return int(n), nil
+}
+
+// FormatQuantity encodes a number as a JSON-RPC quantity
+func FormatQuantity(n int) string {
+	return fmt.Sprintf("0x%x", n)
+}
+
+// ParseBigQuantity parses a hex encoded JSON-RPC quantity which may not fit into int, e.g. wei amounts
+func ParseBigQuantity(s string) (*big.Int, error) {
+	digits, ok := strings.CutPrefix(s, "0x")
+	if !ok || digits == "" {
+		return nil, fmt.Errorf("invalid quantity %q: expected 0x prefixed hex", s)
+	}
+
+	n, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid quantity %q", s)
+	}
+	return n, nil
+}
+
+// ParseAddress validates a 0x prefixed 20 bytes hex address and lower cases it
+func ParseAddress(s string) (string, error) {
+	decoded, err := DecodeHex(s)
+	if err != nil || len(decoded) != 20 {
+		return "", fmt.Errorf("invalid address %q: expected 20 bytes hex", s)
+	}
+	return strings.ToLower(s), nil
+}
+
+// weiPerEther is the number of wei in 1 ETH
+var weiPerEther = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// FormatEther formats wei as an exact decimal amount of ETH, e.g. "1.5" for 1500000000000000000
+func FormatEther(wei *big.Int) string {
+	quotient, remainder := new(big.Int).QuoRem(new(big.Int).Abs(wei), weiPerEther, new(big.Int))
+	sign := ""
+	if wei.Sign() < 0 {
+		sign = "-"
+	}
+	if remainder.Sign() == 0 {
+		return sign + quotient.String()
+	}
+	digits := remainder.String()
+	fraction := strings.TrimRight(strings.Repeat("0", 18-len(digits))+digits, "0")
+	return sign + quotient.String() + "." + fraction
+}
+
+// ParseTimestamp parses a unix time quantity of a block
+func ParseTimestamp(s string) (time.Time, error) {
+	seconds, err := ParseQuantity(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+	}
+	return time.Unix(int64(seconds), 0).UTC(), nil
+}
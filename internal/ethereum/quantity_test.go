@@ -0,0 +1,42 @@
+package ethereum
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestFormatEther(t *testing.T) {
+	tests := []struct {
+		wei      string
+		expected string
+	}{
+		{wei: "0", expected: "0"},
+		{wei: "1", expected: "0.000000000000000001"},
+		{wei: "1500000000000000000", expected: "1.5"},
+		{wei: "2000000000000000000", expected: "2"},
+		{wei: "-21000000000000", expected: "-0.000021"},
+		{wei: "123456789012345678901234567890", expected: "123456789012.34567890123456789"},
+	}
+
+	for _, tt := range tests {
+		wei, _ := new(big.Int).SetString(tt.wei, 10)
+		if got := FormatEther(wei); got != tt.expected {
+			t.Errorf("expected %s wei to be %s ETH, got %s", tt.wei, tt.expected, got)
+		}
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	got, err := ParseTimestamp("0x6554d1c7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := time.Date(2023, 11, 15, 14, 12, 23, 0, time.UTC); !got.Equal(expected) {
+		t.Fatalf("expected %s, got %s", expected, got)
+	}
+
+	if _, err := ParseTimestamp("1700057543"); err == nil {
+		t.Fatal("expected error for decimal timestamp")
+	}
+}
@@ -0,0 +1,592 @@
+// Package fakenode is an in-process Ethereum JSON-RPC node for tests. It keeps a chain of blocks mined on demand
+// or on a timer, and simulates what real nodes do to their clients: reorgs, lagging backends, rate limits and failures.
+//
+// Blocks carry plain transactions only, their logs blooms are empty, so clients never ask for token transfers.
+// Receipts are made up from transactions: 21000 gas at 1 gwei unless the transaction sets GasUsed and
+// EffectiveGasPrice, reverted when the transaction has Failed set.
+package fakenode
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+const (
+	defaultHead = 100
+	// time of block 0, every next block is 12 seconds later
+	genesisTime = 1700000000
+	blockTime   = 12
+
+	defaultGasUsed  = "0x5208"
+	defaultGasPrice = "0x3b9aca00"
+)
+
+// emptyBloom matches no logs, 256 zero bytes
+var emptyBloom = "0x" + strings.Repeat("00", 256)
+
+type Option func(*Node)
+
+// WithChainID sets the chain id reported by eth_chainId, 1 by default
+func WithChainID(chainID int) Option {
+	return func(n *Node) {
+		n.chainID = chainID
+	}
+}
+
+// WithHead starts the chain with empty blocks up to head, 100 by default
+func WithHead(head int) Option {
+	return func(n *Node) {
+		n.head = head
+	}
+}
+
+// WithBlockTime mines a block with pending transactions every interval, blocks are mined only by Mine by default
+func WithBlockTime(interval time.Duration) Option {
+	return func(n *Node) {
+		n.blockTime = interval
+	}
+}
+
+// Node is a fake node served on URL until Close
+type Node struct {
+	URL string
+
+	server    *httptest.Server
+	chainID   int
+	head      int
+	blockTime time.Duration
+	stop      chan struct{}
+	mining    sync.WaitGroup
+
+	mu sync.Mutex
+	// blocks by number
+	blocks []*block
+	// forks counts reorgs, so blocks mined in place of dropped ones get other hashes
+	forks   int
+	pending []ethereum.Transaction
+	// hashes of transactions sent since the last poll of the pending transactions filter
+	filter []string
+	// head reported while paused, -1 otherwise
+	pausedHead int
+	// last block served while lagging, -1 otherwise
+	laggingHead int
+
+	rateLimit   int
+	rateWindow  time.Duration
+	windowStart time.Time
+	// requests served in the current rate limit window
+	windowRequests int
+	rateLimited    int
+	// method -> http statuses of the next failed responses
+	failures map[string][]int
+	// method -> served requests
+	requests map[string]int
+}
+
+type block struct {
+	hash       string
+	parentHash string
+	txs        []ethereum.Transaction
+}
+
+// New starts a node, Close stops it
+func New(options ...Option) *Node {
+	n := &Node{
+		chainID:     ethereum.MainnetChainID,
+		head:        defaultHead,
+		stop:        make(chan struct{}),
+		pausedHead:  -1,
+		laggingHead: -1,
+		failures:    make(map[string][]int),
+		requests:    make(map[string]int),
+	}
+	for _, option := range options {
+		option(n)
+	}
+
+	for i := 0; i <= n.head; i++ {
+		n.mineLocked(nil)
+	}
+	n.server = httptest.NewServer(http.HandlerFunc(n.serve))
+	n.URL = n.server.URL
+
+	if n.blockTime > 0 {
+		n.mining.Add(1)
+		go n.mineEvery(n.blockTime)
+	}
+	return n
+}
+
+// Close stops mining and serving requests
+func (n *Node) Close() {
+	close(n.stop)
+	n.mining.Wait()
+	n.server.Close()
+}
+
+func (n *Node) mineEvery(interval time.Duration) {
+	defer n.mining.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.stop:
+			return
+		case <-ticker.C:
+			n.Mine()
+		}
+	}
+}
+
+// Send adds transactions to the mempool, they are included into the next mined block
+func (n *Node) Send(txs ...ethereum.Transaction) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, tx := range txs {
+		tx = n.withHash(tx, "pending", len(n.blocks), len(n.pending))
+		n.pending = append(n.pending, tx)
+		n.filter = append(n.filter, tx.Hash)
+	}
+}
+
+// Mine mines a block with pending transactions and txs and returns its number.
+// Transactions without a hash get a generated one
+func (n *Node) Mine(txs ...ethereum.Transaction) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	included := append(n.pending, txs...)
+	n.pending = nil
+	return n.mineLocked(included)
+}
+
+func (n *Node) mineLocked(txs []ethereum.Transaction) int {
+	number := len(n.blocks)
+	b := &block{hash: hash("block", n.chainID, number, n.forks), parentHash: n.lastHash()}
+	for i, tx := range txs {
+		tx = n.withHash(tx, "mined", number, i)
+		tx.BlockNumber = ethereum.FormatQuantity(number)
+		b.txs = append(b.txs, tx)
+	}
+	n.blocks = append(n.blocks, b)
+	return number
+}
+
+// lastHash returns the hash of the last block
+func (n *Node) lastHash() string {
+	if len(n.blocks) == 0 {
+		return "0x" + strings.Repeat("0", 64)
+	}
+	return n.blocks[len(n.blocks)-1].hash
+}
+
+// withHash generates a hash of a transaction without one, unique across sent and mined transactions
+func (n *Node) withHash(tx ethereum.Transaction, kind string, number, index int) ethereum.Transaction {
+	if tx.Hash == "" {
+		tx.Hash = hash(kind, n.chainID, number, index, n.forks)
+	}
+	return tx
+}
+
+// Reorg drops the last depth blocks as if a fork without them won and returns their transactions,
+// which are not sent again. Blocks mined afterwards get other hashes than the dropped ones
+func (n *Node) Reorg(depth int) []ethereum.Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	depth = min(depth, len(n.blocks)-1)
+	var dropped []ethereum.Transaction
+	for _, b := range n.blocks[len(n.blocks)-depth:] {
+		for _, tx := range b.txs {
+			tx.BlockNumber = ""
+			dropped = append(dropped, tx)
+		}
+	}
+	n.blocks = n.blocks[:len(n.blocks)-depth]
+	n.forks++
+	return dropped
+}
+
+// Pause keeps reporting the current head while blocks are mined, so they become visible at once on Resume.
+// Blocks above the reported head are not served
+func (n *Node) Pause() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.pausedHead < 0 {
+		n.pausedHead = len(n.blocks) - 1
+	}
+}
+
+// Resume reports the actual head again
+func (n *Node) Resume() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.pausedHead = -1
+}
+
+// Lag keeps reporting mined blocks in the head while answering null for blocks mined from now on, like a load
+// balanced node whose backend is behind the head another backend reported
+func (n *Node) Lag() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.laggingHead < 0 {
+		n.laggingHead = len(n.blocks) - 1
+	}
+}
+
+// CatchUp serves blocks mined while lagging
+func (n *Node) CatchUp() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.laggingHead = -1
+}
+
+// Head returns the number of the last mined block
+func (n *Node) Head() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return len(n.blocks) - 1
+}
+
+// SetRateLimit answers requests over limit in a window with 429 Too Many Requests, a zero limit disables it
+func (n *Node) SetRateLimit(limit int, window time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.rateLimit = limit
+	n.rateWindow = window
+	n.windowStart = time.Time{}
+	n.windowRequests = 0
+}
+
+// FailNext fails the next times requests of method. Failed responses are JSON-RPC errors when status
+// is 200 OK, and responses with the status and no JSON-RPC body otherwise
+func (n *Node) FailNext(method string, times int, status int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for range times {
+		n.failures[method] = append(n.failures[method], status)
+	}
+}
+
+// Requests returns the number of requests of method, including rate limited and failed ones
+func (n *Node) Requests(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.requests[method]
+}
+
+// RateLimited returns the number of requests answered with 429 Too Many Requests
+func (n *Node) RateLimited() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.rateLimited
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (n *Node) serve(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// a batch is answered with an array of responses, a single request with a single response
+	batched := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	var batch []rpcRequest
+	if batched {
+		err = json.Unmarshal(body, &batch)
+	} else {
+		batch = make([]rpcRequest, 1)
+		err = json.Unmarshal(body, &batch[0])
+	}
+	if err != nil {
+		writeJSON(w, response(nil, nil, &rpcError{Code: -32700, Message: "parse error"}))
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for _, req := range batch {
+		n.requests[req.Method]++
+	}
+	if n.limited() {
+		n.rateLimited++
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+		return
+	}
+
+	responses := make([]map[string]any, 0, len(batch))
+	for _, req := range batch {
+		if failures := n.failures[req.Method]; len(failures) > 0 {
+			status := failures[0]
+			n.failures[req.Method] = failures[1:]
+			// an http failure fails the whole batch, like a failing node or proxy in front of it does
+			if status != http.StatusOK {
+				http.Error(w, http.StatusText(status), status)
+				return
+			}
+			responses = append(responses, response(req.ID, nil, &rpcError{Code: -32000, Message: "simulated failure"}))
+			continue
+		}
+
+		result, rpcErr := n.handle(req)
+		responses = append(responses, response(req.ID, result, rpcErr))
+	}
+
+	if batched {
+		writeJSON(w, responses)
+		return
+	}
+	writeJSON(w, responses[0])
+}
+
+// limited counts the request in the current window and reports whether it is over the limit
+func (n *Node) limited() bool {
+	if n.rateLimit <= 0 {
+		return false
+	}
+	now := time.Now()
+	if now.Sub(n.windowStart) >= n.rateWindow {
+		n.windowStart = now
+		n.windowRequests = 0
+	}
+	n.windowRequests++
+	return n.windowRequests > n.rateLimit
+}
+
+func response(id json.RawMessage, result any, rpcErr *rpcError) map[string]any {
+	response := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		response["error"] = rpcErr
+	} else {
+		response["result"] = result
+	}
+	return response
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (n *Node) handle(req rpcRequest) (any, *rpcError) {
+	switch req.Method {
+	case "eth_chainId":
+		return ethereum.FormatQuantity(n.chainID), nil
+	case "eth_blockNumber":
+		return ethereum.FormatQuantity(n.reportedHead()), nil
+	case "eth_getBlockByNumber":
+		number, err := n.blockNumberParam(req)
+		if err != nil {
+			return nil, err
+		}
+		return n.blockJSON(number), nil
+	case "eth_getTransactionReceipt":
+		var txHash string
+		if err := stringParam(req, &txHash); err != nil {
+			return nil, err
+		}
+		return n.receipt(txHash), nil
+	case "eth_getTransactionByHash":
+		var txHash string
+		if err := stringParam(req, &txHash); err != nil {
+			return nil, err
+		}
+		return n.transaction(txHash), nil
+	case "eth_getTransactionCount":
+		var address string
+		if err := stringParam(req, &address); err != nil {
+			return nil, err
+		}
+		return ethereum.FormatQuantity(n.nonce(address)), nil
+	case "eth_getBalance":
+		return "0x0", nil
+	case "eth_getLogs":
+		return []any{}, nil
+	case "eth_newPendingTransactionFilter":
+		n.filter = nil
+		return "0x1", nil
+	case "eth_getFilterChanges":
+		hashes := append([]string{}, n.filter...)
+		n.filter = nil
+		return hashes, nil
+	default:
+		return nil, &rpcError{Code: -32601, Message: fmt.Sprintf("the method %s does not exist/is not available", req.Method)}
+	}
+}
+
+func (n *Node) reportedHead() int {
+	// a reorg while paused can drop blocks below the paused head
+	if n.pausedHead >= 0 {
+		return min(n.pausedHead, len(n.blocks)-1)
+	}
+	return len(n.blocks) - 1
+}
+
+func stringParam(req rpcRequest, v *string) *rpcError {
+	if len(req.Params) == 0 || json.Unmarshal(req.Params[0], v) != nil {
+		return &rpcError{Code: -32602, Message: "invalid params"}
+	}
+	return nil
+}
+
+func (n *Node) blockNumberParam(req rpcRequest) (int, *rpcError) {
+	var tag string
+	if err := stringParam(req, &tag); err != nil {
+		return 0, err
+	}
+	if tag == "latest" {
+		return n.reportedHead(), nil
+	}
+	number, err := ethereum.ParseQuantity(tag)
+	if err != nil {
+		return 0, &rpcError{Code: -32602, Message: "invalid block number"}
+	}
+	return number, nil
+}
+
+// visibleBlock returns a block up to the reported head, nil for unknown blocks and blocks a lagging node
+// does not serve yet
+func (n *Node) visibleBlock(number int) *block {
+	if number < 0 || number > n.reportedHead() || (n.laggingHead >= 0 && number > n.laggingHead) {
+		return nil
+	}
+	return n.blocks[number]
+}
+
+func (n *Node) blockJSON(number int) any {
+	b := n.visibleBlock(number)
+	if b == nil {
+		return nil
+	}
+	txs := make([]ethereum.Transaction, 0, len(b.txs))
+	for _, tx := range b.txs {
+		txs = append(txs, nodeTransaction(tx))
+	}
+	return map[string]any{
+		"number":       ethereum.FormatQuantity(number),
+		"hash":         b.hash,
+		"parentHash":   b.parentHash,
+		"timestamp":    ethereum.FormatQuantity(genesisTime + number*blockTime),
+		"logsBloom":    emptyBloom,
+		"transactions": txs,
+	}
+}
+
+// nodeTransaction drops fields of indexed transactions which nodes do not return
+func nodeTransaction(tx ethereum.Transaction) ethereum.Transaction {
+	tx.BlockTimestamp = ""
+	tx.Status = ""
+	tx.GasUsed = ""
+	tx.EffectiveGasPrice = ""
+	tx.Failed = false
+	return tx
+}
+
+// mined finds a transaction in served blocks
+func (n *Node) mined(txHash string) (ethereum.Transaction, *block, bool) {
+	for number := 0; number <= n.reportedHead(); number++ {
+		b := n.visibleBlock(number)
+		if b == nil {
+			continue
+		}
+		for _, tx := range b.txs {
+			if tx.Hash == txHash {
+				return tx, b, true
+			}
+		}
+	}
+	return ethereum.Transaction{}, nil, false
+}
+
+func (n *Node) receipt(txHash string) any {
+	tx, b, ok := n.mined(txHash)
+	if !ok {
+		return nil
+	}
+	receipt := map[string]any{
+		"transactionHash":   tx.Hash,
+		"blockHash":         b.hash,
+		"blockNumber":       tx.BlockNumber,
+		"from":              tx.From,
+		"to":                tx.To,
+		"gasUsed":           defaultGasUsed,
+		"effectiveGasPrice": defaultGasPrice,
+		"status":            "0x1",
+		"logs":              []any{},
+	}
+	if tx.GasUsed != "" {
+		receipt["gasUsed"] = tx.GasUsed
+	}
+	if tx.EffectiveGasPrice != "" {
+		receipt["effectiveGasPrice"] = tx.EffectiveGasPrice
+	}
+	if tx.Failed {
+		receipt["status"] = "0x0"
+	}
+	return receipt
+}
+
+// transaction returns a mined or pending transaction, pending ones have no block number
+func (n *Node) transaction(txHash string) any {
+	if tx, _, ok := n.mined(txHash); ok {
+		return nodeTransaction(tx)
+	}
+	for _, tx := range n.pending {
+		if tx.Hash == txHash {
+			return nodeTransaction(tx)
+		}
+	}
+	return nil
+}
+
+// nonce counts mined transactions sent from address
+func (n *Node) nonce(address string) int {
+	var count int
+	for number := 0; number <= n.reportedHead(); number++ {
+		for _, tx := range n.blocks[number].txs {
+			if strings.EqualFold(tx.From, address) {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func hash(parts ...any) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%v", parts)))
+	return "0x" + hex.EncodeToString(sum[:])
+}
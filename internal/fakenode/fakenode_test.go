@@ -0,0 +1,216 @@
+package fakenode
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+func TestNode(t *testing.T) {
+	ctx := context.Background()
+	sender := "0x2222222222222222222222222222222222222222"
+	recipient := "0x1111111111111111111111111111111111111111"
+
+	node := New(WithChainID(1337), WithHead(10))
+	defer node.Close()
+	client := ethereum.NewJsonRPCClient(ethereum.WithHTTPClient(&http.Client{}), ethereum.WithEndpoint(node.URL), ethereum.WithChainID(1337),
+		ethereum.WithLog(slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+	head := func(t *testing.T) int {
+		t.Helper()
+		number, err := client.GetBlockNumber(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		return number
+	}
+
+	t.Run("chain", func(t *testing.T) {
+		if err := client.VerifyChainID(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if number := head(t); number != 10 {
+			t.Fatalf("expected head 10, got %d", number)
+		}
+	})
+
+	t.Run("mined transactions", func(t *testing.T) {
+		number := node.Mine(
+			ethereum.Transaction{Hash: "0xa1", From: sender, To: recipient, Value: "0x1"},
+			ethereum.Transaction{From: sender, To: recipient, Value: "0x2", Failed: true},
+		)
+		if number != 11 || head(t) != 11 {
+			t.Fatalf("expected block 11 to be the head, got %d", number)
+		}
+
+		block, err := client.GetBlockByNumber(ctx, number)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(block.Transactions) != 2 || block.Transactions[0].BlockNumber != "0xb" || block.Transactions[1].Hash == "" || block.Transactions[1].Failed {
+			t.Fatalf("unexpected transactions %+v", block.Transactions)
+		}
+		if block.Timestamp != ethereum.FormatQuantity(genesisTime+11*blockTime) {
+			t.Fatalf("unexpected timestamp %s", block.Timestamp)
+		}
+
+		receipt, err := client.GetTransactionReceipt(ctx, "0xa1")
+		if err != nil || receipt.GasUsed != defaultGasUsed || receipt.Failed() {
+			t.Fatalf("unexpected receipt %+v: %v", receipt, err)
+		}
+		if receipt, err := client.GetTransactionReceipt(ctx, block.Transactions[1].Hash); err != nil || !receipt.Failed() {
+			t.Fatalf("expected a failed receipt, got %+v: %v", receipt, err)
+		}
+		if nonce, err := client.GetTransactionCount(ctx, sender); err != nil || nonce != 2 {
+			t.Fatalf("expected nonce 2, got %d: %v", nonce, err)
+		}
+	})
+
+	t.Run("pending transactions", func(t *testing.T) {
+		filterID, err := client.NewPendingTransactionFilter(ctx)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		node.Send(ethereum.Transaction{Hash: "0xb1", From: sender, To: recipient, Value: "0x3"})
+
+		hashes, err := client.GetFilterChanges(ctx, filterID)
+		if err != nil || len(hashes) != 1 || hashes[0] != "0xb1" {
+			t.Fatalf("expected the sent transaction, got %v: %v", hashes, err)
+		}
+		if tx, found, err := client.GetTransactionByHash(ctx, "0xb1"); err != nil || !found || tx.BlockNumber != "" {
+			t.Fatalf("expected a pending transaction, got %+v: %v", tx, err)
+		}
+
+		number := node.Mine()
+		if tx, found, err := client.GetTransactionByHash(ctx, "0xb1"); err != nil || !found || tx.BlockNumber != ethereum.FormatQuantity(number) {
+			t.Fatalf("expected the transaction mined in %d, got %+v: %v", number, tx, err)
+		}
+	})
+
+	t.Run("reorg", func(t *testing.T) {
+		number := node.Mine(ethereum.Transaction{Hash: "0xc1", From: sender, To: recipient})
+		dropped := node.Reorg(1)
+		if len(dropped) != 1 || dropped[0].Hash != "0xc1" || dropped[0].BlockNumber != "" {
+			t.Fatalf("expected the dropped transaction, got %+v", dropped)
+		}
+		if _, err := client.GetTransactionReceipt(ctx, "0xc1"); err == nil {
+			t.Fatal("expected no receipt of a dropped transaction")
+		}
+
+		node.Mine()
+		if moved := node.Mine(dropped...); moved != number+1 {
+			t.Fatalf("expected the transaction in block %d, got %d", number+1, moved)
+		}
+		if receipt, err := client.GetTransactionReceipt(ctx, "0xc1"); err != nil || receipt.BlockNumber != ethereum.FormatQuantity(number+1) {
+			t.Fatalf("unexpected receipt %+v: %v", receipt, err)
+		}
+	})
+
+	t.Run("lagging backend", func(t *testing.T) {
+		node.Lag()
+		number := node.Mine()
+		if head(t) != number {
+			t.Fatalf("expected block %d in the head", number)
+		}
+		if _, err := client.GetBlockByNumber(ctx, number); !errors.Is(err, ethereum.ErrBlockNotFound) {
+			t.Fatalf("expected block not found, got %v", err)
+		}
+
+		node.CatchUp()
+		if block, err := client.GetBlockByNumber(ctx, number); err != nil || block.Number != ethereum.FormatQuantity(number) {
+			t.Fatalf("expected block %d, got %+v: %v", number, block, err)
+		}
+	})
+
+	t.Run("paused head", func(t *testing.T) {
+		node.Pause()
+		paused := node.Head()
+		node.Mine()
+		if number := head(t); number != paused {
+			t.Fatalf("expected head %d while paused, got %d", paused, number)
+		}
+		if _, err := client.GetBlockByNumber(ctx, paused+1); !errors.Is(err, ethereum.ErrBlockNotFound) {
+			t.Fatalf("expected no block above the paused head, got %v", err)
+		}
+		node.Resume()
+		if number := head(t); number != paused+1 {
+			t.Fatalf("expected head %d, got %d", paused+1, number)
+		}
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		node.FailNext("eth_blockNumber", 1, http.StatusOK)
+		node.FailNext("eth_blockNumber", 1, http.StatusBadGateway)
+		if _, err := client.GetBlockNumber(ctx); err == nil || !strings.Contains(err.Error(), "simulated failure") {
+			t.Fatalf("expected a json-rpc error, got %v", err)
+		}
+		if _, err := client.GetBlockNumber(ctx); err == nil || !strings.Contains(err.Error(), "502") {
+			t.Fatalf("expected an http error, got %v", err)
+		}
+		head(t)
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		node.SetRateLimit(1, time.Minute)
+		defer node.SetRateLimit(0, 0)
+		head(t)
+		if _, err := client.GetBlockNumber(ctx); err == nil || !strings.Contains(err.Error(), "429") {
+			t.Fatalf("expected rate limiting, got %v", err)
+		}
+		if node.RateLimited() != 1 {
+			t.Fatalf("expected a rate limited request, got %d", node.RateLimited())
+		}
+	})
+
+	t.Run("requests are counted", func(t *testing.T) {
+		if _, err := client.GetBalance(ctx, sender, 1); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if node.Requests("eth_getBalance") != 1 {
+			t.Fatalf("expected a request of eth_getBalance, got %d", node.Requests("eth_getBalance"))
+		}
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		requests := node.Requests("eth_getBalance")
+		balances, err := client.GetBalances(ctx, []string{sender, recipient}, 1)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(balances) != 2 || node.Requests("eth_getBalance")-requests != 2 {
+			t.Fatalf("expected both balances, got %v", balances)
+		}
+
+		node.FailNext("eth_getBalance", 1, http.StatusOK)
+		if _, err := client.GetBalances(ctx, []string{sender, recipient}, 1); err == nil || !strings.Contains(err.Error(), "simulated failure") {
+			t.Fatalf("expected a failed batch, got %v", err)
+		}
+	})
+}
+
+func TestNode_BlockTime(t *testing.T) {
+	node := New(WithHead(1), WithBlockTime(time.Millisecond*10))
+	defer node.Close()
+
+	node.Send(ethereum.Transaction{Hash: "0xd1"})
+	deadline := time.Now().Add(time.Second)
+	for node.Head() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected blocks to be mined, head is %d", node.Head())
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	if _, _, ok := node.mined("0xd1"); !ok {
+		t.Fatal("expected the sent transaction to be mined")
+	}
+}
@@ -0,0 +1,252 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: api/ethtxparser/v1/parser.proto
+
+// Wire contract of the gRPC API served by internal/grpcapi. Go code is generated with make proto.
+// Field numbers must not change.
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Parser_Subscribe_FullMethodName         = "/ethtxparser.v1.Parser/Subscribe"
+	Parser_ListTransactions_FullMethodName  = "/ethtxparser.v1.Parser/ListTransactions"
+	Parser_GetCurrentBlock_FullMethodName   = "/ethtxparser.v1.Parser/GetCurrentBlock"
+	Parser_WatchTransactions_FullMethodName = "/ethtxparser.v1.Parser/WatchTransactions"
+)
+
+// ParserClient is the client API for Parser service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ParserClient interface {
+	// Subscribe starts indexing transactions of an address for the tenant
+	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (*SubscribeResponse, error)
+	// ListTransactions pages through stored transactions of a subscribed address in block order
+	ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error)
+	// GetCurrentBlock returns the last processed block
+	GetCurrentBlock(ctx context.Context, in *GetCurrentBlockRequest, opts ...grpc.CallOption) (*GetCurrentBlockResponse, error)
+	// WatchTransactions streams pending and mined transactions of a subscribed address as they are stored.
+	// The stream fails with RESOURCE_EXHAUSTED when the client does not keep up
+	WatchTransactions(ctx context.Context, in *WatchTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transaction], error)
+}
+
+type parserClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewParserClient(cc grpc.ClientConnInterface) ParserClient {
+	return &parserClient{cc}
+}
+
+func (c *parserClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (*SubscribeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SubscribeResponse)
+	err := c.cc.Invoke(ctx, Parser_Subscribe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *parserClient) ListTransactions(ctx context.Context, in *ListTransactionsRequest, opts ...grpc.CallOption) (*ListTransactionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTransactionsResponse)
+	err := c.cc.Invoke(ctx, Parser_ListTransactions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *parserClient) GetCurrentBlock(ctx context.Context, in *GetCurrentBlockRequest, opts ...grpc.CallOption) (*GetCurrentBlockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetCurrentBlockResponse)
+	err := c.cc.Invoke(ctx, Parser_GetCurrentBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *parserClient) WatchTransactions(ctx context.Context, in *WatchTransactionsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Transaction], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Parser_ServiceDesc.Streams[0], Parser_WatchTransactions_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchTransactionsRequest, Transaction]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Parser_WatchTransactionsClient = grpc.ServerStreamingClient[Transaction]
+
+// ParserServer is the server API for Parser service.
+// All implementations must embed UnimplementedParserServer
+// for forward compatibility.
+type ParserServer interface {
+	// Subscribe starts indexing transactions of an address for the tenant
+	Subscribe(context.Context, *SubscribeRequest) (*SubscribeResponse, error)
+	// ListTransactions pages through stored transactions of a subscribed address in block order
+	ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error)
+	// GetCurrentBlock returns the last processed block
+	GetCurrentBlock(context.Context, *GetCurrentBlockRequest) (*GetCurrentBlockResponse, error)
+	// WatchTransactions streams pending and mined transactions of a subscribed address as they are stored.
+	// The stream fails with RESOURCE_EXHAUSTED when the client does not keep up
+	WatchTransactions(*WatchTransactionsRequest, grpc.ServerStreamingServer[Transaction]) error
+	mustEmbedUnimplementedParserServer()
+}
+
+// UnimplementedParserServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedParserServer struct{}
+
+func (UnimplementedParserServer) Subscribe(context.Context, *SubscribeRequest) (*SubscribeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
+}
+func (UnimplementedParserServer) ListTransactions(context.Context, *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTransactions not implemented")
+}
+func (UnimplementedParserServer) GetCurrentBlock(context.Context, *GetCurrentBlockRequest) (*GetCurrentBlockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCurrentBlock not implemented")
+}
+func (UnimplementedParserServer) WatchTransactions(*WatchTransactionsRequest, grpc.ServerStreamingServer[Transaction]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchTransactions not implemented")
+}
+func (UnimplementedParserServer) mustEmbedUnimplementedParserServer() {}
+func (UnimplementedParserServer) testEmbeddedByValue()                {}
+
+// UnsafeParserServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ParserServer will
+// result in compilation errors.
+type UnsafeParserServer interface {
+	mustEmbedUnimplementedParserServer()
+}
+
+func RegisterParserServer(s grpc.ServiceRegistrar, srv ParserServer) {
+	// If the following call pancis, it indicates UnimplementedParserServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Parser_ServiceDesc, srv)
+}
+
+func _Parser_Subscribe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubscribeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParserServer).Subscribe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Parser_Subscribe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParserServer).Subscribe(ctx, req.(*SubscribeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Parser_ListTransactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListTransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParserServer).ListTransactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Parser_ListTransactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParserServer).ListTransactions(ctx, req.(*ListTransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Parser_GetCurrentBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCurrentBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ParserServer).GetCurrentBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Parser_GetCurrentBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ParserServer).GetCurrentBlock(ctx, req.(*GetCurrentBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Parser_WatchTransactions_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchTransactionsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ParserServer).WatchTransactions(m, &grpc.GenericServerStream[WatchTransactionsRequest, Transaction]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Parser_WatchTransactionsServer = grpc.ServerStreamingServer[Transaction]
+
+// Parser_ServiceDesc is the grpc.ServiceDesc for Parser service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Parser_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ethtxparser.v1.Parser",
+	HandlerType: (*ParserServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Subscribe",
+			Handler:    _Parser_Subscribe_Handler,
+		},
+		{
+			MethodName: "ListTransactions",
+			Handler:    _Parser_ListTransactions_Handler,
+		},
+		{
+			MethodName: "GetCurrentBlock",
+			Handler:    _Parser_GetCurrentBlock_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchTransactions",
+			Handler:       _Parser_WatchTransactions_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/ethtxparser/v1/parser.proto",
+}
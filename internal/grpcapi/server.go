@@ -0,0 +1,312 @@
+// Package grpcapi serves the operations of server.Parser over gRPC, for consumers which do not speak HTTP.
+// It shares storage, API keys and tenant isolation rules with the HTTP API.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/bus"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/server"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// defaultPageSize and maxPageSize bound transactions page size
+	defaultPageSize = 100
+	maxPageSize     = 1000
+)
+
+type options struct {
+	defaultChain      int
+	apiKeys           tenant.Keys
+	buses             map[int]*bus.Bus
+	subscriptionQuota int
+}
+
+type Option func(*options)
+
+// WithDefaultChain serves requests without a chain id with the chain
+func WithDefaultChain(chainID int) Option {
+	return func(o *options) {
+		o.defaultChain = chainID
+	}
+}
+
+// WithAPIKeys requires requests to authenticate with one of the keys in the authorization or x-api-key
+// metadata, see server.WithAPIKeys
+func WithAPIKeys(keys map[string]string) Option {
+	return func(o *options) {
+		o.apiKeys = tenant.NewKeys(keys)
+	}
+}
+
+// WithBus streams transactions of the chain published to the bus to WatchTransactions clients
+func WithBus(chainID int, b *bus.Bus) Option {
+	return func(o *options) {
+		o.buses[chainID] = b
+	}
+}
+
+// WithSubscriptionQuota limits number of subscriptions of a tenant per chain, see server.WithSubscriptionQuota
+func WithSubscriptionQuota(quota int) Option {
+	return func(o *options) {
+		o.subscriptionQuota = quota
+	}
+}
+
+// NewServer returns a gRPC server with the Parser service registered
+func NewServer(chains server.Chains, log *slog.Logger, opts ...Option) *grpc.Server {
+	o := options{buses: make(map[int]*bus.Bus)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	s := &parserService{chains: chains, log: log, options: o}
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(s.authenticateUnary),
+		grpc.ChainStreamInterceptor(s.authenticateStream),
+	)
+	RegisterParserServer(grpcServer, s)
+	return grpcServer
+}
+
+type parserService struct {
+	UnimplementedParserServer
+	chains server.Chains
+	log    *slog.Logger
+	options
+}
+
+func (s *parserService) Subscribe(ctx context.Context, req *SubscribeRequest) (*SubscribeResponse, error) {
+	parser, chainID, err := s.chain(req.ChainId)
+	if err != nil {
+		return nil, err
+	}
+	address, err := ethereum.ParseAddress(req.Address)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	err = server.CheckSubscriptionQuota(ctx, parser, s.subscriptionQuota, tenant.SubscriptionKindAddress, address)
+	switch {
+	case errors.Is(err, server.ErrSubscriptionQuota):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	case err != nil:
+		return nil, s.internal(ctx, "failed to check subscription quota", err, "chain_id", chainID, "address", address)
+	}
+
+	s.log.InfoContext(ctx, "subscribing to address", "chain_id", chainID, "tenant", tenantID, "address", address)
+	sub := tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: address, CreatedAt: time.Now().UTC()}
+	if err := parser.SubscribeTenant(ctx, tenantID, sub); err != nil {
+		return nil, s.internal(ctx, "failed to subscribe to address", err, "chain_id", chainID, "address", address)
+	}
+	return &SubscribeResponse{}, nil
+}
+
+func (s *parserService) ListTransactions(ctx context.Context, req *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	parser, chainID, address, err := s.subscribedAddress(ctx, req.ChainId, req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = defaultPageSize
+	}
+	if pageSize < 0 || pageSize > maxPageSize {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid page size %d: expected 1 to %d", req.PageSize, maxPageSize)
+	}
+	offset := 0
+	if req.PageToken != "" {
+		offset, err = strconv.Atoi(req.PageToken)
+		if err != nil || offset < 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid page token %q", req.PageToken)
+		}
+	}
+
+	// one more transaction tells whether there is a next page
+	txs, err := parser.GetTransactionsPage(ctx, address, offset, pageSize+1)
+	if err != nil {
+		return nil, s.internal(ctx, "failed to get transactions for address", err, "chain_id", chainID, "address", address)
+	}
+
+	resp := &ListTransactionsResponse{}
+	for _, tx := range txs[:min(pageSize, len(txs))] {
+		resp.Transactions = append(resp.Transactions, fromTransaction(tx))
+	}
+	if len(txs) > pageSize {
+		resp.NextPageToken = strconv.Itoa(offset + pageSize)
+	}
+	return resp, nil
+}
+
+func (s *parserService) GetCurrentBlock(ctx context.Context, req *GetCurrentBlockRequest) (*GetCurrentBlockResponse, error) {
+	parser, chainID, err := s.chain(req.ChainId)
+	if err != nil {
+		return nil, err
+	}
+	block, err := parser.GetCurrentBlock(ctx)
+	if err != nil {
+		return nil, s.internal(ctx, "failed to get current block", err, "chain_id", chainID)
+	}
+	return &GetCurrentBlockResponse{Block: int64(block)}, nil
+}
+
+func (s *parserService) WatchTransactions(req *WatchTransactionsRequest, stream grpc.ServerStreamingServer[Transaction]) error {
+	ctx := stream.Context()
+	_, chainID, address, err := s.subscribedAddress(ctx, req.ChainId, req.Address)
+	if err != nil {
+		return err
+	}
+	b, ok := s.buses[chainID]
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "watching transactions of chain %d is not enabled", chainID)
+	}
+
+	watcher := b.Watch(address)
+	defer b.Stop(watcher)
+	s.log.InfoContext(ctx, "watching transactions", "chain_id", chainID, "tenant", tenant.FromContext(ctx), "address", address)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case tx, ok := <-watcher.C:
+			if !ok {
+				if watcher.Dropped() {
+					return status.Error(codes.ResourceExhausted, "client does not keep up with transactions, watch again")
+				}
+				return nil
+			}
+			if err := stream.Send(fromTransaction(tx)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// chain returns the parser of the chain, the default one for 0
+func (s *parserService) chain(chainID int64) (server.Parser, int, error) {
+	id := int(chainID)
+	if id == 0 {
+		id = s.defaultChain
+	}
+	parser, ok := s.chains[id]
+	if !ok {
+		return nil, 0, status.Errorf(codes.NotFound, "unknown chain %d", chainID)
+	}
+	return parser, id, nil
+}
+
+// subscribedAddress validates the address and checks the tenant of the request is subscribed to it
+// when tenants are isolated
+func (s *parserService) subscribedAddress(ctx context.Context, chainID int64, address string) (server.Parser, int, string, error) {
+	parser, id, err := s.chain(chainID)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	address, err = ethereum.ParseAddress(address)
+	if err != nil {
+		return nil, 0, "", status.Error(codes.InvalidArgument, err.Error())
+	}
+	if s.apiKeys == nil {
+		return parser, id, address, nil
+	}
+
+	subscribed, err := parser.HasTenantSubscription(ctx, tenant.FromContext(ctx), tenant.SubscriptionKindAddress, address)
+	if err != nil {
+		return nil, 0, "", s.internal(ctx, "failed to check tenant subscription", err, "chain_id", id, "address", address)
+	}
+	// not found rather than permission denied, so tenants can not probe what others are subscribed to
+	if !subscribed {
+		return nil, 0, "", status.Errorf(codes.NotFound, "address %s is not subscribed", address)
+	}
+	return parser, id, address, nil
+}
+
+// internal logs the error and hides its details from the client
+func (s *parserService) internal(ctx context.Context, msg string, err error, args ...any) error {
+	s.log.ErrorContext(ctx, msg, append(args, "error", err)...)
+	return status.Error(codes.Internal, "internal error")
+}
+
+func (s *parserService) authenticateUnary(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *parserService) authenticateStream(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, tenantStream{ServerStream: stream, ctx: ctx})
+}
+
+// authenticate resolves the tenant of a request by its API key
+func (s *parserService) authenticate(ctx context.Context) (context.Context, error) {
+	if s.apiKeys == nil {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var key string
+	if values := md.Get("x-api-key"); len(values) > 0 {
+		key = values[0]
+	}
+	if values := md.Get("authorization"); len(values) > 0 {
+		if bearer, ok := strings.CutPrefix(values[0], "Bearer "); ok {
+			key = bearer
+		}
+	}
+
+	tenantID, ok := s.apiKeys.Tenant(key)
+	if !ok {
+		s.log.WarnContext(ctx, "unauthenticated grpc request")
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid api key")
+	}
+	return tenant.NewContext(ctx, tenantID), nil
+}
+
+// tenantStream carries the context with the authenticated tenant
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s tenantStream) Context() context.Context {
+	return s.ctx
+}
+
+func fromTransaction(tx ethereum.Transaction) *Transaction {
+	return &Transaction{
+		Hash:              tx.Hash,
+		From:              tx.From,
+		To:                tx.To,
+		Value:             tx.Value,
+		Nonce:             tx.Nonce,
+		Input:             tx.Input,
+		BlockNumber:       tx.BlockNumber,
+		LogIndex:          tx.LogIndex,
+		Token:             tx.Token,
+		Status:            string(tx.Status),
+		GasUsed:           tx.GasUsed,
+		EffectiveGasPrice: tx.EffectiveGasPrice,
+		Failed:            tx.Failed,
+		BlockTimestamp:    tx.BlockTimestamp,
+	}
+}
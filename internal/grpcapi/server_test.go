@@ -0,0 +1,237 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/bus"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/server"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMessages(t *testing.T) {
+	t.Run("transactions round trip", func(t *testing.T) {
+		in := &ListTransactionsResponse{
+			Transactions: []*Transaction{
+				{Hash: "0x1", From: "0xa", To: "0xb", Value: "0x10", BlockNumber: "0x5", BlockTimestamp: "0x6554d1c7", Status: "mined", Failed: true},
+				{Hash: "0x2", Token: "0xc", LogIndex: "0x0", GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00"},
+			},
+			NextPageToken: "2",
+		}
+		b, err := proto.Marshal(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &ListTransactionsResponse{}
+		if err := proto.Unmarshal(b, out); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(in, out) {
+			t.Fatalf("expected %v, got %v", in, out)
+		}
+	})
+
+	t.Run("field numbers of parser.proto", func(t *testing.T) {
+		// clients built from parser.proto depend on field numbers, which must not change
+		b, _ := proto.Marshal(&Transaction{BlockTimestamp: "0x1", Failed: true})
+		expected := protowire.AppendTag(nil, 13, protowire.VarintType)
+		expected = protowire.AppendVarint(expected, 1)
+		expected = protowire.AppendTag(expected, 14, protowire.BytesType)
+		expected = protowire.AppendString(expected, "0x1")
+		if string(b) != string(expected) {
+			t.Fatalf("expected %x, got %x", expected, b)
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := storage.NewInMemoryStorage()
+	transactions := bus.New()
+	client := newTestClient(t, NewServer(server.Chains{1: store}, log,
+		WithDefaultChain(1),
+		WithBus(1, transactions),
+		WithAPIKeys(map[string]string{"key-a": "team-a", "key-b": "team-b"}),
+		WithSubscriptionQuota(2),
+	))
+
+	address := "0x1111111111111111111111111111111111111111"
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+key)
+	}
+	expectCode := func(t *testing.T, err error, code codes.Code) {
+		t.Helper()
+		if status.Code(err) != code {
+			t.Fatalf("expected %s, got %v", code, err)
+		}
+	}
+
+	t.Run("requests without a valid key are rejected", func(t *testing.T) {
+		_, err := client.GetCurrentBlock(context.Background(), &GetCurrentBlockRequest{})
+		expectCode(t, err, codes.Unauthenticated)
+		_, err = client.GetCurrentBlock(withKey("key-c"), &GetCurrentBlockRequest{})
+		expectCode(t, err, codes.Unauthenticated)
+	})
+
+	t.Run("current block", func(t *testing.T) {
+		if err := store.SetCurrentBlock(context.Background(), 42); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := client.GetCurrentBlock(withKey("key-a"), &GetCurrentBlockRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Block != 42 {
+			t.Fatalf("expected block 42, got %d", resp.Block)
+		}
+
+		_, err = client.GetCurrentBlock(withKey("key-a"), &GetCurrentBlockRequest{ChainId: 5})
+		expectCode(t, err, codes.NotFound)
+	})
+
+	t.Run("subscribe", func(t *testing.T) {
+		_, err := client.Subscribe(withKey("key-a"), &SubscribeRequest{Address: "0xabc"})
+		expectCode(t, err, codes.InvalidArgument)
+
+		if _, err := client.Subscribe(withKey("key-a"), &SubscribeRequest{Address: address}); err != nil {
+			t.Fatal(err)
+		}
+		subscribed, err := store.IsSubscribed(context.Background(), address)
+		if err != nil || !subscribed {
+			t.Fatalf("expected address to be subscribed, got %v, %v", subscribed, err)
+		}
+
+		// subscribing again is idempotent and does not count against the quota
+		if _, err := client.Subscribe(withKey("key-a"), &SubscribeRequest{Address: address}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := client.Subscribe(withKey("key-a"), &SubscribeRequest{Address: "0x2222222222222222222222222222222222222222"}); err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.Subscribe(withKey("key-a"), &SubscribeRequest{Address: "0x3333333333333333333333333333333333333333"})
+		expectCode(t, err, codes.ResourceExhausted)
+	})
+
+	t.Run("transactions are paged", func(t *testing.T) {
+		var txs []ethereum.AddressTx
+		for _, hash := range []string{"0x01", "0x02", "0x03"} {
+			txs = append(txs, ethereum.AddressTx{Address: address, Tx: ethereum.Transaction{Hash: hash, From: address}})
+		}
+		if err := store.CommitBlock(context.Background(), 43, txs, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		var hashes []string
+		req := &ListTransactionsRequest{Address: address, PageSize: 2}
+		for pages := 0; ; pages++ {
+			resp, err := client.ListTransactions(withKey("key-a"), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tx := range resp.Transactions {
+				hashes = append(hashes, tx.Hash)
+			}
+			if resp.NextPageToken == "" {
+				if pages != 1 {
+					t.Fatalf("expected 2 pages, got %d", pages+1)
+				}
+				break
+			}
+			req.PageToken = resp.NextPageToken
+		}
+		if len(hashes) != 3 {
+			t.Fatalf("expected 3 transactions, got %v", hashes)
+		}
+
+		_, err := client.ListTransactions(withKey("key-a"), &ListTransactionsRequest{Address: address, PageSize: maxPageSize + 1})
+		expectCode(t, err, codes.InvalidArgument)
+		_, err = client.ListTransactions(withKey("key-a"), &ListTransactionsRequest{Address: address, PageToken: "x"})
+		expectCode(t, err, codes.InvalidArgument)
+	})
+
+	t.Run("tenants see only own subscriptions", func(t *testing.T) {
+		_, err := client.ListTransactions(withKey("key-b"), &ListTransactionsRequest{Address: address})
+		expectCode(t, err, codes.NotFound)
+		// errors of a server stream surface on the first receive
+		stream, err := client.WatchTransactions(withKey("key-b"), &WatchTransactionsRequest{Address: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = stream.Recv()
+		expectCode(t, err, codes.NotFound)
+	})
+
+	t.Run("stored transactions are streamed", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(withKey("key-a"), time.Second*5)
+		defer cancel()
+		stream, err := client.WatchTransactions(ctx, &WatchTransactionsRequest{Address: address})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the watcher is registered once the server handles the request, publish until the client receives
+		received := make(chan error, 1)
+		var tx *Transaction
+		go func() {
+			var err error
+			tx, err = stream.Recv()
+			received <- err
+		}()
+		ticker := time.NewTicker(time.Millisecond * 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case err := <-received:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tx.Hash != "0x04" || tx.Status != string(ethereum.TransactionStatusMined) {
+					t.Fatalf("unexpected transaction %+v", tx)
+				}
+				return
+			case <-ticker.C:
+				transactions.Publish([]ethereum.AddressTx{{
+					Address: address,
+					Tx:      ethereum.Transaction{Hash: "0x04", Status: ethereum.TransactionStatusMined},
+				}})
+			case <-ctx.Done():
+				t.Fatal("timed out waiting for transaction")
+			}
+		}
+	})
+}
+
+func newTestClient(t *testing.T, srv *grpc.Server) ParserClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	go func() {
+		_ = srv.Serve(listener)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return NewParserClient(conn)
+}
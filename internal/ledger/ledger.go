@@ -0,0 +1,111 @@
+// Package ledger reconstructs historical balances of an address from its indexed transactions
+// anchored to balance snapshots fetched from the node.
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+// ErrNoSnapshot is returned for blocks before the first balance snapshot of an address,
+// history is not indexed before the address is subscribed
+var ErrNoSnapshot = errors.New("no balance snapshot at or before block")
+
+// BalancesAt computes balances of address as of block starting from the latest snapshot at or before
+// the block and applying transactions mined after the snapshot up to the block.
+// Snapshots must be ordered by block. Only tokens present in the snapshot are reconstructed,
+// as balance of a token before its first indexed transfer is unknown
+func BalancesAt(address string, snapshots []ethereum.Balances, txs []ethereum.Transaction, block int) (ethereum.Balances, error) {
+	i := sort.Search(len(snapshots), func(i int) bool { return snapshots[i].Block > block })
+	if i == 0 {
+		return ethereum.Balances{}, ErrNoSnapshot
+	}
+	anchor := snapshots[i-1]
+
+	eth, ok := new(big.Int).SetString(anchor.ETH, 10)
+	if !ok {
+		return ethereum.Balances{}, fmt.Errorf("invalid snapshot balance %q at block %d", anchor.ETH, anchor.Block)
+	}
+	tokens := make(map[string]*big.Int, len(anchor.Tokens))
+	for token, balance := range anchor.Tokens {
+		tokens[token], ok = new(big.Int).SetString(balance, 10)
+		if !ok {
+			return ethereum.Balances{}, fmt.Errorf("invalid snapshot balance %q of token %s at block %d", balance, token, anchor.Block)
+		}
+	}
+
+	for _, tx := range txs {
+		// pending, replaced and dropped transactions did not change balances
+		if tx.Status != ethereum.TransactionStatusMined {
+			continue
+		}
+
+		txBlock, err := ethereum.ParseQuantity(tx.BlockNumber)
+		if err != nil {
+			return ethereum.Balances{}, fmt.Errorf("transaction %s: %w", tx.Hash, err)
+		}
+		if txBlock <= anchor.Block || txBlock > block {
+			continue
+		}
+
+		if tx.Token == "" {
+			if err := applyTransaction(eth, address, tx); err != nil {
+				return ethereum.Balances{}, err
+			}
+			continue
+		}
+
+		if balance, ok := tokens[tx.Token]; ok {
+			if err := applyTransfer(balance, address, tx); err != nil {
+				return ethereum.Balances{}, err
+			}
+		}
+	}
+
+	balances := ethereum.Balances{
+		Address: address,
+		Block:   block,
+		ETH:     eth.String(),
+		Tokens:  make(map[string]string, len(tokens)),
+	}
+	for token, balance := range tokens {
+		balances.Tokens[token] = balance.String()
+	}
+	return balances, nil
+}
+
+// applyTransaction applies value and fees of a plain transaction to the ETH balance of address
+func applyTransaction(balance *big.Int, address string, tx ethereum.Transaction) error {
+	if tx.From == address {
+		fee, ok := tx.Fee()
+		if !ok {
+			return fmt.Errorf("fee of transaction %s is not recorded", tx.Hash)
+		}
+		balance.Sub(balance, fee)
+	}
+
+	if tx.Failed {
+		return nil
+	}
+	return applyTransfer(balance, address, tx)
+}
+
+// applyTransfer applies value moved by a transaction to the balance of address. Self-transfers do not change it
+func applyTransfer(balance *big.Int, address string, tx ethereum.Transaction) error {
+	value, err := ethereum.ParseBigQuantity(tx.Value)
+	if err != nil {
+		return fmt.Errorf("transaction %s value: %w", tx.Hash, err)
+	}
+
+	if tx.To == address {
+		balance.Add(balance, value)
+	}
+	if tx.From == address {
+		balance.Sub(balance, value)
+	}
+	return nil
+}
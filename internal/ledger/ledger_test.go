@@ -0,0 +1,84 @@
+package ledger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+func TestBalancesAt(t *testing.T) {
+	address := "0xaaaa"
+	other := "0xbbbb"
+
+	snapshots := []ethereum.Balances{
+		{Address: address, Block: 100, ETH: "1000", Tokens: map[string]string{"0xtoken": "50"}},
+		{Address: address, Block: 200, ETH: "5000"},
+	}
+	mined := func(block string, tx ethereum.Transaction) ethereum.Transaction {
+		tx.BlockNumber = block
+		tx.Status = ethereum.TransactionStatusMined
+		return tx
+	}
+	txs := []ethereum.Transaction{
+		// +100
+		mined("0x65", ethereum.Transaction{Hash: "0x1", From: other, To: address, Value: "0x64"}),
+		// -10 value, -21 fee
+		mined("0x66", ethereum.Transaction{Hash: "0x2", From: address, To: other, Value: "0xa", GasUsed: "0x7", EffectiveGasPrice: "0x3"}),
+		// reverted, -21 fee only
+		mined("0x67", ethereum.Transaction{Hash: "0x3", From: address, To: other, Value: "0xa", GasUsed: "0x7", EffectiveGasPrice: "0x3", Failed: true}),
+		// -5 tokens
+		mined("0x67", ethereum.Transaction{Hash: "0x3", From: address, To: other, Value: "0x5", Token: "0xtoken", LogIndex: "0x0"}),
+		// untracked token
+		mined("0x67", ethereum.Transaction{Hash: "0x3", From: other, To: address, Value: "0x5", Token: "0xother", LogIndex: "0x1"}),
+		// not mined
+		{Hash: "0x4", From: other, To: address, Value: "0x64", Status: ethereum.TransactionStatusPending},
+		// after the requested blocks
+		mined("0xc9", ethereum.Transaction{Hash: "0x5", From: other, To: address, Value: "0x64"}),
+	}
+
+	tests := []struct {
+		name   string
+		block  int
+		eth    string
+		tokens map[string]string
+		err    error
+	}{
+		{name: "snapshot block", block: 100, eth: "1000", tokens: map[string]string{"0xtoken": "50"}},
+		{name: "incoming transaction", block: 101, eth: "1100", tokens: map[string]string{"0xtoken": "50"}},
+		{name: "outgoing transactions with fees", block: 103, eth: "1048", tokens: map[string]string{"0xtoken": "45"}},
+		{name: "later snapshot is the anchor", block: 201, eth: "5100", tokens: map[string]string{}},
+		{name: "before first snapshot", block: 99, err: ErrNoSnapshot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balances, err := BalancesAt(address, snapshots, txs, tt.block)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if tt.err != nil {
+				return
+			}
+
+			if balances.Block != tt.block || balances.ETH != tt.eth {
+				t.Fatalf("expected %s wei at block %d, got %+v", tt.eth, tt.block, balances)
+			}
+			if len(balances.Tokens) != len(tt.tokens) {
+				t.Fatalf("expected tokens %v, got %v", tt.tokens, balances.Tokens)
+			}
+			for token, balance := range tt.tokens {
+				if balances.Tokens[token] != balance {
+					t.Fatalf("expected tokens %v, got %v", tt.tokens, balances.Tokens)
+				}
+			}
+		})
+	}
+
+	t.Run("missing fee", func(t *testing.T) {
+		txs := []ethereum.Transaction{mined("0x65", ethereum.Transaction{Hash: "0x1", From: address, To: other, Value: "0x1"})}
+		if _, err := BalancesAt(address, snapshots, txs, 101); err == nil {
+			t.Fatalf("expected error for transaction without receipt")
+		}
+	})
+}
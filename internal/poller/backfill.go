@@ -0,0 +1,51 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+)
+
+// NewBackfiller indexes past blocks with the per-block processing of the poller, including token
+// transfers and receipts when the poller is configured with them
+func NewBackfiller(poller *TransactionPoller, storage TransactionsStorage) *Backfiller {
+	return &Backfiller{poller: poller, storage: storage}
+}
+
+// Backfiller indexes history of an address from blocks processed before it was subscribed.
+// It runs next to the poller and never moves the current block
+type Backfiller struct {
+	poller  *TransactionPoller
+	storage TransactionsStorage
+}
+
+// Backfill saves transactions of address in blocks from fromBlock to toBlock inclusive and returns how many were saved.
+// Saving is idempotent, so ranges which are already indexed can be backfilled again, and storage keeps backfilled
+// history in block order before transactions of later blocks. Transactions saved before a failed block are kept
+func (b *Backfiller) Backfill(ctx context.Context, address string, fromBlock, toBlock int) (int, error) {
+	match := func(_ context.Context, participant string) (bool, error) {
+		return participant == address, nil
+	}
+
+	saved := 0
+	for number := fromBlock; number <= toBlock; number++ {
+		block, err := b.poller.ethClient.GetBlockByNumber(ctx, number)
+		if err != nil {
+			pollerErrors.WithLabelValues(b.poller.chainLabel, "get_block").Inc()
+			return saved, fmt.Errorf("load block %d: %w", number, err)
+		}
+
+		addressTxs, err := b.poller.blockTransactions(ctx, number, block, match)
+		if err != nil {
+			return saved, err
+		}
+		for _, addressTx := range addressTxs {
+			if err := b.storage.SaveTransaction(ctx, addressTx.Address, addressTx.Tx); err != nil {
+				return saved, fmt.Errorf("save transaction %s of block %d: %w", addressTx.Tx.Hash, number, err)
+			}
+			saved++
+		}
+	}
+
+	b.poller.log.InfoContext(ctx, "backfilled address", "address", address, "from_block", fmt.Sprintf("%x", fromBlock), "to_block", fmt.Sprintf("%x", toBlock), "transactions_count", saved)
+	return saved, nil
+}
@@ -0,0 +1,104 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	address := "0xabc"
+
+	blocks := map[int]ethereum.EthereumBlock{
+		10: {Timestamp: "0x10", Transactions: []ethereum.Transaction{
+			{Hash: "0x1", BlockNumber: "0xa", From: address, To: "0xdef"},
+			{Hash: "0x2", BlockNumber: "0xa", From: "0xdef", To: "0x123"},
+		}},
+		11: {Timestamp: "0x11", Transactions: []ethereum.Transaction{
+			{Hash: "0x3", BlockNumber: "0xb", From: "0x123", To: address},
+		}},
+	}
+	ethClient := &MockEthClient{
+		GetBlockByNumberFunc: func(ctx context.Context, number int) (ethereum.EthereumBlock, error) {
+			block, ok := blocks[number]
+			if !ok {
+				return ethereum.EthereumBlock{}, errors.New("block not found")
+			}
+			return block, nil
+		},
+	}
+	// the poller never commits blocks or checks subscriptions during a backfill
+	poller := NewTransactionPoller(&MockAddressesStorage{}, &MockBlocksStorage{}, ethClient, log)
+
+	t.Run("saves transactions of the address", func(t *testing.T) {
+		var saved []ethereum.Transaction
+		storage := &MockTransactionsStorage{
+			SaveTransactionFunc: func(ctx context.Context, a string, tx ethereum.Transaction) error {
+				if a != address {
+					t.Errorf("expected transaction of %s, got %s", address, a)
+				}
+				saved = append(saved, tx)
+				return nil
+			},
+		}
+
+		count, err := NewBackfiller(poller, storage).Backfill(ctx, address, 10, 11)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if count != 2 || len(saved) != 2 {
+			t.Fatalf("expected 2 saved transactions, got %d: %+v", count, saved)
+		}
+		if saved[0].Hash != "0x1" || saved[1].Hash != "0x3" {
+			t.Fatalf("unexpected transactions %+v", saved)
+		}
+		if saved[1].Status != ethereum.TransactionStatusMined || saved[1].BlockTimestamp != "0x11" {
+			t.Fatalf("expected mined transaction with block time, got %+v", saved[1])
+		}
+	})
+
+	t.Run("backfilled history goes before indexed blocks", func(t *testing.T) {
+		s := storage.NewInMemoryStorage()
+		// block 12 was indexed by the poller after the address was subscribed
+		_ = s.CommitBlock(ctx, 12, []ethereum.AddressTx{
+			{Address: address, Tx: ethereum.Transaction{Hash: "0x4", BlockNumber: "0xc", Status: ethereum.TransactionStatusMined}},
+		}, nil)
+
+		if _, err := NewBackfiller(poller, s).Backfill(ctx, address, 10, 11); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		txs, _ := s.GetTransactions(ctx, address)
+		var hashes []string
+		for _, tx := range txs {
+			hashes = append(hashes, tx.Hash)
+		}
+		if expected := []string{"0x1", "0x3", "0x4"}; !slices.Equal(hashes, expected) {
+			t.Fatalf("expected transactions in block order %v, got %v", expected, hashes)
+		}
+	})
+
+	t.Run("stops on failed block", func(t *testing.T) {
+		storage := &MockTransactionsStorage{
+			SaveTransactionFunc: func(ctx context.Context, address string, tx ethereum.Transaction) error {
+				return nil
+			},
+		}
+
+		count, err := NewBackfiller(poller, storage).Backfill(ctx, address, 11, 12)
+		if err == nil {
+			t.Fatal("expected error for missing block")
+		}
+		if count != 1 {
+			t.Fatalf("expected transactions before the failed block to be saved, got %d", count)
+		}
+	})
+}
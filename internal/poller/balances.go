@@ -0,0 +1,154 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math/big"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+type BalancesClient interface {
+	GetBalance(ctx context.Context, address string, blockNumber int) (*big.Int, error)
+	// GetBalances fetches balances of several addresses in a single request, in the order of addresses
+	GetBalances(ctx context.Context, addresses []string, blockNumber int) ([]*big.Int, error)
+	GetTokenBalance(ctx context.Context, token string, owner string, blockNumber int) (*big.Int, error)
+}
+
+// missingBalancesPerRun bounds balances fetched for newly subscribed addresses in a poller run, so a large
+// watchlist does not hold back processing of new blocks. The rest is fetched on the next runs
+const missingBalancesPerRun = 100
+
+type BalancesStorage interface {
+	SaveBalances(ctx context.Context, balances ethereum.Balances) error
+	// GetBalances returns false if balances of the address were not fetched yet
+	GetBalances(ctx context.Context, address string) (ethereum.Balances, bool, error)
+}
+
+// WithBalances enables tracking of ETH and token balances of subscribed addresses. Balances are
+// refreshed as of a processed block when the block has transactions or token transfers of an address.
+// Newly subscribed addresses get their balances fetched in batches after the next poller runs
+func WithBalances(client BalancesClient, storage BalancesStorage, addresses SubscribedAddressesLister) Option {
+	return func(p *TransactionPoller) {
+		p.balances = &balances{client: client, storage: storage, addresses: addresses}
+	}
+}
+
+type balances struct {
+	client    BalancesClient
+	storage   BalancesStorage
+	addresses SubscribedAddressesLister
+}
+
+// refreshBalances updates balances of addresses with activity in the block. Tokens an address
+// received or sent in the block are tracked from then on. Failures are logged only, the block
+// is already committed and balances are refreshed again on the next activity
+func (p *TransactionPoller) refreshBalances(ctx context.Context, number int, addressTxs []ethereum.AddressTx) {
+	if p.balances == nil {
+		return
+	}
+
+	// address -> tokens transferred in the block
+	active := make(map[string][]string)
+	for _, addressTx := range addressTxs {
+		tokens := active[addressTx.Address]
+		if addressTx.Tx.Token != "" {
+			tokens = append(tokens, addressTx.Tx.Token)
+		}
+		active[addressTx.Address] = tokens
+	}
+
+	for address, tokens := range active {
+		if err := p.refreshAddressBalances(ctx, address, number, tokens); err != nil {
+			pollerErrors.WithLabelValues(p.chainLabel, "refresh_balances").Inc()
+			p.log.Error("failed to refresh balances", "address", address, "block", fmt.Sprintf("%x", number), "error", err)
+		}
+	}
+}
+
+// refreshMissingBalances fetches balances of up to missingBalancesPerRun subscribed addresses which have none yet
+// in a single batch. New addresses have no tracked tokens, so their ETH balances are all there is to fetch
+func (p *TransactionPoller) refreshMissingBalances(ctx context.Context, number int) {
+	if p.balances == nil {
+		return
+	}
+
+	addresses, err := p.balances.addresses.SubscribedAddresses(ctx)
+	if err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "refresh_balances").Inc()
+		p.log.Error("failed to list subscribed addresses", "error", err)
+		return
+	}
+
+	var missing []string
+	for _, address := range addresses {
+		if len(missing) == missingBalancesPerRun || ctx.Err() != nil {
+			break
+		}
+		_, ok, err := p.balances.storage.GetBalances(ctx, address)
+		if err != nil {
+			pollerErrors.WithLabelValues(p.chainLabel, "refresh_balances").Inc()
+			p.log.Error("failed to load balances", "address", address, "error", err)
+			continue
+		}
+		if !ok {
+			missing = append(missing, address)
+		}
+	}
+	if len(missing) == 0 || ctx.Err() != nil {
+		return
+	}
+
+	eth, err := p.balances.client.GetBalances(ctx, missing, number)
+	if err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "refresh_balances").Inc()
+		p.log.Error("failed to fetch balances", "addresses_count", len(missing), "block", fmt.Sprintf("%x", number), "error", err)
+		return
+	}
+	for i, address := range missing {
+		balances := ethereum.Balances{Address: address, Block: number, ETH: eth[i].String(), Tokens: map[string]string{}}
+		if err := p.balances.storage.SaveBalances(ctx, balances); err != nil {
+			pollerErrors.WithLabelValues(p.chainLabel, "refresh_balances").Inc()
+			p.log.Error("failed to save balances", "address", address, "block", fmt.Sprintf("%x", number), "error", err)
+		}
+	}
+}
+
+// refreshAddressBalances fetches ETH balance and balances of already tracked and new tokens as of the block
+func (p *TransactionPoller) refreshAddressBalances(ctx context.Context, address string, number int, tokens []string) error {
+	stored, _, err := p.balances.storage.GetBalances(ctx, address)
+	if err != nil {
+		return fmt.Errorf("load balances: %w", err)
+	}
+
+	eth, err := p.balances.client.GetBalance(ctx, address, number)
+	if err != nil {
+		return fmt.Errorf("fetch balance: %w", err)
+	}
+
+	updated := ethereum.Balances{
+		Address: address,
+		Block:   number,
+		ETH:     eth.String(),
+		Tokens:  make(map[string]string, len(stored.Tokens)+len(tokens)),
+	}
+	for token := range maps.Keys(stored.Tokens) {
+		tokens = append(tokens, token)
+	}
+	for _, token := range tokens {
+		if _, ok := updated.Tokens[token]; ok {
+			continue
+		}
+		balance, err := p.balances.client.GetTokenBalance(ctx, token, address, number)
+		if err != nil {
+			return fmt.Errorf("fetch balance of token %s: %w", token, err)
+		}
+		updated.Tokens[token] = balance.String()
+	}
+
+	if err := p.balances.storage.SaveBalances(ctx, updated); err != nil {
+		return fmt.Errorf("save balances: %w", err)
+	}
+	return nil
+}
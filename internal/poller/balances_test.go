@@ -0,0 +1,135 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+type MockBalancesClient struct {
+	GetBalanceFunc      func(ctx context.Context, address string, blockNumber int) (*big.Int, error)
+	GetBalancesFunc     func(ctx context.Context, addresses []string, blockNumber int) ([]*big.Int, error)
+	GetTokenBalanceFunc func(ctx context.Context, token string, owner string, blockNumber int) (*big.Int, error)
+}
+
+func (m *MockBalancesClient) GetBalance(ctx context.Context, address string, blockNumber int) (*big.Int, error) {
+	return m.GetBalanceFunc(ctx, address, blockNumber)
+}
+
+func (m *MockBalancesClient) GetBalances(ctx context.Context, addresses []string, blockNumber int) ([]*big.Int, error) {
+	return m.GetBalancesFunc(ctx, addresses, blockNumber)
+}
+
+func (m *MockBalancesClient) GetTokenBalance(ctx context.Context, token string, owner string, blockNumber int) (*big.Int, error) {
+	return m.GetTokenBalanceFunc(ctx, token, owner, blockNumber)
+}
+
+func TestRefreshBalances(t *testing.T) {
+	ctx := context.Background()
+	watched := "0x1234567890abcdef1234567890abcdef12345678"
+
+	client := &MockBalancesClient{
+		GetBalanceFunc: func(ctx context.Context, address string, blockNumber int) (*big.Int, error) {
+			return big.NewInt(int64(blockNumber)), nil
+		},
+		GetBalancesFunc: func(ctx context.Context, addresses []string, blockNumber int) ([]*big.Int, error) {
+			balances := make([]*big.Int, len(addresses))
+			for i := range addresses {
+				balances[i] = big.NewInt(int64(blockNumber))
+			}
+			return balances, nil
+		},
+		GetTokenBalanceFunc: func(ctx context.Context, token string, owner string, blockNumber int) (*big.Int, error) {
+			return big.NewInt(int64(blockNumber + 1)), nil
+		},
+	}
+
+	t.Run("new subscription", func(t *testing.T) {
+		s := storage.NewInMemoryStorage()
+		_ = s.Subscribe(ctx, watched)
+		p := NewTransactionPoller(s, s, nil, slog.Default(), WithBalances(client, s, s))
+
+		p.refreshMissingBalances(ctx, 100)
+
+		balances, ok, _ := s.GetBalances(ctx, watched)
+		if !ok || balances.Block != 100 || balances.ETH != "100" || len(balances.Tokens) != 0 {
+			t.Fatalf("unexpected balances %+v", balances)
+		}
+	})
+
+	t.Run("new subscriptions are fetched in bounded batches", func(t *testing.T) {
+		s := storage.NewInMemoryStorage()
+		for i := range missingBalancesPerRun + 50 {
+			_ = s.Subscribe(ctx, fmt.Sprintf("0x%040x", i+1))
+		}
+		var batches []int
+		batching := &MockBalancesClient{GetBalancesFunc: func(ctx context.Context, addresses []string, blockNumber int) ([]*big.Int, error) {
+			batches = append(batches, len(addresses))
+			return client.GetBalancesFunc(ctx, addresses, blockNumber)
+		}}
+		p := NewTransactionPoller(s, s, nil, slog.Default(), WithBalances(batching, s, s))
+
+		for range 3 {
+			p.refreshMissingBalances(ctx, 100)
+		}
+
+		if !slices.Equal(batches, []int{missingBalancesPerRun, 50}) {
+			t.Fatalf("expected a full batch and the rest, got %v", batches)
+		}
+	})
+
+	t.Run("nothing is fetched after shutdown", func(t *testing.T) {
+		s := storage.NewInMemoryStorage()
+		_ = s.Subscribe(ctx, watched)
+		p := NewTransactionPoller(s, s, nil, slog.Default(), WithBalances(&MockBalancesClient{}, s, s))
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		p.refreshMissingBalances(cancelled, 100)
+
+		if _, ok, _ := s.GetBalances(ctx, watched); ok {
+			t.Fatal("expected no balances fetched")
+		}
+	})
+
+	t.Run("tokens are tracked after first transfer", func(t *testing.T) {
+		s := storage.NewInMemoryStorage()
+		p := NewTransactionPoller(s, s, nil, slog.Default(), WithBalances(client, s, s))
+
+		p.refreshBalances(ctx, 100, []ethereum.AddressTx{
+			{Address: watched, Tx: ethereum.Transaction{Hash: "0x1"}},
+			{Address: watched, Tx: ethereum.Transaction{Hash: "0x2", Token: "0xtoken"}},
+		})
+		// ETH transfer only, token balance is refreshed as well
+		p.refreshBalances(ctx, 101, []ethereum.AddressTx{{Address: watched, Tx: ethereum.Transaction{Hash: "0x3"}}})
+
+		balances, _, _ := s.GetBalances(ctx, watched)
+		if balances.Block != 101 || balances.ETH != "101" || balances.Tokens["0xtoken"] != "102" {
+			t.Fatalf("unexpected balances %+v", balances)
+		}
+	})
+
+	t.Run("failed refresh keeps previous balances", func(t *testing.T) {
+		s := storage.NewInMemoryStorage()
+		p := NewTransactionPoller(s, s, nil, slog.Default(), WithBalances(client, s, s))
+		p.refreshBalances(ctx, 100, []ethereum.AddressTx{{Address: watched, Tx: ethereum.Transaction{Hash: "0x1"}}})
+
+		failing := &MockBalancesClient{GetBalanceFunc: func(ctx context.Context, address string, blockNumber int) (*big.Int, error) {
+			return nil, errors.New("rpc error")
+		}}
+		p.balances.client = failing
+		p.refreshBalances(ctx, 101, []ethereum.AddressTx{{Address: watched, Tx: ethereum.Transaction{Hash: "0x2"}}})
+
+		balances, _, _ := s.GetBalances(ctx, watched)
+		if balances.Block != 100 {
+			t.Fatalf("expected balances of block 100, got %+v", balances)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+// SubscriptionsStorage extends address subscriptions with subscriptions to contract events
+type SubscriptionsStorage interface {
+	AddressesStorage
+	// SubscribeEvents adds a normalized event filter, subscribing an existing filter again is a no-op
+	SubscribeEvents(ctx context.Context, filter ethereum.EventFilter) error
+	EventFilters(ctx context.Context) ([]ethereum.EventFilter, error)
+}
+
+// eventsBlockRange is the number of blocks events are fetched for with a single eth_getLogs request.
+// Providers limit both range and number of returned logs
+const eventsBlockRange = 100
+
+// WithEvents enables collecting logs matching event subscriptions. Logs are fetched for ranges of blocks
+// and committed together with transactions of their block
+func WithEvents(client LogsClient, subscriptions SubscriptionsStorage) Option {
+	return func(p *TransactionPoller) {
+		p.events = &events{client: client, subscriptions: subscriptions}
+	}
+}
+
+type events struct {
+	client        LogsClient
+	subscriptions SubscriptionsStorage
+}
+
+// blockEvents returns events of subscribed filters in blocks from fromBlock to toBlock inclusive by block number
+func (p *TransactionPoller) blockEvents(ctx context.Context, fromBlock, toBlock int) (map[int][]ethereum.Event, error) {
+	if p.events == nil {
+		return nil, nil
+	}
+
+	filters, err := p.events.subscriptions.EventFilters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list event filters: %w", err)
+	}
+
+	byBlock := make(map[int][]ethereum.Event)
+	for _, filter := range filters {
+		logs, err := p.events.client.GetLogs(ctx, filter.LogFilter(fromBlock, toBlock))
+		if err != nil {
+			pollerErrors.WithLabelValues(p.chainLabel, "get_event_logs").Inc()
+			return nil, fmt.Errorf("load logs of filter %s: %w", filter.ID, err)
+		}
+
+		for _, log := range logs {
+			if log.Removed {
+				continue
+			}
+			number, err := ethereum.ParseQuantity(log.BlockNumber)
+			if err != nil {
+				return nil, fmt.Errorf("log of transaction %s: %w", log.TransactionHash, err)
+			}
+			byBlock[number] = append(byBlock[number], ethereum.Event{FilterID: filter.ID, Log: log})
+		}
+	}
+	return byBlock, nil
+}
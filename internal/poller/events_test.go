@@ -0,0 +1,85 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	topic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+	newStorage := func(t *testing.T) (*storage.InMemoryStorage, ethereum.EventFilter) {
+		s := storage.NewInMemoryStorage()
+		filter, err := ethereum.EventFilter{Topics: []string{topic}}.Normalize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = s.SubscribeEvents(ctx, filter)
+		_ = s.SetCurrentBlock(ctx, 100)
+		return s, filter
+	}
+	ethClient := &MockEthClient{
+		GetBlockNumberFunc: func(ctx context.Context) (int, error) {
+			return 250, nil
+		},
+		GetBlockByNumberFunc: func(ctx context.Context, number int) (ethereum.EthereumBlock, error) {
+			return ethereum.EthereumBlock{}, nil
+		},
+	}
+
+	t.Run("logs are fetched for block ranges", func(t *testing.T) {
+		s, filter := newStorage(t)
+		var ranges []string
+		logsClient := &MockLogsClient{GetLogsFunc: func(ctx context.Context, f ethereum.LogFilter) ([]ethereum.Log, error) {
+			ranges = append(ranges, f.FromBlock+"-"+f.ToBlock)
+			if f.Topics[0][0] != topic {
+				t.Fatalf("unexpected topics %+v", f.Topics)
+			}
+			if f.FromBlock != "0x65" {
+				return nil, nil
+			}
+			return []ethereum.Log{
+				{TransactionHash: "0x1", LogIndex: "0x0", BlockNumber: "0x66"},
+				{TransactionHash: "0x2", LogIndex: "0x0", BlockNumber: "0x67", Removed: true},
+			}, nil
+		}}
+		p := NewTransactionPoller(s, s, ethClient, slog.Default(), WithEvents(logsClient, s))
+
+		if err := p.loadNewTransactions(ctx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+
+		// blocks 101-250
+		if len(ranges) != 2 || ranges[0] != "0x65-0xc8" || ranges[1] != "0xc9-0xfa" {
+			t.Fatalf("unexpected ranges %v", ranges)
+		}
+		events, _ := s.GetEvents(ctx, filter.ID, 0, 10)
+		if len(events) != 1 || events[0].TransactionHash != "0x1" || events[0].FilterID != filter.ID {
+			t.Fatalf("expected one event, got %+v", events)
+		}
+		if block, _ := s.GetCurrentBlock(ctx); block != 250 {
+			t.Fatalf("expected current block 250, got %d", block)
+		}
+	})
+
+	t.Run("failed range is retried", func(t *testing.T) {
+		s, _ := newStorage(t)
+		logsClient := &MockLogsClient{GetLogsFunc: func(ctx context.Context, f ethereum.LogFilter) ([]ethereum.Log, error) {
+			return nil, errors.New("too many results")
+		}}
+		p := NewTransactionPoller(s, s, ethClient, slog.Default(), WithEvents(logsClient, s))
+
+		if err := p.loadNewTransactions(ctx); err == nil {
+			t.Fatalf("expected error")
+		}
+		if block, _ := s.GetCurrentBlock(ctx); block != 100 {
+			t.Fatalf("expected current block not to advance, got %d", block)
+		}
+	})
+}
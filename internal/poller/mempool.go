@@ -0,0 +1,258 @@
+package poller
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+type PendingTransactionsStorage interface {
+	TransactionsStorage
+	SetTransactionStatus(ctx context.Context, address string, hash string, status ethereum.TransactionStatus) error
+}
+
+type MempoolClient interface {
+	NewPendingTransactionFilter(ctx context.Context) (string, error)
+	GetFilterChanges(ctx context.Context, filterID string) ([]string, error)
+	GetTransactionByHash(ctx context.Context, hash string) (ethereum.Transaction, bool, error)
+	GetTransactionCount(ctx context.Context, address string) (int, error)
+}
+
+const (
+	mempoolPollInterval = time.Second * 2
+	// how long a transaction unknown to the node stays pending before it is considered dropped.
+	// Load balanced public endpoints do not share mempools, so a single miss is not enough
+	defaultDropTimeout = time.Minute * 10
+)
+
+// pendingTx is a mempool transaction of subscribed addresses waiting to be mined
+type pendingTx struct {
+	tx        ethereum.Transaction
+	addresses []string
+	firstSeen time.Time
+}
+
+type MempoolOption func(*MempoolWatcher)
+
+// WithMempoolPublisher publishes pending transactions of subscribed addresses once they are stored
+func WithMempoolPublisher(publisher Publisher) MempoolOption {
+	return func(w *MempoolWatcher) {
+		w.publisher = publisher
+	}
+}
+
+// WithMempoolChainID sets the chain watched mempool belongs to, used to label metrics
+func WithMempoolChainID(chainID int) MempoolOption {
+	return func(w *MempoolWatcher) {
+		w.chainLabel = strconv.Itoa(chainID)
+	}
+}
+
+func NewMempoolWatcher(
+	transactionsStorage PendingTransactionsStorage,
+	addressesStorage AddressesStorage,
+	client MempoolClient,
+	log *slog.Logger,
+	options ...MempoolOption,
+) *MempoolWatcher {
+	w := &MempoolWatcher{
+		transactionsStorage: transactionsStorage,
+		addressesStorage:    addressesStorage,
+		client:              client,
+		log:                 log,
+		dropTimeout:         defaultDropTimeout,
+		pending:             make(map[string]pendingTx),
+		now:                 time.Now,
+		chainLabel:          strconv.Itoa(ethereum.MainnetChainID),
+	}
+
+	for _, option := range options {
+		option(w)
+	}
+
+	return w
+}
+
+// MempoolWatcher records pending transactions of subscribed addresses and reconciles them
+// once they are mined, replaced by another transaction with the same nonce or dropped.
+// Mined transactions are stored by TransactionPoller, which overrides the pending record.
+// Pending transactions are tracked in memory only, so tracking is lost on restart.
+type MempoolWatcher struct {
+	transactionsStorage PendingTransactionsStorage
+	addressesStorage    AddressesStorage
+	client              MempoolClient
+	log                 *slog.Logger
+	dropTimeout         time.Duration
+	// nil when pending transactions are not published
+	publisher Publisher
+
+	filterID string
+	// tx hash -> pending transaction
+	pending    map[string]pendingTx
+	now        func() time.Time
+	chainLabel string
+}
+
+// Start watches the mempool until ctx is cancelled
+func (w *MempoolWatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(mempoolPollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.watch(ctx)
+
+		select {
+		case <-ctx.Done():
+			w.log.Info("stopping mempool watcher")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *MempoolWatcher) watch(ctx context.Context) {
+	w.loadPendingTransactions(ctx)
+	w.reconcile(ctx)
+}
+
+func (w *MempoolWatcher) loadPendingTransactions(ctx context.Context) {
+	if w.filterID == "" {
+		filterID, err := w.client.NewPendingTransactionFilter(ctx)
+		if err != nil {
+			w.log.Error("failed to install pending transactions filter", "error", err)
+			return
+		}
+		w.filterID = filterID
+	}
+
+	hashes, err := w.client.GetFilterChanges(ctx, w.filterID)
+	if err != nil {
+		// filters expire on the node when not polled for a while, install a new one on the next run
+		w.log.Error("failed to get pending transactions filter changes", "filter_id", w.filterID, "error", err)
+		w.filterID = ""
+		return
+	}
+
+	for _, hash := range hashes {
+		if _, ok := w.pending[hash]; ok {
+			continue
+		}
+		w.loadPendingTransaction(ctx, hash)
+	}
+}
+
+func (w *MempoolWatcher) loadPendingTransaction(ctx context.Context, hash string) {
+	tx, found, err := w.client.GetTransactionByHash(ctx, hash)
+	if err != nil {
+		w.log.Error("failed to load pending transaction", "transaction_hash", hash, "error", err)
+		return
+	}
+
+	// already gone or mined, the latter is handled by the block poller
+	if !found || tx.BlockNumber != "" {
+		return
+	}
+
+	var addresses []string
+	for _, address := range participants(tx) {
+		subscribed, err := w.addressesStorage.IsSubscribed(ctx, address)
+		if err != nil {
+			w.log.Error("failed to check if address is subscribed", "address", address, "error", err)
+			return
+		}
+		if subscribed {
+			addresses = append(addresses, address)
+		}
+	}
+
+	if len(addresses) == 0 {
+		return
+	}
+
+	tx.Status = ethereum.TransactionStatusPending
+	addressTxs := make([]ethereum.AddressTx, 0, len(addresses))
+	saved := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		if err := w.transactionsStorage.SaveTransaction(ctx, address, tx); err != nil {
+			w.log.Error("failed to save pending transaction", "transaction_hash", hash, "address", address, "error", err)
+			continue
+		}
+		addressTxs = append(addressTxs, ethereum.AddressTx{Address: address, Tx: tx})
+		saved = append(saved, address)
+	}
+	if len(saved) == 0 {
+		return
+	}
+	if w.publisher != nil {
+		w.publisher.Publish(addressTxs)
+	}
+
+	// tracked with the addresses it was saved for, even if saving for others failed,
+	// so reconciliation resolves every stored copy once the transaction leaves the mempool
+	w.pending[hash] = pendingTx{tx: tx, addresses: saved, firstSeen: w.now()}
+	mempoolPendingGauge.WithLabelValues(w.chainLabel).Set(float64(len(w.pending)))
+	w.log.Debug("pending transaction saved", "transaction_hash", hash, "addresses", saved)
+}
+
+// reconcile checks tracked pending transactions and resolves the ones which left the mempool
+func (w *MempoolWatcher) reconcile(ctx context.Context) {
+	for hash, p := range w.pending {
+		tx, found, err := w.client.GetTransactionByHash(ctx, hash)
+		if err != nil {
+			w.log.Error("failed to load pending transaction", "transaction_hash", hash, "error", err)
+			continue
+		}
+
+		if found {
+			if tx.BlockNumber != "" {
+				w.log.Debug("pending transaction mined", "transaction_hash", hash, "block", tx.BlockNumber)
+				mempoolResolved.WithLabelValues(w.chainLabel, string(ethereum.TransactionStatusMined)).Inc()
+				delete(w.pending, hash)
+			}
+			continue
+		}
+
+		status, resolved := w.resolveMissing(ctx, p)
+		if !resolved {
+			continue
+		}
+
+		for _, address := range p.addresses {
+			if err := w.transactionsStorage.SetTransactionStatus(ctx, address, hash, status); err != nil {
+				w.log.Error("failed to update pending transaction status", "transaction_hash", hash, "address", address, "status", status, "error", err)
+			}
+		}
+
+		w.log.Info("pending transaction left mempool", "transaction_hash", hash, "status", status)
+		mempoolResolved.WithLabelValues(w.chainLabel, string(status)).Inc()
+		delete(w.pending, hash)
+	}
+	mempoolPendingGauge.WithLabelValues(w.chainLabel).Set(float64(len(w.pending)))
+}
+
+// resolveMissing decides what happened to a pending transaction the node does not know anymore.
+// If the sender's nonce moved past the transaction nonce, another transaction took its place
+func (w *MempoolWatcher) resolveMissing(ctx context.Context, p pendingTx) (ethereum.TransactionStatus, bool) {
+	txNonce, err := ethereum.ParseQuantity(p.tx.Nonce)
+	if err != nil {
+		w.log.Error("failed to parse pending transaction nonce", "transaction_hash", p.tx.Hash, "nonce", p.tx.Nonce, "error", err)
+	} else {
+		nonce, err := w.client.GetTransactionCount(ctx, p.tx.From)
+		if err != nil {
+			w.log.Error("failed to load sender nonce", "address", p.tx.From, "error", err)
+			return "", false
+		}
+
+		if nonce > txNonce {
+			return ethereum.TransactionStatusReplaced, true
+		}
+	}
+
+	if w.now().Sub(p.firstSeen) < w.dropTimeout {
+		return "", false
+	}
+	return ethereum.TransactionStatusDropped, true
+}
@@ -0,0 +1,266 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+func TestMempoolWatcher_LoadPendingTransactions(t *testing.T) {
+	ctx := context.Background()
+	pendingTx := ethereum.Transaction{Hash: "0x1", From: "0xabc", To: "0xdef", Nonce: "0x5"}
+
+	newWatcher := func(client *MockMempoolClient, txStorage *MockPendingTransactionsStorage) *MempoolWatcher {
+		addressesStorage := &MockAddressesStorage{
+			IsSubscribedFunc: func(ctx context.Context, address string) (bool, error) {
+				return address == "0xabc", nil
+			},
+		}
+		return NewMempoolWatcher(txStorage, addressesStorage, client, slog.Default())
+	}
+
+	t.Run("error installing filter", func(t *testing.T) {
+		client := &MockMempoolClient{
+			NewPendingTransactionFilterFunc: func(ctx context.Context) (string, error) {
+				return "", errors.New("filters not supported")
+			},
+		}
+		watcher := newWatcher(client, &MockPendingTransactionsStorage{})
+
+		watcher.loadPendingTransactions(ctx)
+		if watcher.filterID != "" {
+			t.Fatalf("expected no filter, got %s", watcher.filterID)
+		}
+	})
+
+	t.Run("expired filter is reinstalled", func(t *testing.T) {
+		installed := 0
+		client := &MockMempoolClient{
+			NewPendingTransactionFilterFunc: func(ctx context.Context) (string, error) {
+				installed++
+				return "0xfilter", nil
+			},
+			GetFilterChangesFunc: func(ctx context.Context, filterID string) ([]string, error) {
+				return nil, errors.New("filter not found")
+			},
+		}
+		watcher := newWatcher(client, &MockPendingTransactionsStorage{})
+
+		watcher.loadPendingTransactions(ctx)
+		watcher.loadPendingTransactions(ctx)
+		if installed != 2 {
+			t.Fatalf("expected filter to be installed twice, got %d", installed)
+		}
+	})
+
+	t.Run("pending transaction of subscribed address saved", func(t *testing.T) {
+		var saved []string
+		client := &MockMempoolClient{
+			NewPendingTransactionFilterFunc: func(ctx context.Context) (string, error) {
+				return "0xfilter", nil
+			},
+			GetFilterChangesFunc: func(ctx context.Context, filterID string) ([]string, error) {
+				return []string{"0x1", "0x2"}, nil
+			},
+			GetTransactionByHashFunc: func(ctx context.Context, hash string) (ethereum.Transaction, bool, error) {
+				if hash == "0x1" {
+					return pendingTx, true, nil
+				}
+				return ethereum.Transaction{Hash: hash, From: "0x111", To: "0x222"}, true, nil
+			},
+		}
+		txStorage := &MockPendingTransactionsStorage{}
+		txStorage.SaveTransactionFunc = func(ctx context.Context, address string, tx ethereum.Transaction) error {
+			if tx.Status != ethereum.TransactionStatusPending {
+				t.Fatalf("expected pending status, got %q", tx.Status)
+			}
+			saved = append(saved, address+":"+tx.Hash)
+			return nil
+		}
+		watcher := newWatcher(client, txStorage)
+
+		watcher.loadPendingTransactions(ctx)
+		if len(saved) != 1 || saved[0] != "0xabc:0x1" {
+			t.Fatalf("expected only 0x1 saved for 0xabc, got %v", saved)
+		}
+		if _, ok := watcher.pending["0x1"]; !ok {
+			t.Fatalf("expected 0x1 to be tracked")
+		}
+	})
+
+	t.Run("partially saved transaction still tracked", func(t *testing.T) {
+		client := &MockMempoolClient{
+			NewPendingTransactionFilterFunc: func(ctx context.Context) (string, error) {
+				return "0xfilter", nil
+			},
+			GetFilterChangesFunc: func(ctx context.Context, filterID string) ([]string, error) {
+				return []string{"0x1"}, nil
+			},
+			GetTransactionByHashFunc: func(ctx context.Context, hash string) (ethereum.Transaction, bool, error) {
+				return pendingTx, true, nil
+			},
+		}
+		txStorage := &MockPendingTransactionsStorage{}
+		txStorage.SaveTransactionFunc = func(ctx context.Context, address string, tx ethereum.Transaction) error {
+			if address == "0xdef" {
+				return errors.New("storage unavailable")
+			}
+			return nil
+		}
+		addressesStorage := &MockAddressesStorage{
+			IsSubscribedFunc: func(ctx context.Context, address string) (bool, error) {
+				return true, nil
+			},
+		}
+		watcher := NewMempoolWatcher(txStorage, addressesStorage, client, slog.Default())
+
+		watcher.loadPendingTransactions(ctx)
+		p, ok := watcher.pending["0x1"]
+		if !ok {
+			t.Fatalf("expected 0x1 to be tracked")
+		}
+		if len(p.addresses) != 1 || p.addresses[0] != "0xabc" {
+			t.Fatalf("expected 0x1 tracked for 0xabc only, got %v", p.addresses)
+		}
+	})
+
+	t.Run("unsaved transaction not tracked", func(t *testing.T) {
+		client := &MockMempoolClient{
+			NewPendingTransactionFilterFunc: func(ctx context.Context) (string, error) {
+				return "0xfilter", nil
+			},
+			GetFilterChangesFunc: func(ctx context.Context, filterID string) ([]string, error) {
+				return []string{"0x1"}, nil
+			},
+			GetTransactionByHashFunc: func(ctx context.Context, hash string) (ethereum.Transaction, bool, error) {
+				return pendingTx, true, nil
+			},
+		}
+		txStorage := &MockPendingTransactionsStorage{}
+		txStorage.SaveTransactionFunc = func(ctx context.Context, address string, tx ethereum.Transaction) error {
+			return errors.New("storage unavailable")
+		}
+		watcher := newWatcher(client, txStorage)
+
+		watcher.loadPendingTransactions(ctx)
+		if len(watcher.pending) != 0 {
+			t.Fatalf("expected nothing tracked, got %v", watcher.pending)
+		}
+	})
+
+	t.Run("already mined transaction skipped", func(t *testing.T) {
+		client := &MockMempoolClient{
+			NewPendingTransactionFilterFunc: func(ctx context.Context) (string, error) {
+				return "0xfilter", nil
+			},
+			GetFilterChangesFunc: func(ctx context.Context, filterID string) ([]string, error) {
+				return []string{"0x1"}, nil
+			},
+			GetTransactionByHashFunc: func(ctx context.Context, hash string) (ethereum.Transaction, bool, error) {
+				tx := pendingTx
+				tx.BlockNumber = "0x10"
+				return tx, true, nil
+			},
+		}
+		watcher := newWatcher(client, &MockPendingTransactionsStorage{})
+
+		watcher.loadPendingTransactions(ctx)
+		if len(watcher.pending) != 0 {
+			t.Fatalf("expected nothing tracked, got %v", watcher.pending)
+		}
+	})
+}
+
+func TestMempoolWatcher_Reconcile(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	tracked := pendingTx{
+		tx:        ethereum.Transaction{Hash: "0x1", From: "0xabc", Nonce: "0x5"},
+		addresses: []string{"0xabc"},
+		firstSeen: now,
+	}
+
+	tests := []struct {
+		name           string
+		tx             ethereum.Transaction
+		found          bool
+		senderNonce    int
+		elapsed        time.Duration
+		expectedStatus ethereum.TransactionStatus
+		expectTracked  bool
+	}{
+		{name: "still pending", tx: tracked.tx, found: true, expectTracked: true},
+		{name: "mined", tx: ethereum.Transaction{Hash: "0x1", BlockNumber: "0x10"}, found: true},
+		{name: "replaced", senderNonce: 6, expectedStatus: ethereum.TransactionStatusReplaced},
+		{name: "missing but not timed out", senderNonce: 5, elapsed: time.Minute, expectTracked: true},
+		{name: "dropped", senderNonce: 5, elapsed: defaultDropTimeout, expectedStatus: ethereum.TransactionStatusDropped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status ethereum.TransactionStatus
+			client := &MockMempoolClient{
+				GetTransactionByHashFunc: func(ctx context.Context, hash string) (ethereum.Transaction, bool, error) {
+					return tt.tx, tt.found, nil
+				},
+				GetTransactionCountFunc: func(ctx context.Context, address string) (int, error) {
+					return tt.senderNonce, nil
+				},
+			}
+			txStorage := &MockPendingTransactionsStorage{
+				SetTransactionStatusFunc: func(ctx context.Context, address string, hash string, s ethereum.TransactionStatus) error {
+					status = s
+					return nil
+				},
+			}
+			watcher := NewMempoolWatcher(txStorage, &MockAddressesStorage{}, client, slog.Default())
+			watcher.now = func() time.Time { return now.Add(tt.elapsed) }
+			watcher.pending["0x1"] = tracked
+
+			watcher.reconcile(ctx)
+
+			if status != tt.expectedStatus {
+				t.Fatalf("expected status %q, got %q", tt.expectedStatus, status)
+			}
+			if _, ok := watcher.pending["0x1"]; ok != tt.expectTracked {
+				t.Fatalf("expected tracked %v, got %v", tt.expectTracked, ok)
+			}
+		})
+	}
+}
+
+type MockPendingTransactionsStorage struct {
+	MockTransactionsStorage
+	SetTransactionStatusFunc func(ctx context.Context, address string, hash string, status ethereum.TransactionStatus) error
+}
+
+func (m *MockPendingTransactionsStorage) SetTransactionStatus(ctx context.Context, address string, hash string, status ethereum.TransactionStatus) error {
+	return m.SetTransactionStatusFunc(ctx, address, hash, status)
+}
+
+type MockMempoolClient struct {
+	NewPendingTransactionFilterFunc func(ctx context.Context) (string, error)
+	GetFilterChangesFunc            func(ctx context.Context, filterID string) ([]string, error)
+	GetTransactionByHashFunc        func(ctx context.Context, hash string) (ethereum.Transaction, bool, error)
+	GetTransactionCountFunc         func(ctx context.Context, address string) (int, error)
+}
+
+func (m *MockMempoolClient) NewPendingTransactionFilter(ctx context.Context) (string, error) {
+	return m.NewPendingTransactionFilterFunc(ctx)
+}
+
+func (m *MockMempoolClient) GetFilterChanges(ctx context.Context, filterID string) ([]string, error) {
+	return m.GetFilterChangesFunc(ctx, filterID)
+}
+
+func (m *MockMempoolClient) GetTransactionByHash(ctx context.Context, hash string) (ethereum.Transaction, bool, error) {
+	return m.GetTransactionByHashFunc(ctx, hash)
+}
+
+func (m *MockMempoolClient) GetTransactionCount(ctx context.Context, address string) (int, error) {
+	return m.GetTransactionCountFunc(ctx, address)
+}
@@ -0,0 +1,94 @@
+package poller
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "eth_tx_parser"
+
+var (
+	currentBlockGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "current_block",
+		Help:      "Last block processed by the poller.",
+	}, []string{"chain_id"})
+
+	chainHeadGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "chain_head_block",
+		Help:      "Latest block number reported by the node.",
+	}, []string{"chain_id"})
+
+	lagGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "lag_blocks",
+		Help:      "Number of blocks the poller is behind the chain head.",
+	}, []string{"chain_id"})
+
+	blocksProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "blocks_processed_total",
+		Help:      "Number of processed blocks.",
+	}, []string{"chain_id"})
+
+	transactionsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "transactions_processed_total",
+		Help:      "Number of transactions seen in processed blocks.",
+	}, []string{"chain_id"})
+
+	transactionsSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "transactions_saved_total",
+		Help:      "Number of transactions saved for subscribed addresses.",
+	}, []string{"chain_id"})
+
+	bloomSkippedBlocks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "bloom_skipped_blocks_total",
+		Help:      "Number of blocks which logs were not fetched as their logs bloom excludes transfers of subscribed addresses.",
+	}, []string{"chain_id"})
+
+	pollerErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "errors_total",
+		Help:      "Number of poller errors by failed stage.",
+	}, []string{"chain_id", "stage"})
+
+	mempoolPendingGauge = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "mempool",
+		Name:      "pending_transactions",
+		Help:      "Number of tracked pending transactions of subscribed addresses.",
+	}, []string{"chain_id"})
+
+	mempoolResolved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "mempool",
+		Name:      "resolved_transactions_total",
+		Help:      "Number of pending transactions which left the mempool by final status.",
+	}, []string{"chain_id", "status"})
+
+	balanceDiscrepancies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "reconciler",
+		Name:      "balance_discrepancies_total",
+		Help:      "Number of balances reconstructed from indexed history which did not match the node balance.",
+	}, []string{"chain_id"})
+
+	eventsSaved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Subsystem: "poller",
+		Name:      "events_saved_total",
+		Help:      "Number of logs saved for event subscriptions.",
+	}, []string{"chain_id"})
+)
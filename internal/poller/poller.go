@@ -2,8 +2,11 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
@@ -20,7 +23,9 @@ type AddressesStorage interface {
 }
 
 type BlocksStorage interface {
-	SetCurrentBlock(ctx context.Context, block int) error
+	// CommitBlock stores transactions and events of a processed block and marks the block as the current one.
+	// Either all succeed or nothing is written, so a failed block can be safely processed again
+	CommitBlock(ctx context.Context, block int, txs []ethereum.AddressTx, events []ethereum.Event) error
 	GetCurrentBlock(ctx context.Context) (int, error)
 }
 
@@ -29,101 +34,282 @@ type EthClient interface {
 	GetBlockByNumber(ctx context.Context, blockNumber int) (ethereum.EthereumBlock, error)
 }
 
+// Publisher is notified about transactions of subscribed addresses once they are stored
+type Publisher interface {
+	Publish(txs []ethereum.AddressTx)
+}
+
+type Option func(*TransactionPoller)
+
+// WithPublisher publishes transactions of every committed block, e.g. to stream them to API clients
+func WithPublisher(publisher Publisher) Option {
+	return func(p *TransactionPoller) {
+		p.publisher = publisher
+	}
+}
+
+// WithChainID sets the chain polled blocks belong to, used to label metrics
+func WithChainID(chainID int) Option {
+	return func(p *TransactionPoller) {
+		p.chainLabel = strconv.Itoa(chainID)
+	}
+}
+
+// defaultPollInterval is the block time of mainnet
+const defaultPollInterval = time.Second * 12
+
+// WithPollInterval sets how often new blocks are polled, e.g. to follow chains with shorter block times
+func WithPollInterval(interval time.Duration) Option {
+	return func(p *TransactionPoller) {
+		p.pollInterval = interval
+	}
+}
+
 func NewTransactionPoller(
-	transactionsStorage TransactionsStorage,
 	addressesStorage AddressesStorage,
 	blocksStorage BlocksStorage,
 	ethClient EthClient,
 	log *slog.Logger,
-) TransactionPoller {
-	return TransactionPoller{
-		transactionsStorage: transactionsStorage,
-		addressesStorage:    addressesStorage,
-		blocksStorage:       blocksStorage,
-		ethClient:           ethClient,
-		log:                 log,
+	options ...Option,
+) *TransactionPoller {
+	p := &TransactionPoller{
+		addressesStorage: addressesStorage,
+		blocksStorage:    blocksStorage,
+		ethClient:        ethClient,
+		log:              log,
+		chainLabel:       strconv.Itoa(ethereum.MainnetChainID),
+		pollInterval:     defaultPollInterval,
+	}
+
+	for _, option := range options {
+		option(p)
 	}
+
+	return p
 }
 
 type TransactionPoller struct {
-	transactionsStorage TransactionsStorage
-	addressesStorage    AddressesStorage
-	blocksStorage       BlocksStorage
-	ethClient           EthClient
-	log                 *slog.Logger
+	addressesStorage AddressesStorage
+	blocksStorage    BlocksStorage
+	ethClient        EthClient
+	log              *slog.Logger
+	// nil when token transfers indexing is disabled
+	transfers *tokenTransfers
+	// nil when balances tracking is disabled
+	balances *balances
+	// nil when receipts are not recorded
+	receipts ReceiptsClient
+	// nil when event subscriptions are disabled
+	events *events
+	// nil when committed transactions are not published
+	publisher    Publisher
+	chainLabel   string
+	pollInterval time.Duration
+
+	mu     sync.RWMutex
+	status Status
 }
 
-func (p TransactionPoller) Start(ctx context.Context) {
-	ticker := time.NewTicker(time.Second * 12)
+// Start polls new blocks until ctx is cancelled. A block which processing has started is finished
+// before Start returns, so the caller can wait for it to return before closing storage
+func (p *TransactionPoller) Start(ctx context.Context) error {
+	ticker := time.NewTicker(p.pollInterval)
 	// for go versions prior 1.23
 	defer ticker.Stop()
 
 	for {
+		p.recordRun(p.loadNewTransactions(ctx))
+
 		select {
 		case <-ctx.Done():
 			p.log.Info("stopping poller")
-			return
-		default:
-			p.loadNewTransactions(ctx)
-			<-ticker.C
+			return nil
+		case <-ticker.C:
 		}
 	}
 }
 
-func (p TransactionPoller) loadNewTransactions(ctx context.Context) {
+// loadNewTransactions processes blocks up to the chain head. Returned error marks the run as failed.
+// Processing stops on the first failed block, which is retried on the next run
+func (p *TransactionPoller) loadNewTransactions(ctx context.Context) error {
 	latestBlock, err := p.ethClient.GetBlockNumber(ctx)
 	if err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "get_block_number").Inc()
 		p.log.Error("error fetching latest block", "error", err)
-		return
+		return fmt.Errorf("fetch latest block: %w", err)
 	}
+	chainHeadGauge.WithLabelValues(p.chainLabel).Set(float64(latestBlock))
+	p.setChainHead(latestBlock)
 
 	currentBlock, err := p.blocksStorage.GetCurrentBlock(ctx)
 	if err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "get_current_block").Inc()
 		p.log.Error("failed to load last processed block", "error", err)
-		return
+		return fmt.Errorf("load last processed block: %w", err)
 	}
 
 	if currentBlock == 0 {
 		currentBlock = latestBlock - 1
 	}
 
-	// Process new blocks
-	for i := currentBlock + 1; i <= latestBlock; i++ {
-		block, err := p.ethClient.GetBlockByNumber(ctx, i)
+	if err := p.processBlocks(ctx, currentBlock+1, latestBlock, latestBlock); err != nil || ctx.Err() != nil {
+		return err
+	}
+
+	p.refreshMissingBalances(ctx, latestBlock)
+	return nil
+}
+
+// ProcessRange processes blocks from fromBlock to toBlock inclusive once, the same way the poll loop does,
+// e.g. to scan a range of blocks into a fresh storage without running the service. Processing stops on
+// the first failed block, an interrupted range returns the error of ctx
+func (p *TransactionPoller) ProcessRange(ctx context.Context, fromBlock, toBlock int) error {
+	if err := p.processBlocks(ctx, fromBlock, toBlock, toBlock); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// processBlocks processes blocks from fromBlock to toBlock, head is the chain head the lag is measured to.
+// Processing stops without an error once shutdown is requested
+func (p *TransactionPoller) processBlocks(ctx context.Context, fromBlock, toBlock, head int) error {
+	// a started block is processed to the end even if shutdown is requested meanwhile,
+	// otherwise some of its transactions could be saved while the block is not marked as processed
+	blockCtx := context.WithoutCancel(ctx)
+
+	// Process new blocks, events are fetched for ranges of blocks at once
+	for from := fromBlock; from <= toBlock; from += eventsBlockRange {
+		to := min(from+eventsBlockRange-1, toBlock)
+		events, err := p.blockEvents(ctx, from, to)
+		if err != nil && ctx.Err() != nil {
+			p.log.Info("shutdown requested, stopping blocks processing", "block", fmt.Sprintf("%x", from))
+			return nil
+		}
 		if err != nil {
-			p.log.Error("failed to load block", "block", fmt.Sprintf("%x", i), "error", err)
-			return
+			p.log.Error("failed to load events", "from_block", fmt.Sprintf("%x", from), "to_block", fmt.Sprintf("%x", to), "error", err)
+			return fmt.Errorf("load events: %w", err)
 		}
 
-		p.log.Info("processing block with new transactions", "block", fmt.Sprintf("%x", i), "transactions_count", len(block.Transactions))
+		for i := from; i <= to; i++ {
+			if ctx.Err() != nil {
+				p.log.Info("shutdown requested, stopping blocks processing", "block", fmt.Sprintf("%x", i))
+				return nil
+			}
+
+			if err := p.processBlock(blockCtx, i, events[i]); err != nil {
+				return err
+			}
 
-		// Process transactions in the block
-		for _, tx := range block.Transactions {
-			p.saveTxForAddress(ctx, tx, tx.To)
-			p.saveTxForAddress(ctx, tx, tx.From)
+			currentBlockGauge.WithLabelValues(p.chainLabel).Set(float64(i))
+			lagGauge.WithLabelValues(p.chainLabel).Set(float64(head - i))
 		}
+	}
+	return nil
+}
+
+// processBlock saves block transactions of subscribed addresses and events and advances the current block
+func (p *TransactionPoller) processBlock(ctx context.Context, number int, events []ethereum.Event) error {
+	block, err := p.ethClient.GetBlockByNumber(ctx, number)
+	if errors.Is(err, ethereum.ErrBlockNotFound) {
+		// the block is not committed, so it is requested again on the next run
+		p.log.Warn("block not served by the node yet", "block", fmt.Sprintf("%x", number))
+		return fmt.Errorf("load block %d: %w", number, err)
+	}
+	if err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "get_block").Inc()
+		p.log.Error("failed to load block", "block", fmt.Sprintf("%x", number), "error", err)
+		return fmt.Errorf("load block %d: %w", number, err)
+	}
+
+	p.log.Info("processing block with new transactions", "block", fmt.Sprintf("%x", number), "transactions_count", len(block.Transactions))
+
+	addressTxs, err := p.blockTransactions(ctx, number, block, p.isSubscribed)
+	if err != nil {
+		return err
+	}
+
+	if err := p.blocksStorage.CommitBlock(ctx, number, addressTxs, events); err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "commit_block").Inc()
+		p.log.Error("failed to commit block", "block", fmt.Sprintf("%x", number), "error", err)
+		return fmt.Errorf("commit block %d: %w", number, err)
+	}
+
+	if p.publisher != nil && len(addressTxs) > 0 {
+		p.publisher.Publish(addressTxs)
+	}
+	p.refreshBalances(ctx, number, addressTxs)
+
+	blocksProcessed.WithLabelValues(p.chainLabel).Inc()
+	transactionsProcessed.WithLabelValues(p.chainLabel).Add(float64(len(block.Transactions)))
+	transactionsSaved.WithLabelValues(p.chainLabel).Add(float64(len(addressTxs)))
+	eventsSaved.WithLabelValues(p.chainLabel).Add(float64(len(events)))
+	return nil
+}
 
-		// Update the current block
-		if err := p.blocksStorage.SetCurrentBlock(ctx, i); err != nil {
-			p.log.Error("failed to set current processed block", "block", fmt.Sprintf("%x", i), "error", err)
+// addressMatcher tells whether transactions of an address are indexed
+type addressMatcher func(ctx context.Context, address string) (bool, error)
+
+// blockTransactions returns transactions and token transfers of the block bound to addresses accepted by match,
+// with block time and receipts attached. Nothing is stored
+func (p *TransactionPoller) blockTransactions(ctx context.Context, number int, block ethereum.EthereumBlock, match addressMatcher) ([]ethereum.AddressTx, error) {
+	var addressTxs []ethereum.AddressTx
+	for _, tx := range block.Transactions {
+		tx.Status = ethereum.TransactionStatusMined
+		matched, err := bindParticipants(ctx, tx, match)
+		if err != nil {
+			return nil, fmt.Errorf("process block %d: %w", number, err)
 		}
+		addressTxs = append(addressTxs, matched...)
+	}
+
+	transfers, err := p.tokenTransfers(ctx, number, block, match)
+	if err != nil {
+		p.log.Error("failed to process token transfers", "block", fmt.Sprintf("%x", number), "error", err)
+		return nil, fmt.Errorf("process block %d token transfers: %w", number, err)
+	}
+	addressTxs = append(addressTxs, transfers...)
+	for i := range addressTxs {
+		addressTxs[i].Tx.BlockTimestamp = block.Timestamp
+	}
+
+	if err := p.attachReceipts(ctx, addressTxs); err != nil {
+		p.log.Error("failed to load receipts", "block", fmt.Sprintf("%x", number), "error", err)
+		return nil, fmt.Errorf("process block %d receipts: %w", number, err)
 	}
+	return addressTxs, nil
 }
 
-func (p TransactionPoller) saveTxForAddress(ctx context.Context, tx ethereum.Transaction, address string) {
+// isSubscribed matches subscribed addresses
+func (p *TransactionPoller) isSubscribed(ctx context.Context, address string) (bool, error) {
 	subscribed, err := p.addressesStorage.IsSubscribed(ctx, address)
 	if err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "check_subscription").Inc()
 		p.log.Error("failed to check if address is subscribed", "address", address, "error", err)
-		return
+		return false, fmt.Errorf("check subscription of %s: %w", address, err)
 	}
+	return subscribed, nil
+}
 
-	if !subscribed {
-		return
+// bindParticipants binds the transaction to each of its participants accepted by match
+func bindParticipants(ctx context.Context, tx ethereum.Transaction, match addressMatcher) ([]ethereum.AddressTx, error) {
+	var matched []ethereum.AddressTx
+	for _, address := range participants(tx) {
+		ok, err := match(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matched = append(matched, ethereum.AddressTx{Address: address, Tx: tx})
+		}
 	}
+	return matched, nil
+}
 
-	if err := p.transactionsStorage.SaveTransaction(ctx, address, tx); err != nil {
-		p.log.Error("failed to save transaction", "transaction_hash", tx.Hash, "address", address, "error", err)
+// participants returns distinct addresses involved in a transaction, so a self-transfer
+// is stored once. Recipient is empty for contract creation transactions
+func participants(tx ethereum.Transaction) []string {
+	if tx.To == "" || tx.To == tx.From {
+		return []string{tx.From}
 	}
-
-	p.log.Debug("transaction saved for address", "address", address, "transaction_hash", tx.Hash)
+	return []string{tx.To, tx.From}
 }
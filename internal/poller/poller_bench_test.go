@@ -0,0 +1,63 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+// BenchmarkProcessBlock measures per-block throughput of subscription checks and commit
+// for a busy block against large watchlists
+func BenchmarkProcessBlock(b *testing.B) {
+	const txsPerBlock = 300
+
+	for _, watched := range []int{1_000, 100_000} {
+		b.Run(fmt.Sprintf("%d watched addresses", watched), func(b *testing.B) {
+			ctx := context.Background()
+			inMemStorage := storage.NewInMemoryStorage()
+			for i := 0; i < watched; i++ {
+				if err := inMemStorage.Subscribe(ctx, benchAddress(i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			// every 100th transaction touches a watched address
+			block := ethereum.EthereumBlock{Transactions: make([]ethereum.Transaction, txsPerBlock)}
+			for i := range block.Transactions {
+				from := benchAddress(watched + 2*i)
+				if i%100 == 0 {
+					from = benchAddress(i)
+				}
+				block.Transactions[i] = ethereum.Transaction{
+					Hash: fmt.Sprintf("0x%064x", i),
+					From: from,
+					To:   benchAddress(watched + 2*i + 1),
+				}
+			}
+
+			mockEthClient := &MockEthClient{
+				GetBlockByNumberFunc: func(ctx context.Context, number int) (ethereum.EthereumBlock, error) {
+					return block, nil
+				},
+			}
+			p := NewTransactionPoller(inMemStorage, inMemStorage, mockEthClient, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := p.processBlock(ctx, i+1, nil); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*txsPerBlock)/b.Elapsed().Seconds(), "txs/s")
+		})
+	}
+}
+
+func benchAddress(i int) string {
+	return fmt.Sprintf("0x%040x", i)
+}
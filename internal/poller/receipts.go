@@ -0,0 +1,53 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+type ReceiptsClient interface {
+	GetTransactionReceipt(ctx context.Context, hash string) (ethereum.Receipt, error)
+}
+
+// WithReceipts records gas fees and failure of plain transactions of subscribed addresses from their receipts,
+// so balances can be reconstructed from indexed history
+func WithReceipts(client ReceiptsClient) Option {
+	return func(p *TransactionPoller) {
+		p.receipts = client
+	}
+}
+
+// attachReceipts fills receipt fields of plain transactions. Token transfers share the receipt
+// of their transaction, fees are accounted once on the plain transaction
+func (p *TransactionPoller) attachReceipts(ctx context.Context, addressTxs []ethereum.AddressTx) error {
+	if p.receipts == nil {
+		return nil
+	}
+
+	// a transaction between two subscribed addresses is saved for both of them
+	receipts := make(map[string]ethereum.Receipt)
+	for i := range addressTxs {
+		tx := &addressTxs[i].Tx
+		if tx.Token != "" {
+			continue
+		}
+
+		receipt, ok := receipts[tx.Hash]
+		if !ok {
+			var err error
+			receipt, err = p.receipts.GetTransactionReceipt(ctx, tx.Hash)
+			if err != nil {
+				pollerErrors.WithLabelValues(p.chainLabel, "get_receipt").Inc()
+				return fmt.Errorf("load receipt of %s: %w", tx.Hash, err)
+			}
+			receipts[tx.Hash] = receipt
+		}
+
+		tx.GasUsed = receipt.GasUsed
+		tx.EffectiveGasPrice = receipt.EffectiveGasPrice
+		tx.Failed = receipt.Failed()
+	}
+	return nil
+}
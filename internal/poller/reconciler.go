@@ -0,0 +1,153 @@
+package poller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ledger"
+)
+
+type ReconcileStorage interface {
+	SubscribedAddressesLister
+	GetCurrentBlock(ctx context.Context) (int, error)
+	GetTransactions(ctx context.Context, address string) ([]ethereum.Transaction, error)
+	GetBalanceSnapshots(ctx context.Context, address string) ([]ethereum.Balances, error)
+	SaveBalances(ctx context.Context, balances ethereum.Balances) error
+	SaveDiscrepancy(ctx context.Context, discrepancy ethereum.BalanceDiscrepancy) error
+}
+
+type BalanceClient interface {
+	GetBalance(ctx context.Context, address string, blockNumber int) (*big.Int, error)
+}
+
+const reconcileInterval = time.Minute * 10
+
+type ReconcilerOption func(*BalanceReconciler)
+
+// WithReconcilerChainID sets the chain reconciled balances belong to, used to label metrics
+func WithReconcilerChainID(chainID int) ReconcilerOption {
+	return func(r *BalanceReconciler) {
+		r.chainLabel = strconv.Itoa(chainID)
+	}
+}
+
+func NewBalanceReconciler(storage ReconcileStorage, client BalanceClient, log *slog.Logger, options ...ReconcilerOption) *BalanceReconciler {
+	r := &BalanceReconciler{
+		storage:    storage,
+		client:     client,
+		log:        log,
+		chainLabel: strconv.Itoa(ethereum.MainnetChainID),
+	}
+
+	for _, option := range options {
+		option(r)
+	}
+
+	return r
+}
+
+// BalanceReconciler periodically compares ETH balances reconstructed from indexed history with the ones
+// reported by the node as of the current block. A mismatch is recorded as a discrepancy and the node
+// balance becomes a new snapshot, so history after it is reconstructed from the correct amount
+type BalanceReconciler struct {
+	storage    ReconcileStorage
+	client     BalanceClient
+	log        *slog.Logger
+	chainLabel string
+}
+
+// Start reconciles balances until ctx is cancelled
+func (r *BalanceReconciler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.log.Info("stopping balance reconciler")
+			return nil
+		case <-ticker.C:
+		}
+
+		r.reconcile(ctx)
+	}
+}
+
+func (r *BalanceReconciler) reconcile(ctx context.Context) {
+	block, err := r.storage.GetCurrentBlock(ctx)
+	if err != nil {
+		r.log.Error("failed to load last processed block", "error", err)
+		return
+	}
+
+	addresses, err := r.storage.SubscribedAddresses(ctx)
+	if err != nil {
+		r.log.Error("failed to list subscribed addresses", "error", err)
+		return
+	}
+
+	for _, address := range addresses {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := r.reconcileAddress(ctx, address, block); err != nil {
+			pollerErrors.WithLabelValues(r.chainLabel, "reconcile_balance").Inc()
+			r.log.Error("failed to reconcile balance", "address", address, "block", fmt.Sprintf("%x", block), "error", err)
+		}
+	}
+}
+
+func (r *BalanceReconciler) reconcileAddress(ctx context.Context, address string, block int) error {
+	snapshots, err := r.storage.GetBalanceSnapshots(ctx, address)
+	if err != nil {
+		return fmt.Errorf("load balance snapshots: %w", err)
+	}
+	// nothing to reconcile before the first snapshot or at the snapshot block itself
+	if len(snapshots) == 0 || snapshots[len(snapshots)-1].Block >= block {
+		return nil
+	}
+
+	txs, err := r.storage.GetTransactions(ctx, address)
+	if err != nil {
+		return fmt.Errorf("load transactions: %w", err)
+	}
+
+	reconstructed, err := ledger.BalancesAt(address, snapshots, txs, block)
+	if err != nil {
+		return fmt.Errorf("reconstruct balance: %w", err)
+	}
+
+	actual, err := r.client.GetBalance(ctx, address, block)
+	if err != nil {
+		return fmt.Errorf("fetch balance: %w", err)
+	}
+
+	if reconstructed.ETH == actual.String() {
+		return nil
+	}
+
+	discrepancy := ethereum.BalanceDiscrepancy{
+		Address:       address,
+		Block:         block,
+		Reconstructed: reconstructed.ETH,
+		Actual:        actual.String(),
+	}
+	r.log.Warn("balance discrepancy detected", "address", address, "block", fmt.Sprintf("%x", block),
+		"reconstructed", discrepancy.Reconstructed, "actual", discrepancy.Actual)
+	balanceDiscrepancies.WithLabelValues(r.chainLabel).Inc()
+
+	if err := r.storage.SaveDiscrepancy(ctx, discrepancy); err != nil {
+		return fmt.Errorf("save discrepancy: %w", err)
+	}
+
+	reconstructed.ETH = actual.String()
+	if err := r.storage.SaveBalances(ctx, reconstructed); err != nil {
+		return fmt.Errorf("save balances: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package poller
+
+import (
+	"context"
+	"log/slog"
+	"math/big"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+type MockBalanceClient struct {
+	GetBalanceFunc func(ctx context.Context, address string, blockNumber int) (*big.Int, error)
+}
+
+func (m *MockBalanceClient) GetBalance(ctx context.Context, address string, blockNumber int) (*big.Int, error) {
+	return m.GetBalanceFunc(ctx, address, blockNumber)
+}
+
+func TestReconcileBalances(t *testing.T) {
+	ctx := context.Background()
+	watched := "0x1234567890abcdef1234567890abcdef12345678"
+	other := "0xabcdef1234567890abcdef1234567890abcdef12"
+
+	newStorage := func() *storage.InMemoryStorage {
+		s := storage.NewInMemoryStorage()
+		_ = s.Subscribe(ctx, watched)
+		_ = s.SaveBalances(ctx, ethereum.Balances{Address: watched, Block: 100, ETH: "1000"})
+		_ = s.CommitBlock(ctx, 110, []ethereum.AddressTx{{Address: watched, Tx: ethereum.Transaction{
+			Hash: "0x1", From: other, To: watched, Value: "0x64", BlockNumber: "0x65", Status: ethereum.TransactionStatusMined,
+		}}}, nil)
+		return s
+	}
+
+	t.Run("matching balance", func(t *testing.T) {
+		s := newStorage()
+		client := &MockBalanceClient{GetBalanceFunc: func(ctx context.Context, address string, blockNumber int) (*big.Int, error) {
+			if blockNumber != 110 {
+				t.Fatalf("expected balance of current block 110, got %d", blockNumber)
+			}
+			return big.NewInt(1100), nil
+		}}
+
+		NewBalanceReconciler(s, client, slog.Default()).reconcile(ctx)
+
+		discrepancies, _ := s.GetDiscrepancies(ctx, watched)
+		if len(discrepancies) != 0 {
+			t.Fatalf("expected no discrepancies, got %+v", discrepancies)
+		}
+	})
+
+	t.Run("missed internal transfer", func(t *testing.T) {
+		s := newStorage()
+		client := &MockBalanceClient{GetBalanceFunc: func(ctx context.Context, address string, blockNumber int) (*big.Int, error) {
+			return big.NewInt(1500), nil
+		}}
+
+		NewBalanceReconciler(s, client, slog.Default()).reconcile(ctx)
+
+		discrepancies, _ := s.GetDiscrepancies(ctx, watched)
+		if len(discrepancies) != 1 || discrepancies[0].Reconstructed != "1100" || discrepancies[0].Actual != "1500" {
+			t.Fatalf("expected discrepancy of 1100 and 1500, got %+v", discrepancies)
+		}
+		// node balance is the new anchor
+		balances, _, _ := s.GetBalances(ctx, watched)
+		if balances.Block != 110 || balances.ETH != "1500" {
+			t.Fatalf("expected snapshot of node balance at block 110, got %+v", balances)
+		}
+	})
+}
+
+type MockReceiptsClient struct {
+	GetTransactionReceiptFunc func(ctx context.Context, hash string) (ethereum.Receipt, error)
+}
+
+func (m *MockReceiptsClient) GetTransactionReceipt(ctx context.Context, hash string) (ethereum.Receipt, error) {
+	return m.GetTransactionReceiptFunc(ctx, hash)
+}
+
+func TestAttachReceipts(t *testing.T) {
+	calls := 0
+	client := &MockReceiptsClient{GetTransactionReceiptFunc: func(ctx context.Context, hash string) (ethereum.Receipt, error) {
+		calls++
+		return ethereum.Receipt{TransactionHash: hash, GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00", Status: "0x0"}, nil
+	}}
+	p := NewTransactionPoller(nil, nil, nil, slog.Default(), WithReceipts(client))
+
+	addressTxs := []ethereum.AddressTx{
+		{Address: "0xa", Tx: ethereum.Transaction{Hash: "0x1", From: "0xa", To: "0xb"}},
+		{Address: "0xb", Tx: ethereum.Transaction{Hash: "0x1", From: "0xa", To: "0xb"}},
+		{Address: "0xa", Tx: ethereum.Transaction{Hash: "0x1", From: "0xa", To: "0xc", Token: "0xtoken", LogIndex: "0x0"}},
+	}
+	if err := p.attachReceipts(context.Background(), addressTxs); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("expected receipt fetched once, got %d", calls)
+	}
+	for _, addressTx := range addressTxs[:2] {
+		fee, ok := addressTx.Tx.Fee()
+		if !ok || fee.String() != "21000000000000" || !addressTx.Tx.Failed {
+			t.Fatalf("unexpected receipt fields %+v", addressTx.Tx)
+		}
+	}
+	if addressTxs[2].Tx.GasUsed != "" {
+		t.Fatalf("expected token transfer without receipt fields, got %+v", addressTxs[2].Tx)
+	}
+}
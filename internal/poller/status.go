@@ -0,0 +1,72 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Status is a snapshot of the poller progress
+type Status struct {
+	// LastRun is when the last polling iteration finished
+	LastRun time.Time
+	// LastSuccess is when the last iteration processed all blocks up to the chain head without errors
+	LastSuccess time.Time
+	// LastError of the last iteration, nil if it succeeded
+	LastError error
+	// ChainHead is the latest block number reported by the node
+	ChainHead int
+}
+
+// Status returns the poller progress
+func (p *TransactionPoller) Status() Status {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+func (p *TransactionPoller) setChainHead(block int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status.ChainHead = block
+}
+
+func (p *TransactionPoller) recordRun(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.status.LastRun = time.Now()
+	p.status.LastError = err
+	if err == nil {
+		p.status.LastSuccess = p.status.LastRun
+	}
+}
+
+// CheckSync returns an error when the poller is not in sync with the chain: its last successful
+// iteration is older than maxStaleness or the last processed block is more than maxLag blocks behind the head
+func (p *TransactionPoller) CheckSync(ctx context.Context, maxStaleness time.Duration, maxLag int) error {
+	status := p.Status()
+
+	if status.LastSuccess.IsZero() {
+		if status.LastError != nil {
+			return fmt.Errorf("no successful polling yet: %w", status.LastError)
+		}
+		return errors.New("no successful polling yet")
+	}
+
+	if staleness := time.Since(status.LastSuccess); staleness > maxStaleness {
+		return fmt.Errorf("last successful polling was %s ago: %v", staleness.Round(time.Second), status.LastError)
+	}
+
+	currentBlock, err := p.blocksStorage.GetCurrentBlock(ctx)
+	if err != nil {
+		return fmt.Errorf("load last processed block: %w", err)
+	}
+
+	if lag := status.ChainHead - currentBlock; lag > maxLag {
+		return fmt.Errorf("last processed block %d is %d blocks behind chain head %d", currentBlock, lag, status.ChainHead)
+	}
+
+	return nil
+}
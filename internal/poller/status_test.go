@@ -0,0 +1,58 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestTransactionPoller_CheckSync(t *testing.T) {
+	ctx := context.Background()
+	mockBlocksStorage := &MockBlocksStorage{
+		GetCurrentBlockFunc: func(ctx context.Context) (int, error) {
+			return 95, nil
+		},
+	}
+
+	tests := []struct {
+		name      string
+		status    Status
+		expectErr bool
+	}{
+		{name: "never polled", expectErr: true},
+		{name: "first run failed", status: Status{LastRun: time.Now(), LastError: errors.New("node is down")}, expectErr: true},
+		{name: "stale", status: Status{LastSuccess: time.Now().Add(-time.Hour), ChainHead: 95}, expectErr: true},
+		{name: "lagging", status: Status{LastSuccess: time.Now(), ChainHead: 200}, expectErr: true},
+		{name: "in sync", status: Status{LastSuccess: time.Now(), ChainHead: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewTransactionPoller(nil, mockBlocksStorage, nil, slog.Default())
+			p.status = tt.status
+
+			err := p.CheckSync(ctx, time.Minute, 10)
+			if (err != nil) != tt.expectErr {
+				t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestTransactionPoller_RecordRun(t *testing.T) {
+	mockEthClient := &MockEthClient{
+		GetBlockNumberFunc: func(ctx context.Context) (int, error) {
+			return 0, errors.New("node is down")
+		},
+	}
+	p := NewTransactionPoller(nil, nil, mockEthClient, slog.Default())
+
+	p.recordRun(p.loadNewTransactions(context.Background()))
+
+	status := p.Status()
+	if status.LastError == nil || status.LastRun.IsZero() || !status.LastSuccess.IsZero() {
+		t.Fatalf("expected failed run to be recorded, got %+v", status)
+	}
+}
@@ -0,0 +1,134 @@
+package poller
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+type LogsClient interface {
+	GetLogs(ctx context.Context, filter ethereum.LogFilter) ([]ethereum.Log, error)
+}
+
+type SubscribedAddressesLister interface {
+	SubscribedAddresses(ctx context.Context) ([]string, error)
+}
+
+// SubscribedTopicsTester tests a block logs bloom for indexed address topics of subscribed addresses.
+// Storage keeps bloom positions of the topics up to date on subscribe and unsubscribe
+type SubscribedTopicsTester interface {
+	MayContainSubscribedTopic(ctx context.Context, bloom ethereum.Bloom) (bool, error)
+}
+
+// transferTopic is the topic0 of Transfer(address indexed from, address indexed to, uint256 value) event.
+// ERC-721 shares the signature but logs token id as the third indexed topic
+var transferTopic = "0x" + hex.EncodeToString(ethereum.Keccak256([]byte("Transfer(address,address,uint256)")))
+
+var transferTopicPositions = func() ethereum.BloomPositions {
+	topic, _ := ethereum.DecodeHex(transferTopic)
+	return ethereum.BloomPositionsOf(topic)
+}()
+
+// WithTokenTransfers enables indexing of ERC-20 transfers of subscribed addresses.
+// Logs are fetched only for blocks which logs bloom may contain a transfer of a subscribed address
+func WithTokenTransfers(client LogsClient, topics SubscribedTopicsTester) Option {
+	return func(p *TransactionPoller) {
+		p.transfers = &tokenTransfers{client: client, topics: topics}
+	}
+}
+
+type tokenTransfers struct {
+	client LogsClient
+	topics SubscribedTopicsTester
+}
+
+// mayContainTransfers tests block logs bloom for the transfer event and a subscribed address topic
+func (t *tokenTransfers) mayContainTransfers(ctx context.Context, bloom ethereum.Bloom) (bool, error) {
+	if !bloom.TestPositions(transferTopicPositions) {
+		return false, nil
+	}
+
+	mayContain, err := t.topics.MayContainSubscribedTopic(ctx, bloom)
+	if err != nil {
+		return false, fmt.Errorf("test subscribed topics: %w", err)
+	}
+	return mayContain, nil
+}
+
+// tokenTransfers returns ERC-20 transfers of the block bound to addresses accepted by match
+func (p *TransactionPoller) tokenTransfers(ctx context.Context, number int, block ethereum.EthereumBlock, match addressMatcher) ([]ethereum.AddressTx, error) {
+	if p.transfers == nil {
+		return nil, nil
+	}
+
+	mayContain, err := p.transfers.mayContainTransfers(ctx, block.LogsBloom)
+	if err != nil {
+		return nil, err
+	}
+	if !mayContain {
+		bloomSkippedBlocks.WithLabelValues(p.chainLabel).Inc()
+		return nil, nil
+	}
+
+	blockNumber := ethereum.FormatQuantity(number)
+	logs, err := p.transfers.client.GetLogs(ctx, ethereum.LogFilter{
+		FromBlock: blockNumber,
+		ToBlock:   blockNumber,
+		Topics:    [][]string{{transferTopic}},
+	})
+	if err != nil {
+		pollerErrors.WithLabelValues(p.chainLabel, "get_logs").Inc()
+		return nil, fmt.Errorf("load transfer logs: %w", err)
+	}
+
+	var transfers []ethereum.AddressTx
+	for _, log := range logs {
+		tx, ok := transferFromLog(log)
+		if !ok {
+			continue
+		}
+
+		matched, err := bindParticipants(ctx, tx, match)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, matched...)
+	}
+	return transfers, nil
+}
+
+// transferFromLog converts an ERC-20 Transfer log into a transaction. Other logs sharing
+// the topic, like ERC-721 transfers, are reported as not ok
+func transferFromLog(log ethereum.Log) (ethereum.Transaction, bool) {
+	if log.Removed || len(log.Topics) != 3 || log.Topics[0] != transferTopic {
+		return ethereum.Transaction{}, false
+	}
+
+	from, err := ethereum.TopicAddress(log.Topics[1])
+	if err != nil {
+		return ethereum.Transaction{}, false
+	}
+	to, err := ethereum.TopicAddress(log.Topics[2])
+	if err != nil {
+		return ethereum.Transaction{}, false
+	}
+
+	data, err := ethereum.DecodeHex(log.Data)
+	if err != nil {
+		return ethereum.Transaction{}, false
+	}
+
+	return ethereum.Transaction{
+		From:        from,
+		To:          to,
+		Value:       "0x" + new(big.Int).SetBytes(data).Text(16),
+		Hash:        log.TransactionHash,
+		BlockNumber: log.BlockNumber,
+		LogIndex:    log.LogIndex,
+		Token:       log.Address,
+		Status:      ethereum.TransactionStatusMined,
+	}, true
+}
@@ -0,0 +1,103 @@
+package poller
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+func TestTokenTransfers(t *testing.T) {
+	ctx := context.Background()
+	watched := "0x1234567890abcdef1234567890abcdef12345678"
+	watchedTopic, _ := ethereum.AddressTopic(watched)
+	otherTopic, _ := ethereum.AddressTopic("0xabcdef1234567890abcdef1234567890abcdef12")
+
+	bloomOf := func(values ...string) ethereum.Bloom {
+		var bloom ethereum.Bloom
+		for _, v := range values {
+			decoded, _ := ethereum.DecodeHex(v)
+			bloom.Add(decoded)
+		}
+		return bloom
+	}
+
+	transferLog := ethereum.Log{
+		Address:         "0xtoken",
+		Topics:          []string{transferTopic, otherTopic, watchedTopic},
+		Data:            "0x0000000000000000000000000000000000000000000000000de0b6b3a7640000",
+		TransactionHash: "0x1",
+		LogIndex:        "0x3",
+	}
+	nftTransferLog := ethereum.Log{
+		Topics:          []string{transferTopic, otherTopic, watchedTopic, "0x01"},
+		TransactionHash: "0x2",
+		LogIndex:        "0x4",
+	}
+
+	tests := []struct {
+		name          string
+		bloom         ethereum.Bloom
+		expectFetched bool
+		expected      int
+	}{
+		{name: "no transfers in block", bloom: bloomOf(watchedTopic)},
+		{name: "no subscribed addresses in block", bloom: bloomOf(transferTopic, otherTopic)},
+		{name: "transfer of subscribed address", bloom: bloomOf(transferTopic, otherTopic, watchedTopic), expectFetched: true, expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched := false
+			logsClient := &MockLogsClient{
+				GetLogsFunc: func(ctx context.Context, filter ethereum.LogFilter) ([]ethereum.Log, error) {
+					fetched = true
+					if filter.FromBlock != "0x64" || filter.ToBlock != "0x64" {
+						t.Fatalf("expected logs of block 0x64, got %+v", filter)
+					}
+					return []ethereum.Log{transferLog, nftTransferLog}, nil
+				},
+			}
+			addressesStorage := &MockAddressesStorage{
+				IsSubscribedFunc: func(ctx context.Context, address string) (bool, error) {
+					return address == watched, nil
+				},
+				MayContainSubscribedTopicFunc: func(ctx context.Context, bloom ethereum.Bloom) (bool, error) {
+					decoded, _ := ethereum.DecodeHex(watchedTopic)
+					return bloom.Test(decoded), nil
+				},
+			}
+			p := NewTransactionPoller(addressesStorage, nil, nil, slog.Default(), WithTokenTransfers(logsClient, addressesStorage))
+
+			transfers, err := p.tokenTransfers(ctx, 100, ethereum.EthereumBlock{LogsBloom: tt.bloom}, p.isSubscribed)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if fetched != tt.expectFetched {
+				t.Fatalf("expected logs fetched %v, got %v", tt.expectFetched, fetched)
+			}
+			if len(transfers) != tt.expected {
+				t.Fatalf("expected %d transfers, got %+v", tt.expected, transfers)
+			}
+		})
+	}
+
+	t.Run("transfer converted from log", func(t *testing.T) {
+		tx, ok := transferFromLog(transferLog)
+		if !ok {
+			t.Fatalf("expected transfer")
+		}
+		if tx.To != watched || tx.Value != "0xde0b6b3a7640000" || tx.Token != "0xtoken" || tx.LogIndex != "0x3" {
+			t.Fatalf("unexpected transfer %+v", tx)
+		}
+	})
+}
+
+type MockLogsClient struct {
+	GetLogsFunc func(ctx context.Context, filter ethereum.LogFilter) ([]ethereum.Log, error)
+}
+
+func (m *MockLogsClient) GetLogs(ctx context.Context, filter ethereum.LogFilter) ([]ethereum.Log, error) {
+	return m.GetLogsFunc(ctx, filter)
+}
@@ -0,0 +1,235 @@
+// Package report summarizes indexed transactions of an address over a period for tax and accounting:
+// incoming and outgoing totals, fees paid, counterparties and net flows per token.
+// Amounts are exact decimal strings in the smallest units, i.e. wei for ETH.
+package report
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+// Report of an address over the period [From, To)
+type Report struct {
+	Address string    `json:"address"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	// ETH moved by successful plain transactions, fees are not included
+	ETH Flows `json:"eth"`
+	// wei paid in fees for sent transactions, reverted ones included
+	Fees string `json:"fees"`
+	// contract address -> flows of the token
+	Tokens         map[string]Flows `json:"tokens"`
+	Counterparties []Counterparty   `json:"counterparties"`
+	// transactions of the period counted in the report
+	Transactions int `json:"transactions"`
+	// mined transactions stored without a block timestamp, which can not be attributed to a period
+	Undated int `json:"undated"`
+}
+
+// Flows of an asset, Net is In minus Out
+type Flows struct {
+	In  string `json:"in"`
+	Out string `json:"out"`
+	Net string `json:"net"`
+}
+
+// Counterparty is an address the reported address exchanged ETH or tokens with
+type Counterparty struct {
+	Address string `json:"address"`
+	// ETH moved between the addresses
+	ETH          Flows `json:"eth"`
+	Transactions int   `json:"transactions"`
+}
+
+// flows accumulates amounts of an asset
+type flows struct {
+	in  *big.Int
+	out *big.Int
+}
+
+func newFlows() *flows {
+	return &flows{in: new(big.Int), out: new(big.Int)}
+}
+
+func (f *flows) report() Flows {
+	return Flows{In: f.in.String(), Out: f.out.String(), Net: new(big.Int).Sub(f.in, f.out).String()}
+}
+
+type counterparty struct {
+	eth *flows
+	// distinct transactions with the counterparty, transfers of one transaction count once
+	hashes map[string]struct{}
+}
+
+// Builder accumulates transactions of an address into a report, so histories can be read page by page
+type Builder struct {
+	address        string
+	from, to       time.Time
+	eth            *flows
+	fees           *big.Int
+	tokens         map[string]*flows
+	counterparties map[string]*counterparty
+	hashes         map[string]struct{}
+	undated        int
+}
+
+// NewBuilder starts a report of address for transactions mined in [from, to)
+func NewBuilder(address string, from, to time.Time) *Builder {
+	return &Builder{
+		address:        address,
+		from:           from,
+		to:             to,
+		eth:            newFlows(),
+		fees:           new(big.Int),
+		tokens:         make(map[string]*flows),
+		counterparties: make(map[string]*counterparty),
+		hashes:         make(map[string]struct{}),
+	}
+}
+
+// Add accounts a stored transaction of the address. Transactions which are not mined
+// or were mined outside of the period are skipped
+func (b *Builder) Add(tx ethereum.Transaction) error {
+	if tx.Status != ethereum.TransactionStatusMined {
+		return nil
+	}
+	if tx.BlockTimestamp == "" {
+		b.undated++
+		return nil
+	}
+	minedAt, err := ethereum.ParseTimestamp(tx.BlockTimestamp)
+	if err != nil {
+		return fmt.Errorf("transaction %s: %w", tx.Hash, err)
+	}
+	if minedAt.Before(b.from) || !minedAt.Before(b.to) {
+		return nil
+	}
+	b.hashes[tx.Hash] = struct{}{}
+
+	// the sender pays fees of plain transactions, token transfers share the receipt of their transaction
+	if tx.Token == "" && tx.From == b.address {
+		if fee, ok := tx.Fee(); ok {
+			b.fees.Add(b.fees, fee)
+		}
+	}
+	// reverted transactions do not move value, self-transfers do not change holdings
+	if tx.Failed || tx.From == tx.To {
+		return nil
+	}
+
+	value, err := ethereum.ParseBigQuantity(tx.Value)
+	if err != nil {
+		return fmt.Errorf("transaction %s value: %w", tx.Hash, err)
+	}
+
+	asset := b.eth
+	if tx.Token != "" {
+		asset = b.tokens[tx.Token]
+		if asset == nil {
+			asset = newFlows()
+			b.tokens[tx.Token] = asset
+		}
+	}
+
+	other := tx.From
+	if tx.From == b.address {
+		other = tx.To
+		asset.out.Add(asset.out, value)
+	} else {
+		asset.in.Add(asset.in, value)
+	}
+
+	// contract creations have no recipient
+	if other == "" {
+		return nil
+	}
+	c := b.counterparties[other]
+	if c == nil {
+		c = &counterparty{eth: newFlows(), hashes: make(map[string]struct{})}
+		b.counterparties[other] = c
+	}
+	c.hashes[tx.Hash] = struct{}{}
+	if tx.Token == "" {
+		if other == tx.To {
+			c.eth.out.Add(c.eth.out, value)
+		} else {
+			c.eth.in.Add(c.eth.in, value)
+		}
+	}
+	return nil
+}
+
+// Report returns the report of transactions added so far. Counterparties are ordered by number of transactions
+func (b *Builder) Report() Report {
+	r := Report{
+		Address:        b.address,
+		From:           b.from,
+		To:             b.to,
+		ETH:            b.eth.report(),
+		Fees:           b.fees.String(),
+		Tokens:         make(map[string]Flows, len(b.tokens)),
+		Counterparties: make([]Counterparty, 0, len(b.counterparties)),
+		Transactions:   len(b.hashes),
+		Undated:        b.undated,
+	}
+	for token, f := range b.tokens {
+		r.Tokens[token] = f.report()
+	}
+	for address, c := range b.counterparties {
+		r.Counterparties = append(r.Counterparties, Counterparty{Address: address, ETH: c.eth.report(), Transactions: len(c.hashes)})
+	}
+	slices.SortFunc(r.Counterparties, func(a, b Counterparty) int {
+		if a.Transactions != b.Transactions {
+			return b.Transactions - a.Transactions
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return r
+}
+
+// csvColumns of the CSV report, a row per summary line so it pivots easily in spreadsheets
+var csvColumns = []string{"kind", "asset", "counterparty", "in", "out", "net", "transactions"}
+
+// WriteCSV writes the report as CSV rows of kinds total (ETH), fees, token and counterparty
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	row := func(kind, asset, counterparty string, f Flows, transactions int) {
+		count := ""
+		if transactions > 0 {
+			count = strconv.Itoa(transactions)
+		}
+		_ = cw.Write([]string{kind, asset, counterparty, f.In, f.Out, f.Net, count})
+	}
+
+	fees, ok := new(big.Int).SetString(r.Fees, 10)
+	if !ok {
+		return fmt.Errorf("invalid fees %q", r.Fees)
+	}
+	_ = cw.Write(csvColumns)
+	row("total", "ETH", "", r.ETH, r.Transactions)
+	row("fees", "ETH", "", Flows{In: "0", Out: r.Fees, Net: new(big.Int).Neg(fees).String()}, 0)
+
+	tokens := make([]string, 0, len(r.Tokens))
+	for token := range r.Tokens {
+		tokens = append(tokens, token)
+	}
+	slices.Sort(tokens)
+	for _, token := range tokens {
+		row("token", token, "", r.Tokens[token], 0)
+	}
+	for _, c := range r.Counterparties {
+		row("counterparty", "ETH", c.Address, c.ETH, c.Transactions)
+	}
+
+	// csv.Writer keeps the first write error, reported once flushed
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,112 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+)
+
+func TestBuilder(t *testing.T) {
+	address := "0xaaaa"
+	other := "0xbbbb"
+	exchange := "0xcccc"
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// 2024-06-01, within the period
+	inPeriod := ethereum.FormatQuantity(int(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC).Unix()))
+
+	mined := func(timestamp string, tx ethereum.Transaction) ethereum.Transaction {
+		tx.BlockTimestamp = timestamp
+		tx.Status = ethereum.TransactionStatusMined
+		return tx
+	}
+	txs := []ethereum.Transaction{
+		// 10^30 wei in, beyond uint64
+		mined(inPeriod, ethereum.Transaction{Hash: "0x1", From: other, To: address, Value: "0xc9f2c9cd04674edea40000000"}),
+		// -10 value, -21 fee
+		mined(inPeriod, ethereum.Transaction{Hash: "0x2", From: address, To: other, Value: "0xa", GasUsed: "0x7", EffectiveGasPrice: "0x3"}),
+		// reverted, -21 fee only
+		mined(inPeriod, ethereum.Transaction{Hash: "0x3", From: address, To: exchange, Value: "0xa", GasUsed: "0x7", EffectiveGasPrice: "0x3", Failed: true}),
+		// token transfers of the same transaction
+		mined(inPeriod, ethereum.Transaction{Hash: "0x4", From: address, To: exchange, Value: "0x5", Token: "0xtoken", LogIndex: "0x0"}),
+		mined(inPeriod, ethereum.Transaction{Hash: "0x4", From: exchange, To: address, Value: "0x64", Token: "0xtoken", LogIndex: "0x1"}),
+		// self-transfer pays fee only
+		mined(inPeriod, ethereum.Transaction{Hash: "0x5", From: address, To: address, Value: "0x1", GasUsed: "0x1", EffectiveGasPrice: "0x1"}),
+		// before and exactly at the end of the period
+		mined("0x0", ethereum.Transaction{Hash: "0x6", From: other, To: address, Value: "0x64"}),
+		mined(ethereum.FormatQuantity(int(to.Unix())), ethereum.Transaction{Hash: "0x7", From: other, To: address, Value: "0x64"}),
+		// stored before block timestamps were recorded
+		mined("", ethereum.Transaction{Hash: "0x8", From: other, To: address, Value: "0x64"}),
+		// not mined
+		{Hash: "0x9", From: other, To: address, Value: "0x64", Status: ethereum.TransactionStatusPending},
+	}
+
+	b := NewBuilder(address, from, to)
+	for _, tx := range txs {
+		if err := b.Add(tx); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	r := b.Report()
+
+	t.Run("totals", func(t *testing.T) {
+		expected := Flows{In: "1000000000000000000000000000000", Out: "10", Net: "999999999999999999999999999990"}
+		if r.ETH != expected {
+			t.Fatalf("expected eth flows %+v, got %+v", expected, r.ETH)
+		}
+		if r.Fees != "43" {
+			t.Fatalf("expected fees 43, got %s", r.Fees)
+		}
+		if r.Transactions != 5 || r.Undated != 1 {
+			t.Fatalf("expected 5 transactions and 1 undated, got %d and %d", r.Transactions, r.Undated)
+		}
+	})
+
+	t.Run("tokens", func(t *testing.T) {
+		expected := Flows{In: "100", Out: "5", Net: "95"}
+		if len(r.Tokens) != 1 || r.Tokens["0xtoken"] != expected {
+			t.Fatalf("expected token flows %+v, got %+v", expected, r.Tokens)
+		}
+	})
+
+	t.Run("counterparties", func(t *testing.T) {
+		if len(r.Counterparties) != 2 {
+			t.Fatalf("expected 2 counterparties, got %+v", r.Counterparties)
+		}
+		// the exchange only received a reverted transaction and token transfers of a single transaction
+		if c := r.Counterparties[0]; c.Address != other || c.Transactions != 2 || c.ETH.Net != "999999999999999999999999999990" {
+			t.Fatalf("unexpected counterparty %+v", c)
+		}
+		if c := r.Counterparties[1]; c.Address != exchange || c.Transactions != 1 || c.ETH.Net != "0" {
+			t.Fatalf("unexpected counterparty %+v", c)
+		}
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		var out strings.Builder
+		if err := r.WriteCSV(&out); err != nil {
+			t.Fatal(err)
+		}
+		expected := strings.Join([]string{
+			"kind,asset,counterparty,in,out,net,transactions",
+			"total,ETH,,1000000000000000000000000000000,10,999999999999999999999999999990,5",
+			"fees,ETH,,0,43,-43,",
+			"token,0xtoken,,100,5,95,",
+			"counterparty,ETH,0xbbbb,1000000000000000000000000000000,10,999999999999999999999999999990,2",
+			"counterparty,ETH,0xcccc,0,0,0,1",
+		}, "\n") + "\n"
+		if out.String() != expected {
+			t.Fatalf("expected\n%s\ngot\n%s", expected, out.String())
+		}
+	})
+
+	t.Run("malformed transaction", func(t *testing.T) {
+		err := NewBuilder(address, from, to).Add(mined(inPeriod, ethereum.Transaction{Hash: "0xa", From: other, To: address, Value: "100"}))
+		if err == nil {
+			t.Fatal("expected error for decimal value")
+		}
+	})
+}
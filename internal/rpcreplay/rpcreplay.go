@@ -0,0 +1,190 @@
+// Package rpcreplay records JSON-RPC requests of ethereum.JsonRPCClient with their responses to fixture files
+// and replays them, so client and poller tests run against node responses without network access. Fixtures
+// can also be written by hand in the same format.
+//
+// A fixture is a file per request named after its method and a hash of its params, so requests are matched
+// regardless of their ids. Recording overwrites fixtures of the same requests, fixtures of requests which are
+// not made anymore are left in place.
+package rpcreplay
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// RecordEnv is the environment variable with an endpoint to record fixtures from, fixtures are replayed when unset,
+// e.g. ETH_RPC_RECORD=https://ethereum-rpc.publicnode.com go test ./internal/ethereum
+const RecordEnv = "ETH_RPC_RECORD"
+
+// Fixture is a JSON-RPC request and the response of the node, recorded or hand-written
+type Fixture struct {
+	Method   string          `json:"method"`
+	Params   json.RawMessage `json:"params"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Transport is an http.RoundTripper serving JSON-RPC requests from fixtures of a directory,
+// or forwarding them to an endpoint and recording the responses when created with NewRecorder
+type Transport struct {
+	dir string
+	// endpoint and upstream are set when recording
+	endpoint string
+	upstream http.RoundTripper
+}
+
+// NewReplayer replays fixtures of dir, requests without a fixture fail
+func NewReplayer(dir string) *Transport {
+	return &Transport{dir: dir}
+}
+
+// NewRecorder forwards requests to endpoint through upstream and records successful responses to dir.
+// Requests are sent to their own url when endpoint is empty
+func NewRecorder(dir, endpoint string, upstream http.RoundTripper) *Transport {
+	return &Transport{dir: dir, endpoint: endpoint, upstream: upstream}
+}
+
+// FromEnv records from the endpoint in RecordEnv when it is set and replays fixtures of dir otherwise
+func FromEnv(dir string) *Transport {
+	if endpoint := os.Getenv(RecordEnv); endpoint != "" {
+		return NewRecorder(dir, endpoint, http.DefaultTransport)
+	}
+	return NewReplayer(dir)
+}
+
+// request is the part of a JSON-RPC request fixtures are matched by
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read request: %w", err)
+	}
+	var rpcRequest request
+	if err := json.Unmarshal(body, &rpcRequest); err != nil {
+		return nil, fmt.Errorf("decode json-rpc request, batches are not supported: %w", err)
+	}
+	path, err := t.fixturePath(rpcRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.upstream != nil {
+		return t.record(req, body, rpcRequest, path)
+	}
+	return t.replay(req, rpcRequest, path)
+}
+
+func (t *Transport) replay(req *http.Request, rpcRequest request, path string) (*http.Response, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no fixture of %s %s, record it with %s set: %w", rpcRequest.Method, rpcRequest.Params, RecordEnv, err)
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(content, &fixture); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+
+	// the client may check the id of the response against the one it sent
+	var response map[string]json.RawMessage
+	if err := json.Unmarshal(fixture.Response, &response); err != nil {
+		return nil, fmt.Errorf("decode response of fixture %s: %w", path, err)
+	}
+	if rpcRequest.ID != nil {
+		response["id"] = rpcRequest.ID
+	}
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		return nil, fmt.Errorf("encode response of fixture %s: %w", path, err)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(responseBody)),
+		ContentLength: int64(len(responseBody)),
+		Request:       req,
+	}, nil
+}
+
+func (t *Transport) record(req *http.Request, body []byte, rpcRequest request, path string) (*http.Response, error) {
+	upstreamReq := req.Clone(req.Context())
+	if t.endpoint != "" {
+		endpoint, err := req.URL.Parse(t.endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("parse endpoint: %w", err)
+		}
+		upstreamReq.URL = endpoint
+		upstreamReq.Host = ""
+	}
+	upstreamReq.Body = io.NopCloser(bytes.NewReader(body))
+	upstreamReq.ContentLength = int64(len(body))
+
+	resp, err := t.upstream.RoundTrip(upstreamReq)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+	// rate limits and outages are not worth replaying
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+
+	params := rpcRequest.Params
+	if params == nil {
+		params = json.RawMessage("[]")
+	}
+	content, err := json.MarshalIndent(Fixture{Method: rpcRequest.Method, Params: params, Response: responseBody}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode fixture of %s: %w", rpcRequest.Method, err)
+	}
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create fixtures directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("write fixture: %w", err)
+	}
+	return resp, nil
+}
+
+// fixturePath names the fixture of a request after its method and params, params are compared
+// after decoding, so the formatting and key order of objects do not matter
+func (t *Transport) fixturePath(rpcRequest request) (string, error) {
+	if rpcRequest.Method == "" {
+		return "", fmt.Errorf("json-rpc request without method")
+	}
+	var params any = []any{}
+	if len(rpcRequest.Params) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(rpcRequest.Params))
+		decoder.UseNumber()
+		if err := decoder.Decode(&params); err != nil {
+			return "", fmt.Errorf("decode params of %s: %w", rpcRequest.Method, err)
+		}
+	}
+	// maps are encoded with sorted keys
+	canonical, err := json.Marshal(params)
+	if err != nil {
+		return "", fmt.Errorf("encode params of %s: %w", rpcRequest.Method, err)
+	}
+	sum := sha256.Sum256(canonical)
+	return filepath.Join(t.dir, rpcRequest.Method+"-"+hex.EncodeToString(sum[:])[:16]+".json"), nil
+}
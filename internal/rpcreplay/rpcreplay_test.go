@@ -0,0 +1,96 @@
+package rpcreplay
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	dir := t.TempDir()
+
+	var calls int
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req request
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "eth_chainId" {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":` + string(req.ID) + `,"result":"` + req.Method + `"}`))
+	}))
+	defer node.Close()
+
+	post := func(client *http.Client, body string) (*http.Response, string, error) {
+		resp, err := client.Post("http://node.invalid", "application/json", strings.NewReader(body))
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		content, err := io.ReadAll(resp.Body)
+		return resp, string(content), err
+	}
+
+	recorder := &http.Client{Transport: NewRecorder(dir, node.URL, http.DefaultTransport)}
+	replayer := &http.Client{Transport: NewReplayer(dir)}
+
+	t.Run("record", func(t *testing.T) {
+		_, body, err := post(recorder, `{"jsonrpc":"2.0","id":7,"method":"eth_getLogs","params":[{"fromBlock":"0x1","toBlock":"0x2"}]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != `{"jsonrpc":"2.0","id":7,"result":"eth_getLogs"}` {
+			t.Fatalf("expected the node response, got %s", body)
+		}
+		entries, _ := os.ReadDir(dir)
+		if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "eth_getLogs-") {
+			t.Fatalf("expected a fixture of eth_getLogs, got %v", entries)
+		}
+	})
+
+	t.Run("failed responses are not recorded", func(t *testing.T) {
+		resp, _, err := post(recorder, `{"jsonrpc":"2.0","id":1,"method":"eth_chainId","params":[]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, resp.StatusCode)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Fatalf("expected a single fixture, got %v", entries)
+		}
+	})
+
+	t.Run("replay with the id of the request", func(t *testing.T) {
+		recorded := calls
+		// same params with other formatting and key order
+		_, body, err := post(replayer, `{"id":42,"jsonrpc":"2.0","method":"eth_getLogs","params":[{ "toBlock":"0x2", "fromBlock":"0x1" }]}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != `{"id":42,"jsonrpc":"2.0","result":"eth_getLogs"}` {
+			t.Fatalf("expected the recorded response, got %s", body)
+		}
+		if calls != recorded {
+			t.Fatalf("expected no requests to the node while replaying")
+		}
+	})
+
+	t.Run("missing fixture", func(t *testing.T) {
+		_, _, err := post(replayer, `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[{"fromBlock":"0x3"}]}`)
+		if err == nil || !strings.Contains(err.Error(), "no fixture of eth_getLogs") {
+			t.Fatalf("expected missing fixture error, got %v", err)
+		}
+	})
+
+	t.Run("not a json-rpc request", func(t *testing.T) {
+		if _, _, err := post(replayer, `[{"method":"eth_chainId"}]`); err == nil {
+			t.Fatal("expected error, got none")
+		}
+	})
+}
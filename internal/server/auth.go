@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+// apiKeyHeader is an alternative to the Authorization header for clients which can not set bearer tokens
+const apiKeyHeader = "X-API-Key"
+
+// WithAPIKeys requires requests to authenticate with one of the keys, mapped to the tenant owning the key.
+// Tenants see only addresses and event filters they subscribed to. Without keys the server is single-tenant
+// and every request acts as tenant.Default
+func WithAPIKeys(keys map[string]string) Option {
+	return func(o *options) {
+		o.apiKeys = tenant.NewKeys(keys)
+	}
+}
+
+// defaultAuthFailureLimit throttles guessing of API keys, while a client with a mistyped key is not locked out for long
+var defaultAuthFailureLimit = RateLimit{Rate: 1, Burst: 10}
+
+// WithAuthFailureLimit limits failed authentication attempts per IP. Once an IP runs out of attempts, its requests
+// get 429 before their keys are checked. Successful requests take no attempts, so tenants behind a shared proxy
+// are not limited together
+func WithAuthFailureLimit(limit RateLimit) Option {
+	return func(o *options) {
+		o.authFailureLimit = limit
+	}
+}
+
+// unauthenticatedPaths are probed by infrastructure which has no API keys
+var unauthenticatedPaths = map[string]bool{
+	"/healthz":      true,
+	"/readyz":       true,
+	"/metrics":      true,
+	"/openapi.json": true,
+}
+
+// authenticate resolves the tenant of a request by its API key and stores it in the request context.
+// Failed attempts are limited per IP by failures
+func authenticate(next http.Handler, keys tenant.Keys, failures *limiter, log *slog.Logger) http.Handler {
+	if keys == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticatedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// not authenticated yet, so the client is the IP
+		client := clientOf(r)
+		if retryAfter := failures.wait(client, ""); retryAfter > 0 {
+			rateLimitedRequests.WithLabelValues("authentication").Inc()
+			log.WarnContext(r.Context(), "too many failed authentication attempts", "client", client, "retry_after", retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, errors.New("too many failed authentication attempts"), log)
+			return
+		}
+
+		key := r.Header.Get(apiKeyHeader)
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = bearer
+		}
+		tenantID, ok := keys.Tenant(key)
+		if !ok {
+			failures.allow(client, "")
+			log.WarnContext(r.Context(), "unauthenticated request", "method", r.Method, "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="eth-tx-parser"`)
+			writeError(w, r, http.StatusUnauthorized, errors.New("missing or invalid api key"), log)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(tenant.NewContext(r.Context(), tenantID)))
+	})
+}
+
+// authorize checks the tenant of the request is subscribed to the address or event filter. Every tenant is
+// authorized when authentication is disabled. Returned status describes the error
+func authorize(ctx context.Context, c chain, scoped bool, kind tenant.SubscriptionKind, target string) (int, error) {
+	if !scoped {
+		return http.StatusOK, nil
+	}
+
+	ok, err := c.HasTenantSubscription(ctx, tenant.FromContext(ctx), kind, target)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	// not found rather than forbidden, so tenants can not probe what others are subscribed to
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("%s %s is not subscribed", kind, target)
+	}
+	return http.StatusOK, nil
+}
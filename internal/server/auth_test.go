@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/abi"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+func TestTenants(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := NewNaiveHTTPServer(Chains{1: storage.NewInMemoryStorage()}, log,
+		WithDefaultChain(1),
+		WithAPIKeys(map[string]string{"key-a": "team-a", "key-b": "team-b"}))
+
+	shared := "0x1111111111111111111111111111111111111111"
+	private := "0x2222222222222222222222222222222222222222"
+
+	do := func(method, path, key string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("requests without a valid key are rejected", func(t *testing.T) {
+		if rec := do("GET", "/current_block", "", ""); rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+			t.Fatalf("expected 401 with challenge, got %d", rec.Code)
+		}
+		if rec := do("GET", "/current_block", "key-c", ""); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("expected 401 for unknown key, got %d", rec.Code)
+		}
+		if rec := do("GET", "/healthz", "", ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected health check without key, got %d", rec.Code)
+		}
+	})
+
+	t.Run("api key header is accepted", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/current_block", nil)
+		req.Header.Set(apiKeyHeader, "key-a")
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("tenants see only own subscriptions", func(t *testing.T) {
+		for _, path := range []string{"/address/" + shared + "/subscribe", "/address/" + private + "/subscribe"} {
+			if rec := do("POST", path, "key-a", ""); rec.Code != http.StatusOK {
+				t.Fatalf("subscribe %s: expected 200, got %d", path, rec.Code)
+			}
+		}
+		if rec := do("POST", "/address/"+shared+"/subscribe", "key-b", ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+
+		var subs []tenant.Subscription
+		_ = json.NewDecoder(do("GET", "/subscriptions", "key-b", "").Body).Decode(&subs)
+		if len(subs) != 1 || subs[0].Target != shared {
+			t.Fatalf("expected only shared address subscription of team-b, got %+v", subs)
+		}
+		_ = json.NewDecoder(do("GET", "/subscriptions", "key-a", "").Body).Decode(&subs)
+		if len(subs) != 2 {
+			t.Fatalf("expected both subscriptions of team-a, got %+v", subs)
+		}
+	})
+
+	t.Run("transactions of addresses subscribed by other tenants are hidden", func(t *testing.T) {
+		if rec := do("GET", "/transactions?address="+private, "key-b", ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", rec.Code)
+		}
+		if rec := do("GET", "/address/"+private+"/balances", "key-b", ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", rec.Code)
+		}
+		if rec := do("GET", "/transactions?address="+private, "key-a", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", rec.Code)
+		}
+		if rec := do("GET", "/transactions?address="+shared, "key-b", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("expected 204, got %d", rec.Code)
+		}
+	})
+
+	t.Run("event filters of other tenants are hidden", func(t *testing.T) {
+		rec := do("POST", "/events/subscriptions", "key-a", `{"address":"`+private+`"}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		var filter ethereum.EventFilter
+		_ = json.NewDecoder(rec.Body).Decode(&filter)
+
+		var filters []ethereum.EventFilter
+		_ = json.NewDecoder(do("GET", "/events/subscriptions", "key-b", "").Body).Decode(&filters)
+		if len(filters) != 0 {
+			t.Fatalf("expected no filters of team-b, got %+v", filters)
+		}
+		if rec := do("GET", "/events?filter="+filter.ID, "key-b", ""); rec.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", rec.Code)
+		}
+		if rec := do("GET", "/events?filter="+filter.ID, "key-a", ""); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	})
+
+	t.Run("abis registered by a tenant decode only its transactions", func(t *testing.T) {
+		contract := "0x3333333333333333333333333333333333333333"
+		input := "0xa9059cbb" +
+			"0000000000000000000000001111111111111111111111111111111111111111" +
+			"00000000000000000000000000000000000000000000000000000000000003e8"
+		parser := &MockParser{
+			GetTransactionsFunc: func(ctx context.Context, address string) ([]ethereum.Transaction, error) {
+				return []ethereum.Transaction{{Hash: "0x1", From: address, To: contract, Input: input}}, nil
+			},
+			HasTenantSubscriptionFunc: func(ctx context.Context, tenantID string, kind tenant.SubscriptionKind, target string) (bool, error) {
+				return true, nil
+			},
+		}
+		srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1),
+			WithAPIKeys(map[string]string{"key-a": "team-a", "key-b": "team-b"}))
+		do := func(method, path, key string, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+key)
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, req)
+			return rec
+		}
+		decoded := func(t *testing.T, key string) *abi.Call {
+			var txs []transaction
+			if err := json.NewDecoder(do("GET", "/transactions?address="+shared, key, "").Body).Decode(&txs); err != nil || len(txs) != 1 {
+				t.Fatalf("expected a transaction, got %+v: %v", txs, err)
+			}
+			return txs[0].Decoded
+		}
+
+		abiJSON := `[{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}]}]`
+		if rec := do("PUT", "/address/"+contract+"/abi", "key-b", abiJSON); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if call := decoded(t, "key-b"); call == nil || call.Args[0].Name != "to" {
+			t.Fatalf("expected the abi of team-b to be used for team-b, got %+v", call)
+		}
+		if call := decoded(t, "key-a"); call == nil || call.Args[0].Name != "" {
+			t.Fatalf("expected team-a to decode with the selector table, got %+v", call)
+		}
+	})
+}
+
+func TestAuthFailureLimit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := NewNaiveHTTPServer(Chains{1: currentBlockParser(100)}, log,
+		WithDefaultChain(1),
+		WithAPIKeys(map[string]string{"key-a": "team-a"}),
+		WithAuthFailureLimit(RateLimit{Rate: 0.1, Burst: 3}))
+
+	do := func(key, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/current_block", nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// successful requests take no attempts
+	for range 5 {
+		if rec := do("key-a", "192.0.2.1:1234"); rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+	}
+
+	var codes []int
+	for range 5 {
+		codes = append(codes, do("guess", "192.0.2.1:1234").Code)
+	}
+	if codes[0] != http.StatusUnauthorized || codes[len(codes)-1] != http.StatusTooManyRequests {
+		t.Fatalf("expected repeated failures to be limited, got %v", codes)
+	}
+	// keys are not checked for a limited IP, so a right guess gives nothing away
+	rec := do("key-a", "192.0.2.1:1234")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with retry after, got %d", rec.Code)
+	}
+	if rec := do("key-a", "192.0.2.2:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("expected other IPs not limited, got %d", rec.Code)
+	}
+}
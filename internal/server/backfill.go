@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// maxBackfillBlocks bounds blocks of a backfill request, every block is fetched from the node while the client waits
+const maxBackfillBlocks = 1000
+
+// Backfiller indexes transactions of an address in past blocks
+type Backfiller interface {
+	Backfill(ctx context.Context, address string, fromBlock, toBlock int) (int, error)
+}
+
+// WithBackfiller enables backfilling history of subscribed addresses on the chain
+func WithBackfiller(chainID int, backfiller Backfiller) Option {
+	return func(o *options) {
+		o.backfillers[chainID] = backfiller
+	}
+}
+
+// backfillResult reports a completed backfill
+type backfillResult struct {
+	FromBlock    int `json:"from_block"`
+	ToBlock      int `json:"to_block"`
+	Transactions int `json:"transactions"`
+}
+
+// backfillRange parses the inclusive block range of a backfill, up to the current block by default.
+// Returned status describes the error
+func backfillRange(r *http.Request, c chain) (int, int, int, error) {
+	currentBlock, err := c.GetCurrentBlock(r.Context())
+	if err != nil {
+		return 0, 0, http.StatusInternalServerError, err
+	}
+
+	query := r.URL.Query()
+	param := query.Get("from_block")
+	fromBlock, err := strconv.Atoi(param)
+	if err != nil || fromBlock < 0 {
+		return 0, 0, http.StatusBadRequest, fmt.Errorf("invalid from_block %q: expected a block number", param)
+	}
+	toBlock := currentBlock
+	if param := query.Get("to_block"); param != "" {
+		toBlock, err = strconv.Atoi(param)
+		if err != nil || toBlock < fromBlock {
+			return 0, 0, http.StatusBadRequest, fmt.Errorf("invalid to_block %q: expected a block number from %d", param, fromBlock)
+		}
+	}
+	if toBlock > currentBlock {
+		return 0, 0, http.StatusBadRequest, fmt.Errorf("block %d is not processed yet", toBlock)
+	}
+	if toBlock-fromBlock+1 > maxBackfillBlocks {
+		return 0, 0, http.StatusBadRequest, fmt.Errorf("range of %d blocks exceeds %d blocks per backfill", toBlock-fromBlock+1, maxBackfillBlocks)
+	}
+	return fromBlock, toBlock, http.StatusOK, nil
+}
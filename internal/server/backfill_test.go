@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+type MockBackfiller struct {
+	BackfillFunc func(ctx context.Context, address string, fromBlock, toBlock int) (int, error)
+}
+
+func (m *MockBackfiller) Backfill(ctx context.Context, address string, fromBlock, toBlock int) (int, error) {
+	return m.BackfillFunc(ctx, address, fromBlock, toBlock)
+}
+
+func TestBackfill(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	address := "0x1111111111111111111111111111111111111111"
+
+	chainStorage := storage.NewInMemoryStorage()
+	_ = chainStorage.SetCurrentBlock(ctx, 2000)
+	_ = chainStorage.SaveTenantSubscription(ctx, tenant.Default, tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: address})
+
+	var backfilled [2]int
+	backfiller := &MockBackfiller{BackfillFunc: func(ctx context.Context, a string, fromBlock, toBlock int) (int, error) {
+		if fromBlock == 1913 {
+			return 0, errors.New("node unavailable")
+		}
+		backfilled = [2]int{fromBlock, toBlock}
+		return 3, nil
+	}}
+	handler, _ := newHandler(Chains{1: chainStorage, 5: storage.NewInMemoryStorage()}, log, WithDefaultChain(1), WithBackfiller(1, backfiller))
+
+	post := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec
+	}
+
+	t.Run("up to the current block by default", func(t *testing.T) {
+		rec := post("/address/" + address + "/backfill?from_block=1500")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		var result backfillResult
+		if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		expected := backfillResult{FromBlock: 1500, ToBlock: 2000, Transactions: 3}
+		if result != expected || backfilled != [2]int{1500, 2000} {
+			t.Fatalf("expected %+v, got %+v for %v", expected, result, backfilled)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{name: "missing from block", path: "/address/" + address + "/backfill", status: http.StatusBadRequest},
+		{name: "reversed range", path: "/address/" + address + "/backfill?from_block=10&to_block=9", status: http.StatusBadRequest},
+		{name: "not processed block", path: "/address/" + address + "/backfill?from_block=10&to_block=2001", status: http.StatusBadRequest},
+		{name: "too many blocks", path: "/address/" + address + "/backfill?from_block=0&to_block=1000", status: http.StatusBadRequest},
+		{name: "not subscribed address", path: "/address/0x2222222222222222222222222222222222222222/backfill?from_block=1900", status: http.StatusNotFound},
+		{name: "failed backfill", path: "/address/" + address + "/backfill?from_block=1913", status: http.StatusInternalServerError},
+		{name: "chain without backfiller", path: "/chains/5/address/" + address + "/backfill?from_block=10", status: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := post(tt.path); rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
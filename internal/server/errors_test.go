@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+func TestErrorResponses(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := NewNaiveHTTPServer(Chains{1: storage.NewInMemoryStorage()}, log, WithDefaultChain(1))
+	address := "0x1111111111111111111111111111111111111111"
+
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/address/"+address+"/subscribe", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{name: "missing address", method: http.MethodGet, path: "/transactions", status: http.StatusBadRequest, code: "bad_request"},
+		{name: "malformed address", method: http.MethodGet, path: "/transactions?address=0x12", status: http.StatusBadRequest, code: "bad_request"},
+		{name: "malformed path address", method: http.MethodGet, path: "/address/abc/balances", status: http.StatusBadRequest, code: "bad_request"},
+		{name: "malformed flag", method: http.MethodGet, path: "/transactions?address=" + address + "&resolve_names=maybe", status: http.StatusBadRequest, code: "bad_request"},
+		{name: "unknown filter field", method: http.MethodPost, path: "/events/subscriptions", body: `{"contract":"` + address + `"}`, status: http.StatusBadRequest, code: "bad_request"},
+		{name: "oversized abi", method: http.MethodPut, path: "/address/" + address + "/abi", body: strings.Repeat(" ", maxABISize+1), status: http.StatusRequestEntityTooLarge, code: "payload_too_large"},
+		{name: "unknown chain", method: http.MethodGet, path: "/chains/5/current_block", status: http.StatusNotFound, code: "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+
+			var body errorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body.Error.Code != tt.code || body.Error.Message == "" {
+				t.Fatalf("unexpected error %+v", body.Error)
+			}
+			if body.Error.RequestID == "" || body.Error.RequestID != rec.Header().Get(requestIDHeader) {
+				t.Fatalf("expected request id %q in body, got %q", rec.Header().Get(requestIDHeader), body.Error.RequestID)
+			}
+		})
+	}
+
+	t.Run("request id of the client is kept", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/transactions", nil)
+		req.Header.Set(requestIDHeader, "req-42")
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+
+		var body errorResponse
+		_ = json.NewDecoder(rec.Body).Decode(&body)
+		if body.Error.RequestID != "req-42" || rec.Header().Get(requestIDHeader) != "req-42" {
+			t.Fatalf("expected client request id, got %q", body.Error.RequestID)
+		}
+	})
+
+	t.Run("log records carry request id", func(t *testing.T) {
+		var logs strings.Builder
+		srv := NewNaiveHTTPServer(Chains{1: storage.NewInMemoryStorage()}, slog.New(slog.NewTextHandler(&logs, nil)), WithDefaultChain(1))
+
+		req := httptest.NewRequest(http.MethodGet, "/transactions?address=0x12", nil)
+		req.Header.Set(requestIDHeader, "req-43")
+		srv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+		if !strings.Contains(logs.String(), "request_id=req-43") {
+			t.Fatalf("expected request id in logs, got %q", logs.String())
+		}
+	})
+}
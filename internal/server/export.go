@@ -0,0 +1,216 @@
+package server
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+// exportPageSize is the number of transactions read from storage and flushed to the client at once
+const exportPageSize = 500
+
+// exportRow is an exported transaction. Amounts are decimal strings in the smallest units,
+// ETH columns are filled for plain transactions only as token decimals are unknown
+type exportRow struct {
+	Hash        string `json:"hash"`
+	LogIndex    string `json:"log_index"`
+	BlockNumber string `json:"block_number"`
+	// RFC 3339 in UTC, empty for transactions which are not mined
+	Timestamp string `json:"timestamp"`
+	Status    string `json:"status"`
+	// in, out or self relative to the exported address
+	Direction         string `json:"direction"`
+	From              string `json:"from"`
+	To                string `json:"to"`
+	Token             string `json:"token"`
+	Value             string `json:"value"`
+	ValueETH          string `json:"value_eth"`
+	Method            string `json:"method"`
+	GasUsed           string `json:"gas_used"`
+	EffectiveGasPrice string `json:"effective_gas_price"`
+	// paid by the sender, empty when the receipt is not recorded
+	FeeETH string `json:"fee_eth"`
+	Failed bool   `json:"failed"`
+}
+
+var exportColumns = []string{
+	"hash", "log_index", "block_number", "timestamp", "status", "direction", "from", "to", "token",
+	"value", "value_eth", "method", "gas_used", "effective_gas_price", "fee_eth", "failed",
+}
+
+func (row exportRow) record() []string {
+	return []string{
+		row.Hash, row.LogIndex, row.BlockNumber, row.Timestamp, row.Status, row.Direction, row.From, row.To, row.Token,
+		row.Value, row.ValueETH, row.Method, row.GasUsed, row.EffectiveGasPrice, row.FeeETH, strconv.FormatBool(row.Failed),
+	}
+}
+
+// exportFormat encodes rows of an export
+type exportFormat struct {
+	contentType string
+	extension   string
+	newWriter   func(w io.Writer) rowWriter
+}
+
+// exportFormats maps the format query parameter to the format
+var exportFormats = map[string]exportFormat{
+	"csv":    {contentType: "text/csv; charset=utf-8", extension: "csv", newWriter: newCSVWriter},
+	"ndjson": {contentType: "application/x-ndjson", extension: "ndjson", newWriter: newNDJSONWriter},
+}
+
+type rowWriter interface {
+	// start writes the preamble of the export, e.g. a header
+	start() error
+	write(row exportRow) error
+	// flush writes buffered rows to the client
+	flush() error
+}
+
+type csvWriter struct {
+	*csv.Writer
+}
+
+func newCSVWriter(w io.Writer) rowWriter {
+	return csvWriter{Writer: csv.NewWriter(w)}
+}
+
+func (w csvWriter) start() error {
+	return w.Write(exportColumns)
+}
+
+func (w csvWriter) write(row exportRow) error {
+	return w.Write(row.record())
+}
+
+func (w csvWriter) flush() error {
+	w.Flush()
+	return w.Error()
+}
+
+type ndjsonWriter struct {
+	*json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) rowWriter {
+	return ndjsonWriter{Encoder: json.NewEncoder(w)}
+}
+
+func (w ndjsonWriter) start() error {
+	return nil
+}
+
+func (w ndjsonWriter) write(row exportRow) error {
+	return w.Encode(row)
+}
+
+func (w ndjsonWriter) flush() error {
+	return nil
+}
+
+// exportTransactions streams transactions of the address page by page, so the whole history is never held in memory.
+// Failures after the response started abort the connection, so clients do not mistake a truncated export for a full one
+func exportTransactions(w http.ResponseWriter, r *http.Request, c chain, address string, format exportFormat, log *slog.Logger) {
+	// the first page is read before responding, so storage failures still get an error status
+	page, err := c.GetTransactionsPage(r.Context(), address, 0, exportPageSize)
+	if err != nil {
+		log.ErrorContext(r.Context(), "failed to get transactions for address", "chain_id", c.id, "address", address, "error", err)
+		writeError(w, r, http.StatusInternalServerError, err, log)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%d-%s.%s", c.id, address, format.extension)))
+	w.WriteHeader(http.StatusOK)
+
+	rows := format.newWriter(w)
+	if err := rows.start(); err != nil {
+		log.WarnContext(r.Context(), "failed to write export", "chain_id", c.id, "address", address, "error", err)
+		panic(http.ErrAbortHandler)
+	}
+	controller := http.NewResponseController(w)
+	for offset := 0; ; offset += exportPageSize {
+		for _, tx := range page {
+			if err := rows.write(exportRowOf(c, tenant.FromContext(r.Context()), address, tx)); err != nil {
+				log.WarnContext(r.Context(), "failed to write export", "chain_id", c.id, "address", address, "error", err)
+				panic(http.ErrAbortHandler)
+			}
+		}
+		if err := rows.flush(); err != nil {
+			log.WarnContext(r.Context(), "failed to write export", "chain_id", c.id, "address", address, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		if err := controller.Flush(); err != nil {
+			log.WarnContext(r.Context(), "failed to flush export", "chain_id", c.id, "address", address, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+		if len(page) < exportPageSize {
+			return
+		}
+
+		page, err = c.GetTransactionsPage(r.Context(), address, offset+exportPageSize, exportPageSize)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get transactions for address", "chain_id", c.id, "address", address, "offset", offset+exportPageSize, "error", err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// exportRowOf converts a transaction of the address, method names are decoded with ABIs visible to the tenant
+func exportRowOf(c chain, tenantID, address string, tx ethereum.Transaction) exportRow {
+	row := exportRow{
+		Hash:              tx.Hash,
+		LogIndex:          decimalQuantity(tx.LogIndex),
+		BlockNumber:       decimalQuantity(tx.BlockNumber),
+		Status:            string(tx.Status),
+		From:              tx.From,
+		To:                tx.To,
+		Token:             tx.Token,
+		Value:             decimalQuantity(tx.Value),
+		GasUsed:           decimalQuantity(tx.GasUsed),
+		EffectiveGasPrice: decimalQuantity(tx.EffectiveGasPrice),
+		Failed:            tx.Failed,
+	}
+
+	if timestamp, err := ethereum.ParseTimestamp(tx.BlockTimestamp); err == nil {
+		row.Timestamp = timestamp.Format(time.RFC3339)
+	}
+
+	switch {
+	case tx.From == tx.To:
+		row.Direction = "self"
+	case tx.From == address:
+		row.Direction = "out"
+	default:
+		row.Direction = "in"
+	}
+
+	if tx.Token == "" {
+		if value, err := ethereum.ParseBigQuantity(tx.Value); err == nil {
+			row.ValueETH = ethereum.FormatEther(value)
+		}
+		if fee, ok := tx.Fee(); ok {
+			row.FeeETH = ethereum.FormatEther(fee)
+		}
+		if call, ok := c.abi.DecodeTenant(tenantID, tx.To, tx.Input); ok {
+			row.Method = call.Method
+		}
+	}
+	return row
+}
+
+// decimalQuantity converts a JSON-RPC quantity to decimal, empty for missing or malformed quantities
+func decimalQuantity(s string) string {
+	n, err := ethereum.ParseBigQuantity(s)
+	if err != nil {
+		return ""
+	}
+	return n.String()
+}
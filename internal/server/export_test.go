@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+func TestExport(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	address := "0x1111111111111111111111111111111111111111"
+	counterparty := "0x2222222222222222222222222222222222222222"
+	token := "0x3333333333333333333333333333333333333333"
+
+	chainStorage := storage.NewInMemoryStorage()
+	_ = chainStorage.CommitBlock(ctx, 101, []ethereum.AddressTx{
+		{Address: address, Tx: ethereum.Transaction{
+			Hash: "0x01", From: address, To: counterparty, Value: "0x14d1120d7b160000", BlockNumber: "0x65",
+			BlockTimestamp: "0x6554d1c7", Status: ethereum.TransactionStatusMined, GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00",
+		}},
+		{Address: address, Tx: ethereum.Transaction{
+			Hash: "0x01", LogIndex: "0x2", From: counterparty, To: address, Value: "0x64", Token: token, BlockNumber: "0x65",
+			BlockTimestamp: "0x6554d1c7", Status: ethereum.TransactionStatusMined,
+		}},
+	}, nil)
+	// pending transaction of the mempool watcher, not in a block yet
+	_ = chainStorage.SaveTransaction(ctx, address, ethereum.Transaction{
+		Hash: "0x02", From: address, To: address, Value: "0x0", Status: ethereum.TransactionStatusPending,
+	})
+
+	srv := NewNaiveHTTPServer(Chains{1: chainStorage}, log, WithDefaultChain(1))
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		rec := get("/address/" + address + "/export?format=csv")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != "text/csv; charset=utf-8" {
+			t.Fatalf("unexpected content type %q", contentType)
+		}
+		if disposition := rec.Header().Get("Content-Disposition"); !strings.Contains(disposition, "1-"+address+".csv") {
+			t.Fatalf("unexpected content disposition %q", disposition)
+		}
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 4 || strings.Join(records[0], ",") != strings.Join(exportColumns, ",") {
+			t.Fatalf("expected header and 3 rows, got %v", records)
+		}
+
+		expected := [][]string{
+			{"0x01", "", "101", "2023-11-15T14:12:23Z", "mined", "out", address, counterparty, "", "1500000000000000000", "1.5", "", "21000", "1000000000", "0.000021", "false"},
+			{"0x01", "2", "101", "2023-11-15T14:12:23Z", "mined", "in", counterparty, address, token, "100", "", "", "", "", "", "false"},
+			{"0x02", "", "", "", "pending", "self", address, address, "", "0", "0", "", "", "", "", "false"},
+		}
+		for i, row := range expected {
+			if got := strings.Join(records[i+1], ","); got != strings.Join(row, ",") {
+				t.Errorf("row %d: expected %v, got %v", i, row, records[i+1])
+			}
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		rec := get("/chains/1/address/" + address + "/export?format=ndjson")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+
+		var rows []exportRow
+		scanner := bufio.NewScanner(rec.Body)
+		for scanner.Scan() {
+			var row exportRow
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				t.Fatal(err)
+			}
+			rows = append(rows, row)
+		}
+		if len(rows) != 3 || rows[0].FeeETH != "0.000021" || rows[1].Token != token {
+			t.Fatalf("unexpected rows %+v", rows)
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		if rec := get("/address/" + address + "/export?format=xlsx"); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for unknown format, got %d", http.StatusBadRequest, rec.Code)
+		}
+		if rec := get("/address/0xabc/export"); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for malformed address, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+}
+
+func TestExportPaging(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	address := "0x1111111111111111111111111111111111111111"
+	total := exportPageSize*2 + 1
+
+	var offsets []int
+	parser := &MockParser{
+		GetTransactionsPageFunc: func(ctx context.Context, address string, offset int, limit int) ([]ethereum.Transaction, error) {
+			offsets = append(offsets, offset)
+			page := make([]ethereum.Transaction, min(limit, max(0, total-offset)))
+			for i := range page {
+				page[i] = ethereum.Transaction{Hash: "0x01", From: address}
+			}
+			return page, nil
+		},
+	}
+
+	t.Run("transactions are read page by page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler, _ := newHandler(Chains{1: parser}, log, WithDefaultChain(1))
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/address/"+address+"/export?format=ndjson", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		if lines := strings.Count(rec.Body.String(), "\n"); lines != total {
+			t.Fatalf("expected %d rows, got %d", total, lines)
+		}
+		if len(offsets) != 3 || offsets[2] != exportPageSize*2 {
+			t.Fatalf("expected 3 pages, got offsets %v", offsets)
+		}
+	})
+
+	t.Run("storage failure before streaming", func(t *testing.T) {
+		parser.GetTransactionsPageFunc = func(ctx context.Context, address string, offset int, limit int) ([]ethereum.Transaction, error) {
+			return nil, errors.New("storage unavailable")
+		}
+		rec := httptest.NewRecorder()
+		handler, _ := newHandler(Chains{1: parser}, log, WithDefaultChain(1))
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/address/"+address+"/export", nil))
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %d, got %d", http.StatusInternalServerError, rec.Code)
+		}
+	})
+
+	t.Run("storage failure while streaming aborts the response", func(t *testing.T) {
+		parser.GetTransactionsPageFunc = func(ctx context.Context, address string, offset int, limit int) ([]ethereum.Transaction, error) {
+			if offset > 0 {
+				return nil, errors.New("storage unavailable")
+			}
+			return make([]ethereum.Transaction, limit), nil
+		}
+		handler, _ := newHandler(Chains{1: parser}, log, WithDefaultChain(1))
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		resp, err := http.Get(server.URL + "/address/" + address + "/export")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if _, err := io.ReadAll(resp.Body); err == nil {
+			t.Fatal("expected truncated export to fail to read")
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const readinessCheckTimeout = time.Second * 5
+
+// readinessCheck is a named dependency which has to be healthy for the service to accept traffic
+type readinessCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// WithReadinessCheck adds a check to /readyz. The service is ready only when all checks pass
+func WithReadinessCheck(name string, check func(ctx context.Context) error) Option {
+	return func(o *options) {
+		o.readinessChecks = append(o.readinessChecks, readinessCheck{name: name, check: check})
+	}
+}
+
+func registerHealthHandlers(serverMux *routeMux, checks []readinessCheck, log *slog.Logger) {
+	// process is alive as long as it is able to serve the request
+	serverMux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"}, log)
+	})
+
+	serverMux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readinessCheckTimeout)
+		defer cancel()
+
+		failed := make(map[string]string)
+		for _, c := range checks {
+			if err := c.check(ctx); err != nil {
+				failed[c.name] = err.Error()
+			}
+		}
+
+		if len(failed) > 0 {
+			log.WarnContext(r.Context(), "service is not ready", "checks", failed)
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"status": "not ready", "checks": failed}, log)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"}, log)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any, log *slog.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error("failed to encode response", "error", err)
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+func TestInstrument(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name    string
+		options []Option
+		key     string
+		// metrics are global, so every case requests its own route
+		path  string
+		route string
+	}{
+		{name: "unauthenticated api", path: "/chains/1/current_block", route: "GET /chains/{chainId}/current_block"},
+		{
+			name:    "authenticated api",
+			options: []Option{WithAPIKeys(map[string]string{"key-a": "team-a"})},
+			key:     "key-a",
+			path:    "/current_block",
+			route:   "GET /current_block",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewNaiveHTTPServer(Chains{1: storage.NewInMemoryStorage()}, log, append(tt.options, WithDefaultChain(1))...)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set(apiKeyHeader, tt.key)
+			}
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected 200, got %d", rec.Code)
+			}
+
+			rec = httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			body := rec.Body.String()
+			if !strings.Contains(body, `eth_tx_parser_http_requests_total{code="200",method="GET",route="`+tt.route+`"}`) {
+				t.Fatalf("expected request labelled with route %s, got:\n%s", tt.route, body)
+			}
+		})
+	}
+}
@@ -3,11 +3,21 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
-	"strings"
+	"slices"
+	"strconv"
+	"time"
 
+	"github.com/mkorolyov/go-eth-tx-parser/internal/abi"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ens"
 	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ledger"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type Parser interface {
@@ -15,61 +25,754 @@ type Parser interface {
 	// In the task methods where defined without error in response
 	// while here error was added to propagate possible errors
 	GetCurrentBlock(ctx context.Context) (int, error)
-	// Subscribe add address to observer
-	Subscribe(ctx context.Context, address string) error
-	// GetTransactions list of inbound or outbound transactions for an address
+	// SubscribeTenant records an address subscription of a tenant and observes the address, atomically with
+	// UnsubscribeTenant of other tenants
+	SubscribeTenant(ctx context.Context, tenantID string, sub tenant.Subscription) error
+	// UnsubscribeTenant removes the address subscription of a tenant, the address is no longer observed
+	// once no tenant is subscribed to it. False if the tenant was not subscribed
+	UnsubscribeTenant(ctx context.Context, tenantID string, address string) (bool, error)
+	// GetTransactions list of inbound or outbound transactions for an address in block order
 	GetTransactions(ctx context.Context, address string) ([]ethereum.Transaction, error)
+	// GetTransactionsPage page of transactions of an address in block order starting at offset, the offset of
+	// the next page is the cursor clients page with
+	GetTransactionsPage(ctx context.Context, address string, offset int, limit int) ([]ethereum.Transaction, error)
+	// GetBalances latest known balances of an address, false if not fetched yet
+	GetBalances(ctx context.Context, address string) (ethereum.Balances, bool, error)
+	// GetBalanceSnapshots balances of an address fetched from the node, ordered by block
+	GetBalanceSnapshots(ctx context.Context, address string) ([]ethereum.Balances, error)
+	// GetDiscrepancies mismatches of reconstructed and node balances of an address
+	GetDiscrepancies(ctx context.Context, address string) ([]ethereum.BalanceDiscrepancy, error)
+	// SubscribeEvents add normalized event filter to observer
+	SubscribeEvents(ctx context.Context, filter ethereum.EventFilter) error
+	// EventFilters list of subscribed event filters
+	EventFilters(ctx context.Context) ([]ethereum.EventFilter, error)
+	// GetEvents page of events matched by a filter
+	GetEvents(ctx context.Context, filterID string, offset int, limit int) ([]ethereum.Event, error)
+	// SaveTenantSubscription records a subscription of a tenant to an address or an event filter
+	SaveTenantSubscription(ctx context.Context, tenantID string, sub tenant.Subscription) error
+	// TenantSubscriptions list of subscriptions of a tenant
+	TenantSubscriptions(ctx context.Context, tenantID string) ([]tenant.Subscription, error)
+	// HasTenantSubscription checks if a tenant is subscribed to an address or an event filter
+	HasTenantSubscription(ctx context.Context, tenantID string, kind tenant.SubscriptionKind, target string) (bool, error)
 }
 
-func NewNaiveHTTPServer(parser Parser, log *slog.Logger) *http.Server {
-	serverMux := http.NewServeMux()
+// historicalBalance is a balance reconstructed from indexed history with discrepancies detected up to its block
+type historicalBalance struct {
+	ethereum.Balances
+	Discrepancies []ethereum.BalanceDiscrepancy `json:"discrepancies,omitempty"`
+}
+
+// Chains maps chain id to the parser of the chain
+type Chains map[int]Parser
+
+// NameResolver resolves ENS names
+type NameResolver interface {
+	ResolveName(ctx context.Context, name string) (string, error)
+	LookupAddress(ctx context.Context, address string) (string, error)
+}
+
+// chain is the parser a request is routed to
+type chain struct {
+	id int
+	Parser
+	abi *abi.Registry
+}
+
+// transaction is a stored transaction with its call input decoded when the method is known
+type transaction struct {
+	ethereum.Transaction
+	Decoded *abi.Call `json:"decoded,omitempty"`
+	// ENS names of counterparties, filled in on request
+	FromName string `json:"fromName,omitempty"`
+	ToName   string `json:"toName,omitempty"`
+}
+
+type options struct {
+	readinessChecks []readinessCheck
+	defaultChain    int
+	abiRegistries   map[int]*abi.Registry
+	names           NameResolver
+	// api key digest -> tenant, nil when authentication is disabled
+	apiKeys           tenant.Keys
+	authFailureLimit  RateLimit
+	rateLimit         *RateLimit
+	routeRateLimits   map[string]RateLimit
+	subscriptionQuota int
+	// chain id -> backfiller, chains without one do not backfill
+	backfillers map[int]Backfiller
+}
+
+type Option func(*options)
+
+const (
+	// maxABISize limits size of registered contract ABIs
+	maxABISize = 1 << 20
+	// defaultEventsLimit and maxEventsLimit bound events page size
+	defaultEventsLimit = 100
+	maxEventsLimit     = 1000
+)
+
+// eventsPage is a page of events, NextCursor is empty on the last page
+type eventsPage struct {
+	Events     []ethereum.Event `json:"events"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// WithDefaultChain serves chain routes also without the /chains/{chainId} prefix,
+// for clients which predate multi-chain support
+func WithDefaultChain(chainID int) Option {
+	return func(o *options) {
+		o.defaultChain = chainID
+	}
+}
+
+// WithABIRegistry decodes input of transactions of the chain with ABIs of the registry. Chains
+// without a registry decode only common methods
+func WithABIRegistry(chainID int, registry *abi.Registry) Option {
+	return func(o *options) {
+		o.abiRegistries[chainID] = registry
+	}
+}
+
+// WithNameResolver accepts ENS names in place of addresses and enables reverse resolution of counterparties
+func WithNameResolver(resolver NameResolver) Option {
+	return func(o *options) {
+		o.names = resolver
+	}
+}
 
-	serverMux.HandleFunc("POST /address/{address}/subscribe", func(w http.ResponseWriter, r *http.Request) {
-		address := strings.ToLower(r.PathValue("address"))
+func NewNaiveHTTPServer(chains Chains, log *slog.Logger, opts ...Option) *http.Server {
+	handler, _ := newHandler(chains, log, opts...)
+	return &http.Server{Addr: ":8080", Handler: handler}
+}
+
+// routeMux records patterns of registered routes, the OpenAPI document is checked against them
+type routeMux struct {
+	*http.ServeMux
+	routes []string
+}
+
+func (m *routeMux) Handle(pattern string, handler http.Handler) {
+	m.routes = append(m.routes, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+func (m *routeMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
 
-		log.Info("subscribing to address", "address", address)
+// newHandler builds the API handler and returns patterns of its routes
+func newHandler(chains Chains, log *slog.Logger, opts ...Option) (http.Handler, []string) {
+	// records of requests are tagged with their request id
+	log = slog.New(requestIDHandler{log.Handler()})
 
-		if err := parser.Subscribe(r.Context(), address); err != nil {
-			log.Error("failed to subscribe to address", "address", address, "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
+	o := options{
+		abiRegistries:    make(map[int]*abi.Registry),
+		routeRateLimits:  make(map[string]RateLimit),
+		backfillers:      make(map[int]Backfiller),
+		authFailureLimit: defaultAuthFailureLimit,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// tenants are isolated only when requests are authenticated
+	scoped := o.apiKeys != nil
+
+	resolved := make(map[int]chain, len(chains))
+	for id, parser := range chains {
+		registry, ok := o.abiRegistries[id]
+		if !ok {
+			registry = abi.NewRegistry()
+		}
+		resolved[id] = chain{id: id, Parser: parser, abi: registry}
+	}
+
+	serverMux := &routeMux{ServeMux: http.NewServeMux()}
+
+	// chainRoute registers a handler under /chains/{chainId} and, for the default chain, at the root
+	chainRoute := func(method, path string, handler func(w http.ResponseWriter, r *http.Request, c chain)) {
+		serverMux.HandleFunc(method+" /chains/{chainId}"+path, func(w http.ResponseWriter, r *http.Request) {
+			chainID, err := strconv.Atoi(r.PathValue("chainId"))
+			c, ok := resolved[chainID]
+			if err != nil || !ok {
+				writeError(w, r, http.StatusNotFound, fmt.Errorf("unknown chain %q", r.PathValue("chainId")), log)
+				return
+			}
+			handler(w, r, c)
+		})
+
+		if c, ok := resolved[o.defaultChain]; ok {
+			serverMux.HandleFunc(method+" "+path, func(w http.ResponseWriter, r *http.Request) {
+				handler(w, r, c)
+			})
+		}
+	}
+
+	serverMux.HandleFunc("GET /chains", func(w http.ResponseWriter, r *http.Request) {
+		ids := make([]int, 0, len(chains))
+		for id := range chains {
+			ids = append(ids, id)
+		}
+		slices.Sort(ids)
+		writeJSON(w, http.StatusOK, map[string][]int{"chains": ids}, log)
+	})
+
+	chainRoute("POST", "/address/{address}/subscribe", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, status, err := resolveAddress(r.Context(), o.names, r.PathValue("address"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to resolve address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+
+		tenantID := tenant.FromContext(r.Context())
+		if status, err := checkSubscriptionQuota(r.Context(), c, o.subscriptionQuota, tenant.SubscriptionKindAddress, address); err != nil {
+			log.ErrorContext(r.Context(), "rejected subscription", "chain_id", c.id, "tenant", tenantID, "address", address, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		log.InfoContext(r.Context(), "subscribing to address", "chain_id", c.id, "tenant", tenantID, "address", address)
+
+		sub := tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: address, CreatedAt: time.Now().UTC()}
+		if err := c.SubscribeTenant(r.Context(), tenantID, sub); err != nil {
+			log.ErrorContext(r.Context(), "failed to subscribe to address", "chain_id", c.id, "tenant", tenantID, "address", address, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
 			return
 		}
 		w.WriteHeader(http.StatusOK)
 	})
 
-	//naive implementation without paging support
-	serverMux.HandleFunc("GET /transactions", func(w http.ResponseWriter, r *http.Request) {
-		address := r.URL.Query().Get("address")
-		txs, err := parser.GetTransactions(r.Context(), address)
+	chainRoute("POST", "/address/{address}/unsubscribe", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, status, err := resolveAddress(r.Context(), o.names, r.PathValue("address"))
 		if err != nil {
-			log.Error("failed to get transactions for address", "address", address, "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			log.ErrorContext(r.Context(), "failed to resolve address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+
+		tenantID := tenant.FromContext(r.Context())
+		unsubscribed, err := c.UnsubscribeTenant(r.Context(), tenantID, address)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to unsubscribe from address", "chain_id", c.id, "tenant", tenantID, "address", address, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		if !unsubscribed {
+			writeError(w, r, http.StatusNotFound, fmt.Errorf("%s %s is not subscribed", tenant.SubscriptionKindAddress, address), log)
+			return
+		}
+		log.InfoContext(r.Context(), "unsubscribed from address", "chain_id", c.id, "tenant", tenantID, "address", address)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	chainRoute("POST", "/address/{address}/backfill", func(w http.ResponseWriter, r *http.Request, c chain) {
+		backfiller, ok := o.backfillers[c.id]
+		if !ok {
+			writeError(w, r, http.StatusNotImplemented, fmt.Errorf("backfill is not enabled on chain %d", c.id), log)
+			return
+		}
+		address, status, err := resolveAddress(r.Context(), o.names, r.PathValue("address"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to resolve address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		fromBlock, toBlock, status, err := backfillRange(r, c)
+		if err != nil {
+			log.ErrorContext(r.Context(), "invalid backfill range", "chain_id", c.id, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		// only subscribed addresses are backfilled, also when tenants are not isolated
+		if status, err := authorize(r.Context(), c, true, tenant.SubscriptionKindAddress, address); err != nil {
+			log.ErrorContext(r.Context(), "failed to authorize address", "chain_id", c.id, "tenant", tenant.FromContext(r.Context()), "address", address, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+
+		log.InfoContext(r.Context(), "backfilling address", "chain_id", c.id, "address", address, "from_block", fromBlock, "to_block", toBlock)
+		saved, err := backfiller.Backfill(r.Context(), address, fromBlock, toBlock)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to backfill address", "chain_id", c.id, "address", address, "transactions_count", saved, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		writeJSON(w, http.StatusOK, backfillResult{FromBlock: fromBlock, ToBlock: toBlock, Transactions: saved}, log)
+	})
+
+	// the whole history of an address in block order, gRPC ListTransactions and exports page through it instead
+	chainRoute("GET", "/transactions", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, status, err := resolveAddress(r.Context(), o.names, r.URL.Query().Get("address"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to resolve address", "chain_id", c.id, "address", r.URL.Query().Get("address"), "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		resolveNames, err := boolParam(r, "resolve_names")
+		if err != nil {
+			log.ErrorContext(r.Context(), "invalid query", "chain_id", c.id, "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
+			return
+		}
+		if status, err := authorize(r.Context(), c, scoped, tenant.SubscriptionKindAddress, address); err != nil {
+			log.ErrorContext(r.Context(), "failed to authorize address", "chain_id", c.id, "tenant", tenant.FromContext(r.Context()), "address", address, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		txs, err := c.GetTransactions(r.Context(), address)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get transactions for address", "chain_id", c.id, "address", address, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
 			return
 		}
 		if len(txs) == 0 {
 			w.WriteHeader(http.StatusNoContent)
 			return
 		}
-		if err := json.NewEncoder(w).Encode(txs); err != nil {
-			log.Error("failed to encode transactions for address", "address", address, "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		views := make([]transaction, len(txs))
+		for i, tx := range txs {
+			views[i] = transaction{Transaction: tx}
+			if call, ok := c.abi.DecodeTenant(tenant.FromContext(r.Context()), tx.To, tx.Input); ok {
+				views[i].Decoded = &call
+			}
+		}
+		if o.names != nil && resolveNames {
+			lookupNames(r.Context(), o.names, views, log)
+		}
+		writeJSON(w, http.StatusOK, views, log)
+	})
+
+	chainRoute("GET", "/address/{address}/export", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, status, err := resolveAddress(r.Context(), o.names, r.PathValue("address"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to resolve address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		name := r.URL.Query().Get("format")
+		if name == "" {
+			name = "csv"
+		}
+		format, ok := exportFormats[name]
+		if !ok {
+			writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid format %q: expected csv or ndjson", name), log)
+			return
+		}
+		if status, err := authorize(r.Context(), c, scoped, tenant.SubscriptionKindAddress, address); err != nil {
+			log.ErrorContext(r.Context(), "failed to authorize address", "chain_id", c.id, "tenant", tenant.FromContext(r.Context()), "address", address, "error", err)
+			writeError(w, r, status, err, log)
 			return
 		}
+		log.InfoContext(r.Context(), "exporting transactions", "chain_id", c.id, "address", address, "format", name)
+		exportTransactions(w, r, c, address, format, log)
 	})
 
-	serverMux.HandleFunc("GET /current_block", func(w http.ResponseWriter, r *http.Request) {
-		block, err := parser.GetCurrentBlock(r.Context())
+	chainRoute("GET", "/address/{address}/report", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, status, err := resolveAddress(r.Context(), o.names, r.PathValue("address"))
 		if err != nil {
-			log.Error("failed to get current block", "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
+			log.ErrorContext(r.Context(), "failed to resolve address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, status, err, log)
 			return
 		}
-		if err := json.NewEncoder(w).Encode(map[string]int{"current_block": block}); err != nil {
-			log.Error("failed to encode current block", "error", err)
-			w.WriteHeader(http.StatusInternalServerError)
+		from, to, err := reportPeriod(r, time.Now())
+		if err != nil {
+			log.ErrorContext(r.Context(), "invalid query", "chain_id", c.id, "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
 			return
 		}
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid format %q: expected json or csv", format), log)
+			return
+		}
+		if status, err := authorize(r.Context(), c, scoped, tenant.SubscriptionKindAddress, address); err != nil {
+			log.ErrorContext(r.Context(), "failed to authorize address", "chain_id", c.id, "tenant", tenant.FromContext(r.Context()), "address", address, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+
+		summary, err := buildReport(r.Context(), c, address, from, to)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to build report", "chain_id", c.id, "address", address, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		if format != "csv" {
+			writeJSON(w, http.StatusOK, summary, log)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%d-%s-report.csv", c.id, address)))
+		if err := summary.WriteCSV(w); err != nil {
+			log.WarnContext(r.Context(), "failed to write report", "chain_id", c.id, "address", address, "error", err)
+		}
 	})
 
-	return &http.Server{Addr: ":8080", Handler: serverMux}
+	chainRoute("PUT", "/address/{address}/abi", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, err := parseAddress(r.PathValue("address"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "invalid address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxABISize))
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to read abi", "chain_id", c.id, "address", address, "error", err)
+			status := http.StatusBadRequest
+			if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			writeError(w, r, status, err, log)
+			return
+		}
+
+		contractABI, err := abi.Parse(body)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to parse abi", "chain_id", c.id, "address", address, "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
+			return
+		}
+
+		// registered for the tenant only, decoding of the same contract for other tenants is not changed
+		tenantID := tenant.FromContext(r.Context())
+		log.InfoContext(r.Context(), "registering contract abi", "chain_id", c.id, "address", address, "tenant", tenantID)
+		c.abi.RegisterTenant(tenantID, address, contractABI)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	chainRoute("GET", "/address/{address}/balances", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, err := parseAddress(r.PathValue("address"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "invalid address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
+			return
+		}
+		if status, err := authorize(r.Context(), c, scoped, tenant.SubscriptionKindAddress, address); err != nil {
+			log.ErrorContext(r.Context(), "failed to authorize address", "chain_id", c.id, "tenant", tenant.FromContext(r.Context()), "address", address, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		balances, ok, err := c.GetBalances(r.Context(), address)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get balances for address", "chain_id", c.id, "address", address, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		// not subscribed or balances are not fetched yet
+		if !ok {
+			writeError(w, r, http.StatusNotFound, fmt.Errorf("balances of %s are not fetched yet", address), log)
+			return
+		}
+		writeJSON(w, http.StatusOK, balances, log)
+	})
+
+	chainRoute("GET", "/address/{address}/balance", func(w http.ResponseWriter, r *http.Request, c chain) {
+		address, err := parseAddress(r.PathValue("address"))
+		if err != nil {
+			log.ErrorContext(r.Context(), "invalid address", "chain_id", c.id, "address", r.PathValue("address"), "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
+			return
+		}
+		if status, err := authorize(r.Context(), c, scoped, tenant.SubscriptionKindAddress, address); err != nil {
+			log.ErrorContext(r.Context(), "failed to authorize address", "chain_id", c.id, "tenant", tenant.FromContext(r.Context()), "address", address, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		balance, status, err := balanceAt(r, c, address)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to reconstruct balance for address", "chain_id", c.id, "address", address, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		writeJSON(w, http.StatusOK, balance, log)
+	})
+
+	chainRoute("POST", "/events/subscriptions", func(w http.ResponseWriter, r *http.Request, c chain) {
+		var filter ethereum.EventFilter
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&filter); err != nil {
+			log.ErrorContext(r.Context(), "failed to decode event filter", "chain_id", c.id, "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
+			return
+		}
+		filter, err := filter.Normalize()
+		if err != nil {
+			log.ErrorContext(r.Context(), "invalid event filter", "chain_id", c.id, "error", err)
+			writeError(w, r, http.StatusBadRequest, err, log)
+			return
+		}
+
+		tenantID := tenant.FromContext(r.Context())
+		if status, err := checkSubscriptionQuota(r.Context(), c, o.subscriptionQuota, tenant.SubscriptionKindEvents, filter.ID); err != nil {
+			log.ErrorContext(r.Context(), "rejected subscription", "chain_id", c.id, "tenant", tenantID, "filter_id", filter.ID, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		log.InfoContext(r.Context(), "subscribing to events", "chain_id", c.id, "tenant", tenantID, "filter_id", filter.ID, "address", filter.Address, "topics", filter.Topics)
+
+		if err := c.SubscribeEvents(r.Context(), filter); err != nil {
+			log.ErrorContext(r.Context(), "failed to subscribe to events", "chain_id", c.id, "tenant", tenantID, "filter_id", filter.ID, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		sub := tenant.Subscription{Kind: tenant.SubscriptionKindEvents, Target: filter.ID, CreatedAt: time.Now().UTC()}
+		if err := c.SaveTenantSubscription(r.Context(), tenantID, sub); err != nil {
+			log.ErrorContext(r.Context(), "failed to save tenant subscription", "chain_id", c.id, "tenant", tenantID, "filter_id", filter.ID, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		writeJSON(w, http.StatusOK, filter, log)
+	})
+
+	chainRoute("GET", "/events/subscriptions", func(w http.ResponseWriter, r *http.Request, c chain) {
+		filters, err := visibleEventFilters(r.Context(), c, scoped)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list event filters", "chain_id", c.id, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		if filters == nil {
+			filters = []ethereum.EventFilter{}
+		}
+		writeJSON(w, http.StatusOK, filters, log)
+	})
+
+	chainRoute("GET", "/events", func(w http.ResponseWriter, r *http.Request, c chain) {
+		page, status, err := eventsPageOf(r, c, scoped)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get events", "chain_id", c.id, "error", err)
+			writeError(w, r, status, err, log)
+			return
+		}
+		writeJSON(w, http.StatusOK, page, log)
+	})
+
+	chainRoute("GET", "/subscriptions", func(w http.ResponseWriter, r *http.Request, c chain) {
+		tenantID := tenant.FromContext(r.Context())
+		subs, err := c.TenantSubscriptions(r.Context(), tenantID)
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to list tenant subscriptions", "chain_id", c.id, "tenant", tenantID, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		if subs == nil {
+			subs = []tenant.Subscription{}
+		}
+		writeJSON(w, http.StatusOK, subs, log)
+	})
+
+	chainRoute("GET", "/current_block", func(w http.ResponseWriter, r *http.Request, c chain) {
+		block, err := c.GetCurrentBlock(r.Context())
+		if err != nil {
+			log.ErrorContext(r.Context(), "failed to get current block", "chain_id", c.id, "error", err)
+			writeError(w, r, http.StatusInternalServerError, err, log)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"current_block": block}, log)
+	})
+
+	limits := newLimiter(o.rateLimit, o.routeRateLimits)
+
+	serverMux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, openAPIDocument(), log)
+	})
+	serverMux.Handle("GET /metrics", promhttp.Handler())
+	registerHealthHandlers(serverMux, o.readinessChecks, log)
+
+	authFailures := newLimiter(&o.authFailureLimit, nil)
+	handler := withRequestID(instrument(authenticate(rateLimit(serverMux, serverMux.ServeMux, limits, log), o.apiKeys, authFailures, log), serverMux.ServeMux))
+	return handler, serverMux.routes
+}
+
+// balanceAt reconstructs balance of address as of the block query parameter, current block by default.
+// Returned status describes the error
+func balanceAt(r *http.Request, c chain, address string) (historicalBalance, int, error) {
+	currentBlock, err := c.GetCurrentBlock(r.Context())
+	if err != nil {
+		return historicalBalance{}, http.StatusInternalServerError, err
+	}
+
+	block := currentBlock
+	if param := r.URL.Query().Get("block"); param != "" {
+		block, err = strconv.Atoi(param)
+		if err != nil || block < 0 {
+			return historicalBalance{}, http.StatusBadRequest, fmt.Errorf("invalid block %q", param)
+		}
+	}
+	if block > currentBlock {
+		return historicalBalance{}, http.StatusBadRequest, fmt.Errorf("block %d is not processed yet", block)
+	}
+
+	snapshots, err := c.GetBalanceSnapshots(r.Context(), address)
+	if err != nil {
+		return historicalBalance{}, http.StatusInternalServerError, err
+	}
+	txs, err := c.GetTransactions(r.Context(), address)
+	if err != nil {
+		return historicalBalance{}, http.StatusInternalServerError, err
+	}
+
+	balances, err := ledger.BalancesAt(address, snapshots, txs, block)
+	if errors.Is(err, ledger.ErrNoSnapshot) {
+		return historicalBalance{}, http.StatusNotFound, err
+	}
+	if err != nil {
+		return historicalBalance{}, http.StatusInternalServerError, err
+	}
+
+	discrepancies, err := c.GetDiscrepancies(r.Context(), address)
+	if err != nil {
+		return historicalBalance{}, http.StatusInternalServerError, err
+	}
+	balance := historicalBalance{Balances: balances}
+	for _, d := range discrepancies {
+		if d.Block <= block {
+			balance.Discrepancies = append(balance.Discrepancies, d)
+		}
+	}
+	return balance, http.StatusOK, nil
+}
+
+// eventsPageOf reads a page of events of the filter query parameter starting at the cursor.
+// Returned status describes the error
+func eventsPageOf(r *http.Request, c chain, scoped bool) (eventsPage, int, error) {
+	query := r.URL.Query()
+
+	filterID := query.Get("filter")
+	if filterID == "" {
+		return eventsPage{}, http.StatusBadRequest, errors.New("filter is required")
+	}
+
+	offset := 0
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		offset, err = strconv.Atoi(cursor)
+		if err != nil || offset < 0 {
+			return eventsPage{}, http.StatusBadRequest, fmt.Errorf("invalid cursor %q", cursor)
+		}
+	}
+
+	limit := defaultEventsLimit
+	if param := query.Get("limit"); param != "" {
+		var err error
+		limit, err = strconv.Atoi(param)
+		if err != nil || limit <= 0 || limit > maxEventsLimit {
+			return eventsPage{}, http.StatusBadRequest, fmt.Errorf("invalid limit %q: expected 1 to %d", param, maxEventsLimit)
+		}
+	}
+
+	filters, err := visibleEventFilters(r.Context(), c, scoped)
+	if err != nil {
+		return eventsPage{}, http.StatusInternalServerError, err
+	}
+	if !slices.ContainsFunc(filters, func(f ethereum.EventFilter) bool { return f.ID == filterID }) {
+		return eventsPage{}, http.StatusNotFound, fmt.Errorf("unknown filter %q", filterID)
+	}
+
+	// one extra event tells whether there is a next page
+	events, err := c.GetEvents(r.Context(), filterID, offset, limit+1)
+	if err != nil {
+		return eventsPage{}, http.StatusInternalServerError, err
+	}
+
+	page := eventsPage{Events: events}
+	if len(events) > limit {
+		page.Events = events[:limit]
+		page.NextCursor = strconv.Itoa(offset + limit)
+	}
+	if page.Events == nil {
+		page.Events = []ethereum.Event{}
+	}
+	return page, http.StatusOK, nil
+}
+
+// boolParam parses an optional boolean query parameter, false when missing
+func boolParam(r *http.Request, name string) (bool, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return false, nil
+	}
+	value, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s %q: expected true or false", name, param)
+	}
+	return value, nil
+}
+
+// visibleEventFilters lists event filters the tenant of the request subscribed to, all filters when tenants are not isolated
+func visibleEventFilters(ctx context.Context, c chain, scoped bool) ([]ethereum.EventFilter, error) {
+	filters, err := c.EventFilters(ctx)
+	if err != nil || !scoped {
+		return filters, err
+	}
+
+	subs, err := c.TenantSubscriptions(ctx, tenant.FromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	subscribed := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		if sub.Kind == tenant.SubscriptionKindEvents {
+			subscribed[sub.Target] = true
+		}
+	}
+	visible := make([]ethereum.EventFilter, 0, len(filters))
+	for _, f := range filters {
+		if subscribed[f.ID] {
+			visible = append(visible, f)
+		}
+	}
+	return visible, nil
+}
+
+// parseAddress validates a hex address and lower cases it
+func parseAddress(address string) (string, error) {
+	if address == "" {
+		return "", errors.New("address is required")
+	}
+	return ethereum.ParseAddress(address)
+}
+
+// resolveAddress returns lower cased address, resolving ENS names. Returned status describes the error
+func resolveAddress(ctx context.Context, names NameResolver, address string) (string, int, error) {
+	if !ens.IsName(address) {
+		parsed, err := parseAddress(address)
+		if err != nil {
+			return "", http.StatusBadRequest, err
+		}
+		return parsed, http.StatusOK, nil
+	}
+	if names == nil {
+		return "", http.StatusBadRequest, fmt.Errorf("ens names are not supported")
+	}
+
+	resolved, err := names.ResolveName(ctx, address)
+	if errors.Is(err, ethereum.ErrNameNotFound) {
+		return "", http.StatusNotFound, err
+	}
+	if err != nil {
+		return "", http.StatusInternalServerError, err
+	}
+	return resolved, http.StatusOK, nil
+}
+
+// lookupNames fills ENS names of transaction counterparties. Addresses without a name or failed lookups are left empty
+func lookupNames(ctx context.Context, names NameResolver, txs []transaction, log *slog.Logger) {
+	lookup := func(address string) string {
+		if address == "" {
+			return ""
+		}
+		name, err := names.LookupAddress(ctx, address)
+		if err != nil && !errors.Is(err, ethereum.ErrNameNotFound) {
+			log.WarnContext(ctx, "failed to lookup ens name", "address", address, "error", err)
+		}
+		return name
+	}
+
+	for i := range txs {
+		txs[i].FromName = lookup(txs[i].From)
+		txs[i].ToName = lookup(txs[i].To)
+	}
 }
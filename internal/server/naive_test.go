@@ -0,0 +1,530 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+type MockParser struct {
+	GetCurrentBlockFunc        func(ctx context.Context) (int, error)
+	SubscribeTenantFunc        func(ctx context.Context, tenantID string, sub tenant.Subscription) error
+	UnsubscribeTenantFunc      func(ctx context.Context, tenantID string, address string) (bool, error)
+	GetTransactionsFunc        func(ctx context.Context, address string) ([]ethereum.Transaction, error)
+	GetTransactionsPageFunc    func(ctx context.Context, address string, offset int, limit int) ([]ethereum.Transaction, error)
+	GetBalancesFunc            func(ctx context.Context, address string) (ethereum.Balances, bool, error)
+	GetBalanceSnapshotsFunc    func(ctx context.Context, address string) ([]ethereum.Balances, error)
+	GetDiscrepanciesFunc       func(ctx context.Context, address string) ([]ethereum.BalanceDiscrepancy, error)
+	SubscribeEventsFunc        func(ctx context.Context, filter ethereum.EventFilter) error
+	EventFiltersFunc           func(ctx context.Context) ([]ethereum.EventFilter, error)
+	GetEventsFunc              func(ctx context.Context, filterID string, offset int, limit int) ([]ethereum.Event, error)
+	SaveTenantSubscriptionFunc func(ctx context.Context, tenantID string, sub tenant.Subscription) error
+	TenantSubscriptionsFunc    func(ctx context.Context, tenantID string) ([]tenant.Subscription, error)
+	HasTenantSubscriptionFunc  func(ctx context.Context, tenantID string, kind tenant.SubscriptionKind, target string) (bool, error)
+}
+
+func (m *MockParser) GetCurrentBlock(ctx context.Context) (int, error) {
+	return m.GetCurrentBlockFunc(ctx)
+}
+
+func (m *MockParser) SubscribeTenant(ctx context.Context, tenantID string, sub tenant.Subscription) error {
+	return m.SubscribeTenantFunc(ctx, tenantID, sub)
+}
+
+func (m *MockParser) UnsubscribeTenant(ctx context.Context, tenantID string, address string) (bool, error) {
+	return m.UnsubscribeTenantFunc(ctx, tenantID, address)
+}
+
+func (m *MockParser) GetTransactions(ctx context.Context, address string) ([]ethereum.Transaction, error) {
+	return m.GetTransactionsFunc(ctx, address)
+}
+
+func (m *MockParser) GetTransactionsPage(ctx context.Context, address string, offset int, limit int) ([]ethereum.Transaction, error) {
+	return m.GetTransactionsPageFunc(ctx, address, offset, limit)
+}
+
+func (m *MockParser) GetBalances(ctx context.Context, address string) (ethereum.Balances, bool, error) {
+	return m.GetBalancesFunc(ctx, address)
+}
+
+func (m *MockParser) GetBalanceSnapshots(ctx context.Context, address string) ([]ethereum.Balances, error) {
+	return m.GetBalanceSnapshotsFunc(ctx, address)
+}
+
+func (m *MockParser) GetDiscrepancies(ctx context.Context, address string) ([]ethereum.BalanceDiscrepancy, error) {
+	return m.GetDiscrepanciesFunc(ctx, address)
+}
+
+func (m *MockParser) SubscribeEvents(ctx context.Context, filter ethereum.EventFilter) error {
+	return m.SubscribeEventsFunc(ctx, filter)
+}
+
+func (m *MockParser) EventFilters(ctx context.Context) ([]ethereum.EventFilter, error) {
+	return m.EventFiltersFunc(ctx)
+}
+
+func (m *MockParser) GetEvents(ctx context.Context, filterID string, offset int, limit int) ([]ethereum.Event, error) {
+	return m.GetEventsFunc(ctx, filterID, offset, limit)
+}
+
+func (m *MockParser) SaveTenantSubscription(ctx context.Context, tenantID string, sub tenant.Subscription) error {
+	return m.SaveTenantSubscriptionFunc(ctx, tenantID, sub)
+}
+
+func (m *MockParser) TenantSubscriptions(ctx context.Context, tenantID string) ([]tenant.Subscription, error) {
+	return m.TenantSubscriptionsFunc(ctx, tenantID)
+}
+
+func (m *MockParser) HasTenantSubscription(ctx context.Context, tenantID string, kind tenant.SubscriptionKind, target string) (bool, error) {
+	return m.HasTenantSubscriptionFunc(ctx, tenantID, kind, target)
+}
+
+func currentBlockParser(block int) *MockParser {
+	return &MockParser{GetCurrentBlockFunc: func(ctx context.Context) (int, error) { return block, nil }}
+}
+
+func TestChainRoutes(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := NewNaiveHTTPServer(Chains{1: currentBlockParser(100), 137: currentBlockParser(200)}, log, WithDefaultChain(1))
+
+	tests := []struct {
+		name  string
+		path  string
+		code  int
+		block int
+	}{
+		{name: "chain prefixed route", path: "/chains/137/current_block", code: http.StatusOK, block: 200},
+		{name: "legacy route served by default chain", path: "/current_block", code: http.StatusOK, block: 100},
+		{name: "unknown chain", path: "/chains/10/current_block", code: http.StatusNotFound},
+		{name: "malformed chain id", path: "/chains/polygon/current_block", code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var body map[string]int
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if body["current_block"] != tt.block {
+				t.Fatalf("expected block %d, got %d", tt.block, body["current_block"])
+			}
+		})
+	}
+
+	t.Run("no default chain", func(t *testing.T) {
+		srv := NewNaiveHTTPServer(Chains{137: currentBlockParser(200)}, log)
+
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/current_block", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+}
+
+func TestBalances(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	parser := &MockParser{GetBalancesFunc: func(ctx context.Context, address string) (ethereum.Balances, bool, error) {
+		if address != "0xabcabcabcabcabcabcabcabcabcabcabcabcabca" {
+			return ethereum.Balances{}, false, nil
+		}
+		return ethereum.Balances{Address: address, Block: 100, ETH: "1000", Tokens: map[string]string{"0xtoken": "5"}}, true, nil
+	}}
+	srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1))
+
+	t.Run("tracked address", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/address/0xABCABCABCABCABCABCABCABCABCABCABCABCABCA/balances", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		var balances ethereum.Balances
+		if err := json.NewDecoder(rec.Body).Decode(&balances); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if balances.Block != 100 || balances.ETH != "1000" || balances.Tokens["0xtoken"] != "5" {
+			t.Fatalf("unexpected balances %+v", balances)
+		}
+	})
+
+	t.Run("balances not fetched yet", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/address/0xdefdefdefdefdefdefdefdefdefdefdefdefdefd/balances", nil))
+
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+}
+
+func TestHistoricalBalance(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	address := "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	parser := &MockParser{
+		GetCurrentBlockFunc: func(ctx context.Context) (int, error) { return 300, nil },
+		GetBalanceSnapshotsFunc: func(ctx context.Context, address string) ([]ethereum.Balances, error) {
+			return []ethereum.Balances{{Address: address, Block: 100, ETH: "1000"}}, nil
+		},
+		GetTransactionsFunc: func(ctx context.Context, address string) ([]ethereum.Transaction, error) {
+			return []ethereum.Transaction{{
+				Hash: "0x1", From: "0xbbbb", To: address, Value: "0x64", BlockNumber: "0xc8", Status: ethereum.TransactionStatusMined,
+			}}, nil
+		},
+		GetDiscrepanciesFunc: func(ctx context.Context, address string) ([]ethereum.BalanceDiscrepancy, error) {
+			return []ethereum.BalanceDiscrepancy{{Address: address, Block: 250, Reconstructed: "1100", Actual: "1200"}}, nil
+		},
+	}
+	srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1))
+
+	tests := []struct {
+		name          string
+		query         string
+		code          int
+		eth           string
+		discrepancies int
+	}{
+		{name: "before transfer", query: "?block=150", code: http.StatusOK, eth: "1000"},
+		{name: "current block by default", code: http.StatusOK, eth: "1100", discrepancies: 1},
+		{name: "before tracking started", query: "?block=50", code: http.StatusNotFound},
+		{name: "not processed block", query: "?block=301", code: http.StatusBadRequest},
+		{name: "malformed block", query: "?block=latest", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/address/"+address+"/balance"+tt.query, nil))
+
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+
+			var balance historicalBalance
+			if err := json.NewDecoder(rec.Body).Decode(&balance); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if balance.ETH != tt.eth || len(balance.Discrepancies) != tt.discrepancies {
+				t.Fatalf("unexpected balance %+v", balance)
+			}
+		})
+	}
+}
+
+func TestDecodedTransactions(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	contract := "0x2222222222222222222222222222222222222222"
+	input := "0xa9059cbb" +
+		"0000000000000000000000001111111111111111111111111111111111111111" +
+		"00000000000000000000000000000000000000000000000000000000000003e8"
+	parser := &MockParser{GetTransactionsFunc: func(ctx context.Context, address string) ([]ethereum.Transaction, error) {
+		return []ethereum.Transaction{
+			{Hash: "0x1", From: address, To: contract, Input: input},
+			{Hash: "0x2", From: address, To: "0x3333333333333333333333333333333333333333", Input: "0x"},
+		}, nil
+	}}
+	srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1))
+
+	getTransactions := func(t *testing.T) []transaction {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transactions?address=0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		var txs []transaction
+		if err := json.NewDecoder(rec.Body).Decode(&txs); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		return txs
+	}
+
+	t.Run("common method", func(t *testing.T) {
+		txs := getTransactions(t)
+		if txs[0].Decoded == nil || txs[0].Decoded.Method != "transfer" || txs[0].Decoded.Args[0].Name != "" {
+			t.Fatalf("expected transfer decoded with selector table, got %+v", txs[0].Decoded)
+		}
+		if txs[1].Decoded != nil {
+			t.Fatalf("expected plain transfer not to be decoded, got %+v", txs[1].Decoded)
+		}
+	})
+
+	t.Run("registered abi", func(t *testing.T) {
+		abiJSON := `[{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}]}]`
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/address/"+contract+"/abi", strings.NewReader(abiJSON)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+
+		txs := getTransactions(t)
+		if txs[0].Decoded == nil || txs[0].Decoded.Args[0].Name != "to" || txs[0].Decoded.Args[1].Value != "1000" {
+			t.Fatalf("expected transfer decoded with registered abi, got %+v", txs[0].Decoded)
+		}
+	})
+
+	t.Run("malformed abi", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/address/"+contract+"/abi", strings.NewReader("{")))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+}
+
+func TestEvents(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	transferTopic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+	var filters []ethereum.EventFilter
+	var stored []ethereum.Event
+	parser := &MockParser{
+		SubscribeEventsFunc: func(ctx context.Context, filter ethereum.EventFilter) error {
+			filters = append(filters, filter)
+			return nil
+		},
+		EventFiltersFunc: func(ctx context.Context) ([]ethereum.EventFilter, error) {
+			return filters, nil
+		},
+		SaveTenantSubscriptionFunc: func(ctx context.Context, tenantID string, sub tenant.Subscription) error {
+			return nil
+		},
+		HasTenantSubscriptionFunc: func(ctx context.Context, tenantID string, kind tenant.SubscriptionKind, target string) (bool, error) {
+			return false, nil
+		},
+		GetEventsFunc: func(ctx context.Context, filterID string, offset int, limit int) ([]ethereum.Event, error) {
+			if offset >= len(stored) {
+				return nil, nil
+			}
+			return stored[offset:min(offset+limit, len(stored))], nil
+		},
+	}
+	srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1))
+
+	rec := httptest.NewRecorder()
+	body := `{"address":"0xDAC17F958D2EE523A2206206994597C13D831EC7","topics":["` + transferTopic + `"]}`
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/events/subscriptions", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var filter ethereum.EventFilter
+	if err := json.NewDecoder(rec.Body).Decode(&filter); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if filter.ID == "" || filter.Address != "0xdac17f958d2ee523a2206206994597c13d831ec7" {
+		t.Fatalf("unexpected filter %+v", filter)
+	}
+
+	for i := range 3 {
+		stored = append(stored, ethereum.Event{FilterID: filter.ID, Log: ethereum.Log{TransactionHash: "0x1", LogIndex: strconv.Itoa(i)}})
+	}
+
+	t.Run("pagination", func(t *testing.T) {
+		var hashes []string
+		cursor := ""
+		for range 3 {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events?filter="+filter.ID+"&limit=2&cursor="+cursor, nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+			}
+			var page eventsPage
+			if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			for _, event := range page.Events {
+				hashes = append(hashes, event.LogIndex)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		if strings.Join(hashes, ",") != "0,1,2" {
+			t.Fatalf("expected all events once in order, got %v", hashes)
+		}
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{name: "invalid topic", method: http.MethodPost, path: "/events/subscriptions", body: `{"topics":["0x01"]}`, code: http.StatusBadRequest},
+		{name: "filter matching everything", method: http.MethodPost, path: "/events/subscriptions", body: `{}`, code: http.StatusBadRequest},
+		{name: "missing filter", method: http.MethodGet, path: "/events", code: http.StatusBadRequest},
+		{name: "unknown filter", method: http.MethodGet, path: "/events?filter=abc", code: http.StatusNotFound},
+		{name: "limit too large", method: http.MethodGet, path: "/events?filter=" + filter.ID + "&limit=5000", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if rec.Code != tt.code {
+				t.Fatalf("expected status %d, got %d", tt.code, rec.Code)
+			}
+		})
+	}
+}
+
+type MockNameResolver struct {
+	ResolveNameFunc   func(ctx context.Context, name string) (string, error)
+	LookupAddressFunc func(ctx context.Context, address string) (string, error)
+}
+
+func (m *MockNameResolver) ResolveName(ctx context.Context, name string) (string, error) {
+	return m.ResolveNameFunc(ctx, name)
+}
+
+func (m *MockNameResolver) LookupAddress(ctx context.Context, address string) (string, error) {
+	return m.LookupAddressFunc(ctx, address)
+}
+
+func TestENSNames(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	owner := "0xd8da6bf26964af9d7eed9e03e53415d37aa96045"
+	names := &MockNameResolver{
+		ResolveNameFunc: func(ctx context.Context, name string) (string, error) {
+			if name == "vitalik.eth" {
+				return owner, nil
+			}
+			return "", ethereum.ErrNameNotFound
+		},
+		LookupAddressFunc: func(ctx context.Context, address string) (string, error) {
+			if address == owner {
+				return "vitalik.eth", nil
+			}
+			return "", ethereum.ErrNameNotFound
+		},
+	}
+
+	var subscribed []string
+	parser := &MockParser{
+		SubscribeTenantFunc: func(ctx context.Context, tenantID string, sub tenant.Subscription) error {
+			subscribed = append(subscribed, sub.Target)
+			return nil
+		},
+		HasTenantSubscriptionFunc: func(ctx context.Context, tenantID string, kind tenant.SubscriptionKind, target string) (bool, error) {
+			return false, nil
+		},
+		GetTransactionsFunc: func(ctx context.Context, address string) ([]ethereum.Transaction, error) {
+			if address != owner {
+				return nil, nil
+			}
+			return []ethereum.Transaction{{Hash: "0x1", From: owner, To: "0x2222222222222222222222222222222222222222"}}, nil
+		},
+	}
+
+	t.Run("subscribe by name", func(t *testing.T) {
+		srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1), WithNameResolver(names))
+
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/address/vitalik.eth/subscribe", nil))
+		if rec.Code != http.StatusOK || len(subscribed) != 1 || subscribed[0] != owner {
+			t.Fatalf("expected %s subscribed, got status %d and %v", owner, rec.Code, subscribed)
+		}
+
+		rec = httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/address/missing.eth/subscribe", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("transactions with counterparties names", func(t *testing.T) {
+		srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1), WithNameResolver(names))
+
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transactions?address=vitalik.eth&resolve_names=true", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+		}
+		var txs []transaction
+		if err := json.NewDecoder(rec.Body).Decode(&txs); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if len(txs) != 1 || txs[0].FromName != "vitalik.eth" || txs[0].ToName != "" {
+			t.Fatalf("unexpected transactions %+v", txs)
+		}
+	})
+
+	t.Run("names without resolver", func(t *testing.T) {
+		srv := NewNaiveHTTPServer(Chains{1: parser}, log, WithDefaultChain(1))
+
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/transactions?address=vitalik.eth", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+		}
+	})
+}
+
+func TestUnsubscribe(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	address := "0x1111111111111111111111111111111111111111"
+
+	chainStorage := storage.NewInMemoryStorage()
+	srv := NewNaiveHTTPServer(Chains{1: chainStorage}, log, WithDefaultChain(1), WithAPIKeys(map[string]string{"key-a": "a", "key-b": "b"}))
+	post := func(key, action string) int {
+		req := httptest.NewRequest(http.MethodPost, "/address/"+address+"/"+action, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, key := range []string{"key-a", "key-b"} {
+		if code := post(key, "subscribe"); code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+		}
+	}
+
+	t.Run("address observed while another tenant is subscribed", func(t *testing.T) {
+		if code := post("key-a", "unsubscribe"); code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+		}
+		if ok, _ := chainStorage.IsSubscribed(ctx, address); !ok {
+			t.Fatal("expected address to be observed")
+		}
+	})
+
+	t.Run("not subscribed tenant", func(t *testing.T) {
+		if code := post("key-a", "unsubscribe"); code != http.StatusNotFound {
+			t.Fatalf("expected status %d, got %d", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("last tenant unsubscribed", func(t *testing.T) {
+		if code := post("key-b", "unsubscribe"); code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+		}
+		if ok, _ := chainStorage.IsSubscribed(ctx, address); ok {
+			t.Fatal("expected address not to be observed")
+		}
+	})
+}
@@ -0,0 +1,483 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// apiVersion is the version of the OpenAPI document, bumped with changes of the API
+const apiVersion = "1.0.0"
+
+type jsonObject = map[string]any
+
+// openAPIDocument describes the routes of newHandler. Chain routes are described both under /chains/{chainId}
+// and at the root, where the default chain serves them
+func openAPIDocument() jsonObject {
+	paths := jsonObject{}
+	add := func(method, path string, operation jsonObject) {
+		item, ok := paths[path].(jsonObject)
+		if !ok {
+			item = jsonObject{}
+			paths[path] = item
+		}
+		item[strings.ToLower(method)] = operation
+	}
+	addChain := func(method, path string, operation jsonObject) {
+		responses := maps.Clone(operation["responses"].(jsonObject))
+		maps.Copy(responses, errorResponses(http.StatusUnauthorized, http.StatusTooManyRequests))
+		operation["responses"] = responses
+		add(method, path, operation)
+
+		prefixed := maps.Clone(operation)
+		prefixed["operationId"] = operation["operationId"].(string) + "ByChain"
+		params, _ := operation["parameters"].([]any)
+		prefixed["parameters"] = append([]any{pathParameter("chainId", "chain id", integerSchema())}, params...)
+		prefixedResponses := maps.Clone(responses)
+		maps.Copy(prefixedResponses, errorResponses(http.StatusNotFound))
+		prefixed["responses"] = prefixedResponses
+		add(method, "/chains/{chainId}"+path, prefixed)
+	}
+	addressParameter := pathParameter("address", "hex address, or ENS name where noted", stringSchema())
+
+	add(http.MethodGet, "/chains", jsonObject{
+		"operationId": "listChains",
+		"summary":     "List ids of indexed chains",
+		"responses": merge(
+			jsonObject{"200": jsonResponse("indexed chains", object([]string{"chains"}, jsonObject{"chains": arrayOf(integerSchema())}))},
+			errorResponses(http.StatusUnauthorized, http.StatusTooManyRequests),
+		),
+	})
+
+	addChain(http.MethodPost, "/address/{address}/subscribe", jsonObject{
+		"operationId": "subscribeAddress",
+		"summary":     "Subscribe to transactions of an address or ENS name",
+		"parameters":  []any{addressParameter},
+		"responses": merge(
+			jsonObject{"200": jsonObject{"description": "subscribed"}},
+			errorResponses(http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodPost, "/address/{address}/unsubscribe", jsonObject{
+		"operationId": "unsubscribeAddress",
+		"summary":     "Unsubscribe from an address or ENS name, indexed transactions are kept",
+		"parameters":  []any{addressParameter},
+		"responses": merge(
+			jsonObject{"200": jsonObject{"description": "unsubscribed"}},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodPost, "/address/{address}/backfill", jsonObject{
+		"operationId": "backfillAddress",
+		"summary":     "Index transactions of a subscribed address in past blocks",
+		"parameters": []any{
+			addressParameter,
+			queryParameter("from_block", "first block to index", integerSchema(), true),
+			queryParameter("to_block", fmt.Sprintf("last block to index, current block by default, at most %d blocks per request", maxBackfillBlocks), integerSchema(), false),
+		},
+		"responses": merge(
+			jsonObject{"200": jsonResponse("backfilled range", ref("BackfillResult"))},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError, http.StatusNotImplemented),
+		),
+	})
+
+	addChain(http.MethodGet, "/transactions", jsonObject{
+		"operationId": "listTransactions",
+		"summary":     "List transactions of a subscribed address",
+		"parameters": []any{
+			queryParameter("address", "hex address or ENS name", stringSchema(), true),
+			queryParameter("resolve_names", "add ENS names of counterparties", jsonObject{"type": "boolean"}, false),
+		},
+		"responses": merge(
+			jsonObject{
+				"200": jsonResponse("transactions of the address", arrayOf(ref("Transaction"))),
+				"204": jsonObject{"description": "no transactions"},
+			},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodGet, "/address/{address}/export", jsonObject{
+		"operationId": "exportTransactions",
+		"summary":     "Stream the transaction history of a subscribed address as CSV or NDJSON",
+		"parameters": []any{
+			addressParameter,
+			queryParameter("format", "csv by default", jsonObject{"type": "string", "enum": []any{"csv", "ndjson"}}, false),
+		},
+		"responses": merge(
+			jsonObject{"200": jsonObject{
+				"description": "transactions in storage order, CSV has a header row with the ExportRow property names",
+				"content": jsonObject{
+					"text/csv":             jsonObject{"schema": stringSchema()},
+					"application/x-ndjson": jsonObject{"schema": ref("ExportRow")},
+				},
+			}},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodGet, "/address/{address}/report", jsonObject{
+		"operationId": "getReport",
+		"summary":     "Totals, fees, counterparties and token flows of a subscribed address over a period",
+		"parameters": []any{
+			addressParameter,
+			queryParameter("from", "start of the period as a date or an RFC 3339 time, the first block by default", stringSchema(), false),
+			queryParameter("to", "exclusive end of the period as a date or an RFC 3339 time, now by default", stringSchema(), false),
+			queryParameter("format", "json by default", jsonObject{"type": "string", "enum": []any{"json", "csv"}}, false),
+		},
+		"responses": merge(
+			jsonObject{"200": jsonObject{
+				"description": "report, CSV has a row per total, fees, token and counterparty",
+				"content": jsonObject{
+					"application/json": jsonObject{"schema": ref("Report")},
+					"text/csv":         jsonObject{"schema": stringSchema()},
+				},
+			}},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodPut, "/address/{address}/abi", jsonObject{
+		"operationId": "registerABI",
+		"summary":     "Register the ABI of a contract to decode transaction input of the tenant",
+		"parameters":  []any{addressParameter},
+		"requestBody": jsonObject{
+			"required": true,
+			"content":  jsonObject{"application/json": jsonObject{"schema": arrayOf(jsonObject{"type": "object"})}},
+		},
+		"responses": merge(
+			jsonObject{"200": jsonObject{"description": "registered"}},
+			errorResponses(http.StatusBadRequest, http.StatusRequestEntityTooLarge),
+		),
+	})
+
+	addChain(http.MethodGet, "/address/{address}/balances", jsonObject{
+		"operationId": "getBalances",
+		"summary":     "Latest ETH and token balances of a subscribed address",
+		"parameters":  []any{addressParameter},
+		"responses": merge(
+			jsonObject{"200": jsonResponse("balances", ref("Balances"))},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodGet, "/address/{address}/balance", jsonObject{
+		"operationId": "getHistoricalBalance",
+		"summary":     "Balance of a subscribed address as of a block, reconstructed from indexed transactions",
+		"parameters": []any{
+			addressParameter,
+			queryParameter("block", "block number, current block by default", integerSchema(), false),
+		},
+		"responses": merge(
+			jsonObject{"200": jsonResponse("balance", ref("HistoricalBalance"))},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodPost, "/events/subscriptions", jsonObject{
+		"operationId": "subscribeEvents",
+		"summary":     "Subscribe to logs of a contract matching topics",
+		"requestBody": jsonObject{
+			"required": true,
+			"content":  jsonObject{"application/json": jsonObject{"schema": ref("EventFilterRequest")}},
+		},
+		"responses": merge(
+			jsonObject{"200": jsonResponse("normalized filter", ref("EventFilter"))},
+			errorResponses(http.StatusBadRequest, http.StatusForbidden, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodGet, "/events/subscriptions", jsonObject{
+		"operationId": "listEventFilters",
+		"summary":     "List subscribed event filters",
+		"responses": merge(
+			jsonObject{"200": jsonResponse("event filters", arrayOf(ref("EventFilter")))},
+			errorResponses(http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodGet, "/events", jsonObject{
+		"operationId": "listEvents",
+		"summary":     "Page through logs matched by an event filter",
+		"parameters": []any{
+			queryParameter("filter", "event filter id", stringSchema(), true),
+			queryParameter("cursor", "next_cursor of the previous page", stringSchema(), false),
+			queryParameter("limit", "page size, 100 by default", jsonObject{"type": "integer", "minimum": 1, "maximum": maxEventsLimit}, false),
+		},
+		"responses": merge(
+			jsonObject{"200": jsonResponse("page of events", ref("EventsPage"))},
+			errorResponses(http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodGet, "/subscriptions", jsonObject{
+		"operationId": "listSubscriptions",
+		"summary":     "List address and event subscriptions of the tenant",
+		"responses": merge(
+			jsonObject{"200": jsonResponse("subscriptions", arrayOf(ref("Subscription")))},
+			errorResponses(http.StatusInternalServerError),
+		),
+	})
+
+	addChain(http.MethodGet, "/current_block", jsonObject{
+		"operationId": "getCurrentBlock",
+		"summary":     "Last processed block",
+		"responses": merge(
+			jsonObject{"200": jsonResponse("current block", object([]string{"current_block"}, jsonObject{"current_block": integerSchema()}))},
+			errorResponses(http.StatusInternalServerError),
+		),
+	})
+
+	add(http.MethodGet, "/openapi.json", jsonObject{
+		"operationId": "getOpenAPI",
+		"summary":     "This document",
+		"security":    []any{},
+		"responses":   jsonObject{"200": jsonResponse("OpenAPI document", jsonObject{"type": "object"})},
+	})
+
+	add(http.MethodGet, "/metrics", jsonObject{
+		"operationId": "getMetrics",
+		"summary":     "Prometheus metrics",
+		"security":    []any{},
+		"responses": jsonObject{"200": jsonObject{
+			"description": "metrics in Prometheus text format",
+			"content":     jsonObject{"text/plain": jsonObject{"schema": stringSchema()}},
+		}},
+	})
+
+	add(http.MethodGet, "/healthz", jsonObject{
+		"operationId": "getHealth",
+		"summary":     "Liveness of the process",
+		"security":    []any{},
+		"responses":   jsonObject{"200": jsonResponse("alive", ref("Health"))},
+	})
+
+	add(http.MethodGet, "/readyz", jsonObject{
+		"operationId": "getReadiness",
+		"summary":     "Readiness to serve traffic, failed checks otherwise",
+		"security":    []any{},
+		"responses": jsonObject{
+			"200": jsonResponse("ready", ref("Health")),
+			"503": jsonResponse("not ready", ref("Health")),
+		},
+	})
+
+	return jsonObject{
+		"openapi": "3.0.3",
+		"info": jsonObject{
+			"title":       "Ethereum transactions parser",
+			"description": "Indexes transactions, balances and contract events of subscribed addresses on EVM chains",
+			"version":     apiVersion,
+		},
+		// authentication is optional, servers run without api keys are single-tenant
+		"security": []any{jsonObject{"bearerAuth": []any{}}, jsonObject{"apiKeyAuth": []any{}}, jsonObject{}},
+		"paths":    paths,
+		"components": jsonObject{
+			"securitySchemes": jsonObject{
+				"bearerAuth": jsonObject{"type": "http", "scheme": "bearer"},
+				"apiKeyAuth": jsonObject{"type": "apiKey", "in": "header", "name": apiKeyHeader},
+			},
+			"responses": jsonObject{
+				"Error": jsonResponse("error", ref("Error")),
+			},
+			"schemas": openAPISchemas(),
+		},
+	}
+}
+
+func openAPISchemas() jsonObject {
+	balances := jsonObject{
+		"address": stringSchema(),
+		"block":   integerSchema(),
+		"eth":     describe(stringSchema(), "wei, decimal"),
+		"tokens":  describe(jsonObject{"type": "object", "additionalProperties": stringSchema()}, "token contract address -> balance, decimal"),
+	}
+
+	return jsonObject{
+		"Error": object([]string{"error"}, jsonObject{
+			"error": object([]string{"code", "message"}, jsonObject{
+				"code":       stringSchema(),
+				"message":    stringSchema(),
+				"request_id": stringSchema(),
+			}),
+		}),
+		"Transaction": object([]string{"from", "to", "value", "hash", "nonce", "blockNumber"}, jsonObject{
+			"from":              stringSchema(),
+			"to":                stringSchema(),
+			"value":             describe(stringSchema(), "wei, hex quantity"),
+			"hash":              stringSchema(),
+			"nonce":             stringSchema(),
+			"input":             stringSchema(),
+			"blockNumber":       describe(stringSchema(), "hex quantity, empty for pending transactions"),
+			"blockTimestamp":    describe(stringSchema(), "unix time hex quantity, empty for pending transactions"),
+			"logIndex":          describe(stringSchema(), "index of the event a token transfer is derived from"),
+			"token":             describe(stringSchema(), "contract of the transferred token"),
+			"status":            jsonObject{"type": "string", "enum": []any{"pending", "mined", "replaced", "dropped"}},
+			"gasUsed":           stringSchema(),
+			"effectiveGasPrice": stringSchema(),
+			"failed":            jsonObject{"type": "boolean"},
+			"decoded":           ref("Call"),
+			"fromName":          stringSchema(),
+			"toName":            stringSchema(),
+		}),
+		"ExportRow": object(exportColumns, jsonObject{
+			"hash":                stringSchema(),
+			"log_index":           describe(stringSchema(), "decimal, empty for plain transactions"),
+			"block_number":        describe(stringSchema(), "decimal, empty for pending transactions"),
+			"timestamp":           describe(stringSchema(), "RFC 3339 block time, empty for pending transactions"),
+			"status":              stringSchema(),
+			"direction":           jsonObject{"type": "string", "enum": []any{"in", "out", "self"}},
+			"from":                stringSchema(),
+			"to":                  stringSchema(),
+			"token":               describe(stringSchema(), "contract of the transferred token, empty for ETH"),
+			"value":               describe(stringSchema(), "decimal amount in the smallest units"),
+			"value_eth":           describe(stringSchema(), "decimal ETH, empty for token transfers"),
+			"method":              describe(stringSchema(), "decoded method of the call"),
+			"gas_used":            describe(stringSchema(), "decimal"),
+			"effective_gas_price": describe(stringSchema(), "decimal wei"),
+			"fee_eth":             describe(stringSchema(), "decimal ETH paid by the sender, empty without a recorded receipt"),
+			"failed":              jsonObject{"type": "boolean"},
+		}),
+		"Report": object([]string{"address", "from", "to", "eth", "fees", "tokens", "counterparties", "transactions", "undated"}, jsonObject{
+			"address":        stringSchema(),
+			"from":           jsonObject{"type": "string", "format": "date-time"},
+			"to":             jsonObject{"type": "string", "format": "date-time"},
+			"eth":            describe(ref("Flows"), "wei moved by successful plain transactions"),
+			"fees":           describe(stringSchema(), "wei paid in fees, decimal"),
+			"tokens":         describe(jsonObject{"type": "object", "additionalProperties": ref("Flows")}, "token contract address -> flows"),
+			"counterparties": arrayOf(ref("Counterparty")),
+			"transactions":   integerSchema(),
+			"undated":        describe(integerSchema(), "mined transactions without a recorded block time, not attributed to the period"),
+		}),
+		"Flows": object([]string{"in", "out", "net"}, jsonObject{
+			"in":  describe(stringSchema(), "decimal in the smallest units"),
+			"out": describe(stringSchema(), "decimal in the smallest units"),
+			"net": describe(stringSchema(), "in minus out"),
+		}),
+		"Counterparty": object([]string{"address", "eth", "transactions"}, jsonObject{
+			"address":      stringSchema(),
+			"eth":          ref("Flows"),
+			"transactions": integerSchema(),
+		}),
+		"Call": object([]string{"method", "signature", "args"}, jsonObject{
+			"method":    stringSchema(),
+			"signature": stringSchema(),
+			"args": arrayOf(object([]string{"type", "value"}, jsonObject{
+				"name":  stringSchema(),
+				"type":  stringSchema(),
+				"value": jsonObject{"description": "decoded value, nested arrays for arrays and tuples"},
+			})),
+		}),
+		"Balances": object([]string{"address", "block", "eth"}, balances),
+		"HistoricalBalance": object([]string{"address", "block", "eth"}, merge(balances, jsonObject{
+			"discrepancies": arrayOf(ref("BalanceDiscrepancy")),
+		})),
+		"BalanceDiscrepancy": object([]string{"address", "block", "reconstructed", "actual"}, jsonObject{
+			"address":       stringSchema(),
+			"block":         integerSchema(),
+			"reconstructed": stringSchema(),
+			"actual":        stringSchema(),
+		}),
+		"EventFilterRequest": object(nil, jsonObject{
+			"address": describe(stringSchema(), "contract address, any contract when empty"),
+			"topics":  describe(arrayOf(stringSchema()), "up to 4 32 bytes topics, empty matches any value"),
+		}),
+		"EventFilter": object([]string{"id"}, jsonObject{
+			"id":      stringSchema(),
+			"address": stringSchema(),
+			"topics":  arrayOf(stringSchema()),
+		}),
+		"Event": object([]string{"filterId", "address", "topics", "data", "blockNumber", "transactionHash", "logIndex", "removed"}, jsonObject{
+			"filterId":        stringSchema(),
+			"address":         stringSchema(),
+			"topics":          arrayOf(stringSchema()),
+			"data":            stringSchema(),
+			"blockNumber":     stringSchema(),
+			"transactionHash": stringSchema(),
+			"logIndex":        stringSchema(),
+			"removed":         jsonObject{"type": "boolean"},
+		}),
+		"EventsPage": object([]string{"events"}, jsonObject{
+			"events":      arrayOf(ref("Event")),
+			"next_cursor": stringSchema(),
+		}),
+		"Subscription": object([]string{"kind", "target", "created_at"}, jsonObject{
+			"kind":       jsonObject{"type": "string", "enum": []any{"address", "events"}},
+			"target":     describe(stringSchema(), "address or event filter id"),
+			"created_at": jsonObject{"type": "string", "format": "date-time"},
+		}),
+		"BackfillResult": object([]string{"from_block", "to_block", "transactions"}, jsonObject{
+			"from_block":   integerSchema(),
+			"to_block":     integerSchema(),
+			"transactions": describe(integerSchema(), "transactions and token transfers saved, including already indexed ones"),
+		}),
+		"Health": object([]string{"status"}, jsonObject{
+			"status": stringSchema(),
+			"checks": jsonObject{"type": "object", "additionalProperties": stringSchema()},
+		}),
+	}
+}
+
+// object is a schema of an object without undocumented properties
+func object(required []string, properties jsonObject) jsonObject {
+	schema := jsonObject{"type": "object", "properties": properties, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = slices.Clone(required)
+	}
+	return schema
+}
+
+func arrayOf(items jsonObject) jsonObject {
+	return jsonObject{"type": "array", "items": items}
+}
+
+func stringSchema() jsonObject {
+	return jsonObject{"type": "string"}
+}
+
+func integerSchema() jsonObject {
+	return jsonObject{"type": "integer"}
+}
+
+func describe(schema jsonObject, description string) jsonObject {
+	schema["description"] = description
+	return schema
+}
+
+func ref(name string) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+func merge(a, b jsonObject) jsonObject {
+	merged := maps.Clone(a)
+	maps.Copy(merged, b)
+	return merged
+}
+
+func jsonResponse(description string, schema jsonObject) jsonObject {
+	return jsonObject{
+		"description": description,
+		"content":     jsonObject{"application/json": jsonObject{"schema": schema}},
+	}
+}
+
+func errorResponses(statuses ...int) jsonObject {
+	responses := jsonObject{}
+	for _, status := range statuses {
+		responses[strconv.Itoa(status)] = jsonObject{"$ref": "#/components/responses/Error"}
+	}
+	return responses
+}
+
+func pathParameter(name, description string, schema jsonObject) jsonObject {
+	return jsonObject{"name": name, "in": "path", "required": true, "description": description, "schema": schema}
+}
+
+func queryParameter(name, description string, schema jsonObject, required bool) jsonObject {
+	return jsonObject{"name": name, "in": "query", "required": required, "description": description, "schema": schema}
+}
@@ -0,0 +1,345 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+// servedDocument fetches the OpenAPI document the way clients do
+func servedDocument(t *testing.T, handler http.Handler) map[string]any {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var doc map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+	return doc
+}
+
+// operations lists operations of the document as mux patterns, e.g. "GET /transactions"
+func operations(doc map[string]any) []string {
+	var ops []string
+	for path, item := range doc["paths"].(map[string]any) {
+		for method := range item.(map[string]any) {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(ops)
+	return ops
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler, routes := newHandler(Chains{1: storage.NewInMemoryStorage()}, log, WithDefaultChain(1))
+
+	slices.Sort(routes)
+	if ops := operations(servedDocument(t, handler)); !slices.Equal(routes, ops) {
+		t.Fatalf("document does not match routes\nroutes:     %v\noperations: %v", routes, ops)
+	}
+}
+
+func TestOpenAPIConformance(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	address := "0x1111111111111111111111111111111111111111"
+	counterparty := "0x2222222222222222222222222222222222222222"
+	transferTopic := "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+
+	chainStorage := storage.NewInMemoryStorage()
+	handler, _ := newHandler(Chains{1: chainStorage}, log,
+		WithDefaultChain(1),
+		WithAPIKeys(map[string]string{"key": "team"}),
+		WithReadinessCheck("storage_1", chainStorage.Ping),
+		WithBackfiller(1, &MockBackfiller{BackfillFunc: func(ctx context.Context, address string, fromBlock, toBlock int) (int, error) {
+			return 1, nil
+		}}))
+
+	_ = chainStorage.SaveBalances(ctx, ethereum.Balances{Address: address, Block: 100, ETH: "1000", Tokens: map[string]string{counterparty: "5"}})
+	_ = chainStorage.CommitBlock(ctx, 101, []ethereum.AddressTx{{
+		Address: address,
+		Tx: ethereum.Transaction{
+			Hash: "0x01", From: counterparty, To: address, Value: "0x64", Nonce: "0x0", BlockNumber: "0x65",
+			BlockTimestamp: "0x6554d1c7", Status: ethereum.TransactionStatusMined,
+			Input: "0xa9059cbb" + strings.Repeat("0", 64) + strings.Repeat("0", 63) + "1",
+		},
+	}}, nil)
+	_ = chainStorage.SetCurrentBlock(ctx, 101)
+
+	var filterID string
+	tests := []struct {
+		name   string
+		method string
+		path   func() string
+		body   string
+		key    string
+		status int
+		// runs after the request, e.g. to seed data found by later requests
+		after func(t *testing.T, body []byte)
+	}{
+		{name: "unauthenticated", method: "GET", path: static("/current_block"), status: http.StatusUnauthorized},
+		{name: "chains", method: "GET", path: static("/chains"), key: "key", status: http.StatusOK},
+		{name: "subscribe", method: "POST", path: static("/address/" + address + "/subscribe"), key: "key", status: http.StatusOK},
+		{name: "subscribe again on chain", method: "POST", path: static("/chains/1/address/" + address + "/subscribe"), key: "key", status: http.StatusOK},
+		{name: "transactions", method: "GET", path: static("/transactions?address=" + address), key: "key", status: http.StatusOK},
+		{name: "transactions on chain", method: "GET", path: static("/chains/1/transactions?address=" + address), key: "key", status: http.StatusOK},
+		{name: "transactions of unknown chain", method: "GET", path: static("/chains/5/transactions?address=" + address), key: "key", status: http.StatusNotFound},
+		{name: "export", method: "GET", path: static("/address/" + address + "/export"), key: "key", status: http.StatusOK},
+		{name: "export on chain", method: "GET", path: static("/chains/1/address/" + address + "/export?format=ndjson"), key: "key", status: http.StatusOK},
+		{name: "report", method: "GET", path: static("/address/" + address + "/report?from=2023-01-01"), key: "key", status: http.StatusOK},
+		{name: "report on chain", method: "GET", path: static("/chains/1/address/" + address + "/report?format=csv"), key: "key", status: http.StatusOK},
+		{name: "backfill", method: "POST", path: static("/address/" + address + "/backfill?from_block=100"), key: "key", status: http.StatusOK},
+		{name: "backfill on chain", method: "POST", path: static("/chains/1/address/" + address + "/backfill?from_block=latest"), key: "key", status: http.StatusBadRequest},
+		{name: "register abi", method: "PUT", path: static("/address/" + counterparty + "/abi"), body: "[]", key: "key", status: http.StatusOK},
+		{name: "register malformed abi on chain", method: "PUT", path: static("/chains/1/address/" + counterparty + "/abi"), body: "{", key: "key", status: http.StatusBadRequest},
+		{name: "balances", method: "GET", path: static("/address/" + address + "/balances"), key: "key", status: http.StatusOK},
+		{name: "balances on chain", method: "GET", path: static("/chains/1/address/" + address + "/balances"), key: "key", status: http.StatusOK},
+		{name: "historical balance", method: "GET", path: static("/address/" + address + "/balance?block=101"), key: "key", status: http.StatusOK},
+		{name: "historical balance on chain", method: "GET", path: static("/chains/1/address/" + address + "/balance?block=latest"), key: "key", status: http.StatusBadRequest},
+		{
+			name: "subscribe events", method: "POST", path: static("/events/subscriptions"),
+			body: `{"address":"` + counterparty + `","topics":["` + transferTopic + `"]}`, key: "key", status: http.StatusOK,
+			after: func(t *testing.T, body []byte) {
+				var filter ethereum.EventFilter
+				if err := json.Unmarshal(body, &filter); err != nil {
+					t.Fatalf("failed to decode filter: %v", err)
+				}
+				filterID = filter.ID
+				_ = chainStorage.CommitBlock(ctx, 102, nil, []ethereum.Event{{FilterID: filterID, Log: ethereum.Log{
+					Address: counterparty, Topics: []string{transferTopic}, Data: "0x", BlockNumber: "0x66", TransactionHash: "0x02", LogIndex: "0x0",
+				}}})
+			},
+		},
+		{name: "subscribe events on chain", method: "POST", path: static("/chains/1/events/subscriptions"), body: `{}`, key: "key", status: http.StatusBadRequest},
+		{name: "event filters", method: "GET", path: static("/events/subscriptions"), key: "key", status: http.StatusOK},
+		{name: "event filters on chain", method: "GET", path: static("/chains/1/events/subscriptions"), key: "key", status: http.StatusOK},
+		{name: "events", method: "GET", path: func() string { return "/events?filter=" + filterID }, key: "key", status: http.StatusOK},
+		{name: "events on chain", method: "GET", path: static("/chains/1/events?filter=unknown"), key: "key", status: http.StatusNotFound},
+		{name: "subscriptions", method: "GET", path: static("/subscriptions"), key: "key", status: http.StatusOK},
+		{name: "subscriptions on chain", method: "GET", path: static("/chains/1/subscriptions"), key: "key", status: http.StatusOK},
+		{name: "current block", method: "GET", path: static("/current_block"), key: "key", status: http.StatusOK},
+		{name: "current block on chain", method: "GET", path: static("/chains/1/current_block"), key: "key", status: http.StatusOK},
+		{name: "unsubscribe", method: "POST", path: static("/address/" + address + "/unsubscribe"), key: "key", status: http.StatusOK},
+		{name: "unsubscribe again on chain", method: "POST", path: static("/chains/1/address/" + address + "/unsubscribe"), key: "key", status: http.StatusNotFound},
+		{name: "metrics", method: "GET", path: static("/metrics"), status: http.StatusOK},
+		{name: "health", method: "GET", path: static("/healthz"), status: http.StatusOK},
+		{name: "readiness", method: "GET", path: static("/readyz"), status: http.StatusOK},
+		{name: "document", method: "GET", path: static("/openapi.json"), status: http.StatusOK},
+	}
+
+	doc := servedDocument(t, handler)
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path(), strings.NewReader(tt.body))
+			if tt.key != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.key)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+
+			op, operation := findOperation(doc, tt.method, req.URL.Path)
+			if operation == nil {
+				t.Fatalf("no operation for %s %s", tt.method, req.URL.Path)
+			}
+			covered[op] = true
+			if err := checkResponse(doc, operation, rec); err != nil {
+				t.Fatalf("%s: %v", op, err)
+			}
+			if tt.after != nil {
+				tt.after(t, rec.Body.Bytes())
+			}
+		})
+	}
+
+	for _, op := range operations(doc) {
+		if !covered[op] {
+			t.Errorf("operation %s is not exercised", op)
+		}
+	}
+}
+
+func static(path string) func() string {
+	return func() string { return path }
+}
+
+// findOperation matches a request path against path templates of the document
+func findOperation(doc map[string]any, method, path string) (string, map[string]any) {
+	segments := strings.Split(path, "/")
+	for template, item := range doc["paths"].(map[string]any) {
+		templateSegments := strings.Split(template, "/")
+		if len(templateSegments) != len(segments) {
+			continue
+		}
+		matches := true
+		for i, segment := range templateSegments {
+			if !strings.HasPrefix(segment, "{") && segment != segments[i] {
+				matches = false
+				break
+			}
+		}
+		if operation, ok := item.(map[string]any)[strings.ToLower(method)].(map[string]any); matches && ok {
+			return method + " " + template, operation
+		}
+	}
+	return "", nil
+}
+
+// checkResponse checks the status of the response is documented and its body matches the documented schema
+func checkResponse(doc map[string]any, operation map[string]any, rec *httptest.ResponseRecorder) error {
+	response, ok := operation["responses"].(map[string]any)[strconv.Itoa(rec.Code)].(map[string]any)
+	if !ok {
+		return fmt.Errorf("status %d is not documented", rec.Code)
+	}
+	response = resolve(doc, response)
+
+	content, ok := response["content"].(map[string]any)
+	if !ok {
+		if rec.Body.Len() > 0 {
+			return fmt.Errorf("undocumented body %q", rec.Body.String())
+		}
+		return nil
+	}
+	contentType, _, err := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("invalid content type: %w", err)
+	}
+	media, ok := content[contentType].(map[string]any)
+	if !ok {
+		return fmt.Errorf("undocumented content type %q", contentType)
+	}
+
+	switch contentType {
+	case "application/json":
+		var body any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			return fmt.Errorf("invalid json body: %w", err)
+		}
+		return validate(doc, media["schema"].(map[string]any), body, "body")
+	case "application/x-ndjson":
+		for i, line := range strings.Split(rec.Body.String(), "\n") {
+			if line == "" {
+				continue
+			}
+			var body any
+			if err := json.Unmarshal([]byte(line), &body); err != nil {
+				return fmt.Errorf("invalid json line %d: %w", i, err)
+			}
+			if err := validate(doc, media["schema"].(map[string]any), body, fmt.Sprintf("line %d", i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve follows a local reference of the document
+func resolve(doc map[string]any, object map[string]any) map[string]any {
+	target, ok := object["$ref"].(string)
+	if !ok {
+		return object
+	}
+	resolved := any(doc)
+	for _, segment := range strings.Split(strings.TrimPrefix(target, "#/"), "/") {
+		resolved = resolved.(map[string]any)[segment]
+	}
+	return resolve(doc, resolved.(map[string]any))
+}
+
+// validate checks a decoded JSON value against the subset of JSON Schema the document uses
+func validate(doc map[string]any, schema map[string]any, value any, path string) error {
+	schema = resolve(doc, schema)
+
+	if enum, ok := schema["enum"].([]any); ok && !slices.Contains(enum, value) {
+		return fmt.Errorf("%s: %v is not one of %v", path, value, enum)
+	}
+
+	switch schema["type"] {
+	case nil:
+		return nil
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				return fmt.Errorf("%s: missing required %s", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for name, property := range object {
+			propertySchema, ok := properties[name].(map[string]any)
+			if !ok {
+				switch additional := schema["additionalProperties"].(type) {
+				case bool:
+					if !additional {
+						return fmt.Errorf("%s: undocumented property %s", path, name)
+					}
+					continue
+				case map[string]any:
+					propertySchema = additional
+				default:
+					continue
+				}
+			}
+			if err := validate(doc, propertySchema, property, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		for i, item := range items {
+			if err := validate(doc, schema["items"].(map[string]any), item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected integer, got %v", path, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %v", path, schema["type"])
+	}
+	return nil
+}
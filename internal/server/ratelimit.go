@@ -0,0 +1,221 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+// RateLimit is a token bucket refilled with Rate tokens per second up to Burst tokens, a request takes a token
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// WithRateLimit limits requests of every client, identified by tenant when authenticated and by IP otherwise
+func WithRateLimit(limit RateLimit) Option {
+	return func(o *options) {
+		o.rateLimit = &limit
+	}
+}
+
+// WithRouteRateLimit limits requests of every client to a route, e.g. "GET /transactions", separately from
+// the default limit. Routes are matched with and without the /chains/{chainId} prefix
+func WithRouteRateLimit(route string, limit RateLimit) Option {
+	return func(o *options) {
+		o.routeRateLimits[route] = limit
+	}
+}
+
+// WithSubscriptionQuota limits number of address and event subscriptions of a tenant per chain
+func WithSubscriptionQuota(quota int) Option {
+	return func(o *options) {
+		o.subscriptionQuota = quota
+	}
+}
+
+// maxBuckets is the number of buckets full ones are evicted at, one client hits a few routes
+const maxBuckets = 100000
+
+type bucketKey struct {
+	client string
+	route  string
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// limiter keeps token buckets of clients
+type limiter struct {
+	defaultLimit *RateLimit
+	routeLimits  map[string]RateLimit
+	now          func() time.Time
+
+	mu      sync.Mutex
+	buckets map[bucketKey]*bucket
+}
+
+func newLimiter(defaultLimit *RateLimit, routeLimits map[string]RateLimit) *limiter {
+	return &limiter{
+		defaultLimit: defaultLimit,
+		routeLimits:  routeLimits,
+		now:          time.Now,
+		buckets:      make(map[bucketKey]*bucket),
+	}
+}
+
+// allow takes a token from the bucket of the client for the route. When the bucket is empty it returns
+// how long until the next token
+func (l *limiter) allow(client, route string) (bool, time.Duration) {
+	return l.take(client, route, true)
+}
+
+// wait returns how long until the bucket of the client for the route has a token, without taking it
+func (l *limiter) wait(client, route string) time.Duration {
+	_, retryAfter := l.take(client, route, false)
+	return retryAfter
+}
+
+func (l *limiter) take(client, route string, consume bool) (bool, time.Duration) {
+	limit, ok := l.routeLimits[route]
+	if !ok {
+		if l.defaultLimit == nil {
+			return true, 0
+		}
+		// routes without own limit share the default bucket of the client
+		limit, route = *l.defaultLimit, ""
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key := bucketKey{client: client, route: route}
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxBuckets {
+			l.evictFull(now)
+		}
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.updated).Seconds()*limit.Rate)
+	b.updated = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+	}
+	if consume {
+		b.tokens--
+	}
+	return true, 0
+}
+
+// evictFull drops buckets refilled to their burst, they are the same as new ones
+func (l *limiter) evictFull(now time.Time) {
+	for key, b := range l.buckets {
+		limit, ok := l.routeLimits[key.route]
+		if !ok && l.defaultLimit != nil {
+			limit = *l.defaultLimit
+		}
+		if b.tokens+now.Sub(b.updated).Seconds()*limit.Rate >= float64(limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// rateLimit rejects requests of clients which ran out of tokens with 429 and Retry-After
+func rateLimit(next http.Handler, serverMux *http.ServeMux, l *limiter, log *slog.Logger) http.Handler {
+	if l.defaultLimit == nil && len(l.routeLimits) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if unauthenticatedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		_, pattern := serverMux.Handler(r)
+		route := strings.Replace(pattern, " /chains/{chainId}/", " /", 1)
+		client := clientOf(r)
+
+		if ok, retryAfter := l.allow(client, route); !ok {
+			rateLimitedRequests.WithLabelValues(route).Inc()
+			log.WarnContext(r.Context(), "rate limited request", "client", client, "route", route, "retry_after", retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, fmt.Errorf("rate limit of %s exceeded", route), log)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientOf identifies the client of a request by tenant when authenticated and by remote IP otherwise.
+// Forwarding headers are not trusted, so behind a proxy limits need authentication
+func clientOf(r *http.Request) string {
+	if tenantID, ok := tenant.Lookup(r.Context()); ok {
+		return "tenant:" + tenantID
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// ErrSubscriptionQuota is returned by CheckSubscriptionQuota when the tenant reached its subscription quota
+var ErrSubscriptionQuota = errors.New("subscription quota reached")
+
+// CheckSubscriptionQuota rejects a new subscription of the tenant of the request when it reached the quota.
+// Subscribing again to the same target is allowed, quota of 0 is unlimited. Shared by the HTTP and gRPC APIs,
+// which map errors to their statuses
+func CheckSubscriptionQuota(ctx context.Context, parser Parser, quota int, kind tenant.SubscriptionKind, target string) error {
+	if quota <= 0 {
+		return nil
+	}
+
+	tenantID := tenant.FromContext(ctx)
+	subscribed, err := parser.HasTenantSubscription(ctx, tenantID, kind, target)
+	if err != nil {
+		return fmt.Errorf("check tenant subscription: %w", err)
+	}
+	if subscribed {
+		return nil
+	}
+
+	// concurrent subscriptions may exceed the quota by a few, which is fine for a guard against runaway clients
+	subs, err := parser.TenantSubscriptions(ctx, tenantID)
+	if err != nil {
+		return fmt.Errorf("list tenant subscriptions: %w", err)
+	}
+	if len(subs) >= quota {
+		return fmt.Errorf("tenant %s reached quota of %d subscriptions: %w", tenantID, quota, ErrSubscriptionQuota)
+	}
+	return nil
+}
+
+// checkSubscriptionQuota is CheckSubscriptionQuota of a chain. Returned status describes the error
+func checkSubscriptionQuota(ctx context.Context, c chain, quota int, kind tenant.SubscriptionKind, target string) (int, error) {
+	err := CheckSubscriptionQuota(ctx, c.Parser, quota, kind, target)
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.Is(err, ErrSubscriptionQuota):
+		return http.StatusForbidden, err
+	default:
+		return http.StatusInternalServerError, err
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := newLimiter(&RateLimit{Rate: 1, Burst: 2}, map[string]RateLimit{"GET /transactions": {Rate: 0.5, Burst: 1}})
+	l.now = func() time.Time { return now }
+
+	t.Run("burst is allowed then client waits for a token", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			if ok, _ := l.allow("a", "GET /current_block"); !ok {
+				t.Fatalf("expected request %d allowed", i)
+			}
+		}
+		// routes without own limit share the default bucket
+		ok, retryAfter := l.allow("a", "GET /chains")
+		if ok || retryAfter != time.Second {
+			t.Fatalf("expected request limited for 1s, got %v %v", ok, retryAfter)
+		}
+
+		now = now.Add(time.Second)
+		if ok, _ := l.allow("a", "GET /current_block"); !ok {
+			t.Fatal("expected request allowed after refill")
+		}
+	})
+
+	t.Run("clients and limited routes have own buckets", func(t *testing.T) {
+		if ok, _ := l.allow("b", "GET /current_block"); !ok {
+			t.Fatal("expected other client allowed")
+		}
+		if ok, _ := l.allow("a", "GET /transactions"); !ok {
+			t.Fatal("expected route with own limit allowed")
+		}
+		ok, retryAfter := l.allow("a", "GET /transactions")
+		if ok || retryAfter != 2*time.Second {
+			t.Fatalf("expected request limited for 2s, got %v %v", ok, retryAfter)
+		}
+	})
+}
+
+func TestRateLimit(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("limited requests get 429 with retry after", func(t *testing.T) {
+		srv := NewNaiveHTTPServer(Chains{1: currentBlockParser(100)}, log,
+			WithDefaultChain(1),
+			WithRouteRateLimit("GET /current_block", RateLimit{Rate: 0.1, Burst: 1}))
+
+		codes := make([]int, 0, 3)
+		for _, path := range []string{"/current_block", "/chains/1/current_block", "/healthz"} {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+			codes = append(codes, rec.Code)
+			if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") != "10" {
+				t.Fatalf("expected retry after 10s, got %q", rec.Header().Get("Retry-After"))
+			}
+		}
+		// prefixed and legacy routes share the bucket, health checks are not limited
+		if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests || codes[2] != http.StatusOK {
+			t.Fatalf("unexpected codes %v", codes)
+		}
+	})
+
+	t.Run("subscriptions over quota are rejected", func(t *testing.T) {
+		srv := NewNaiveHTTPServer(Chains{1: storage.NewInMemoryStorage()}, log,
+			WithDefaultChain(1),
+			WithSubscriptionQuota(1))
+
+		subscribe := func(address string) int {
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, httptest.NewRequest("POST", "/address/"+address+"/subscribe", nil))
+			return rec.Code
+		}
+		first := "0x" + strings.Repeat("1", 40)
+		if code := subscribe(first); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		// subscribing again is idempotent and does not count against the quota
+		if code := subscribe(first); code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", code)
+		}
+		if code := subscribe("0x" + strings.Repeat("2", 40)); code != http.StatusForbidden {
+			t.Fatalf("expected 403, got %d", code)
+		}
+	})
+}
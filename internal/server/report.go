@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/report"
+)
+
+// dateLayout is accepted for report periods next to RFC 3339 times, as midnight UTC
+const dateLayout = time.DateOnly
+
+// reportPeriod parses the [from, to) period of a report, from the first block until now by default
+func reportPeriod(r *http.Request, now time.Time) (time.Time, time.Time, error) {
+	from, err := timeParam(r, "from", time.Unix(0, 0).UTC())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	to, err := timeParam(r, "to", now.UTC())
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid period: from %s is not before to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return from, to, nil
+}
+
+func timeParam(r *http.Request, name string, fallback time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: expected a date or an RFC 3339 time", name, value)
+	}
+	return t.UTC(), nil
+}
+
+// buildReport reads transactions of the address page by page into a report of the period
+func buildReport(ctx context.Context, c chain, address string, from, to time.Time) (report.Report, error) {
+	b := report.NewBuilder(address, from, to)
+	for offset := 0; ; offset += exportPageSize {
+		page, err := c.GetTransactionsPage(ctx, address, offset, exportPageSize)
+		if err != nil {
+			return report.Report{}, fmt.Errorf("get transactions at %d: %w", offset, err)
+		}
+		for _, tx := range page {
+			if err := b.Add(tx); err != nil {
+				return report.Report{}, err
+			}
+		}
+		if len(page) < exportPageSize {
+			return b.Report(), nil
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/report"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/storage"
+)
+
+func TestReport(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx := context.Background()
+	address := "0x1111111111111111111111111111111111111111"
+	counterparty := "0x2222222222222222222222222222222222222222"
+
+	minedAt := func(t time.Time) string {
+		return ethereum.FormatQuantity(int(t.Unix()))
+	}
+	chainStorage := storage.NewInMemoryStorage()
+	_ = chainStorage.CommitBlock(ctx, 101, []ethereum.AddressTx{
+		{Address: address, Tx: ethereum.Transaction{
+			Hash: "0x01", From: counterparty, To: address, Value: "0x14d1120d7b160000", Status: ethereum.TransactionStatusMined,
+			BlockTimestamp: minedAt(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)),
+		}},
+		{Address: address, Tx: ethereum.Transaction{
+			Hash: "0x02", From: address, To: counterparty, Value: "0xde0b6b3a7640000", Status: ethereum.TransactionStatusMined,
+			BlockTimestamp: minedAt(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)), GasUsed: "0x5208", EffectiveGasPrice: "0x3b9aca00",
+		}},
+	}, nil)
+
+	srv := NewNaiveHTTPServer(Chains{1: chainStorage}, log, WithDefaultChain(1))
+	get := func(query url.Values) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/address/"+address+"/report?"+query.Encode(), nil))
+		return rec
+	}
+
+	t.Run("json report of a period", func(t *testing.T) {
+		rec := get(url.Values{"from": {"2024-01-01"}, "to": {"2025-01-01T00:00:00Z"}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		var r report.Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.ETH.In != "1500000000000000000" || r.ETH.Out != "0" || r.Fees != "0" || r.Transactions != 1 {
+			t.Fatalf("unexpected report %+v", r)
+		}
+	})
+
+	t.Run("whole history by default", func(t *testing.T) {
+		var r report.Report
+		if err := json.Unmarshal(get(nil).Body.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.ETH.Net != "500000000000000000" || r.Fees != "21000000000000" || len(r.Counterparties) != 1 {
+			t.Fatalf("unexpected report %+v", r)
+		}
+	})
+
+	t.Run("csv report", func(t *testing.T) {
+		rec := get(url.Values{"format": {"csv"}})
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+			t.Fatalf("expected csv, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+		}
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 4 || records[2][0] != "fees" || records[3][2] != counterparty {
+			t.Fatalf("unexpected csv %v", records)
+		}
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		tests := []url.Values{
+			{"from": {"yesterday"}},
+			{"from": {"2025-01-01"}, "to": {"2024-01-01"}},
+			{"format": {"xlsx"}},
+		}
+		for _, query := range tests {
+			if rec := get(query); rec.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d for %v, got %d", http.StatusBadRequest, query, rec.Code)
+			}
+		}
+	})
+}
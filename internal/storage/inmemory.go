@@ -2,61 +2,472 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/mkorolyov/go-eth-tx-parser/internal/addrset"
 	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
 )
 
+// txKey identifies a stored transaction of an address. Log index distinguishes
+// several transfers derived from event logs of the same transaction
+type txKey struct {
+	hash     string
+	logIndex string
+}
+
+// subscriptionKey identifies a subscription of a tenant
+type subscriptionKey struct {
+	kind   tenant.SubscriptionKind
+	target string
+}
+
 // InMemoryStorage is a thread-safe in-memory storage for transactions
 type InMemoryStorage struct {
 	mu sync.RWMutex
 	// address -> transactions
-	transactions        map[string][]ethereum.Transaction
-	currentBlock        int
-	subscribedAddresses map[string]struct{}
+	transactions map[string][]ethereum.Transaction
+	// address -> transaction key -> position in transactions
+	txIndex      map[string]map[txKey]int
+	currentBlock int
+	// address -> balance snapshots ordered by block
+	balances map[string][]ethereum.Balances
+	// event filters in subscription order
+	eventFilters []ethereum.EventFilter
+	// filter id -> events in commit order
+	events map[string][]ethereum.Event
+	// filter id -> event key -> position in events
+	eventIndex map[string]map[txKey]int
+	// tenant -> subscription key -> subscription
+	tenantSubscriptions map[string]map[subscriptionKey]tenant.Subscription
+	// address -> detected balance discrepancies
+	discrepancies map[string][]ethereum.BalanceDiscrepancy
+	// has its own lock, so subscription checks of the poller do not wait for transactions writes
+	subscribedAddresses *addrset.Set
+	// bloom positions of indexed address topics of subscribed addresses, kept in sync with subscribedAddresses
+	subscribedTopics *ethereum.BloomIndex
+	observers        operationObservers
+}
+
+// defaultExpectedSubscriptions sizes the bloom filter of subscribed addresses, it grows with the watchlist
+const defaultExpectedSubscriptions = 1024
+
+type options struct {
+	expectedSubscriptions int
+	chainID               int
+}
+
+type Option func(*options)
+
+// WithExpectedSubscriptions sizes the bloom filter checked before subscribed addresses for the watchlist
+// size, so it is not rebuilt while a large watchlist is loaded
+func WithExpectedSubscriptions(n int) Option {
+	return func(o *options) {
+		o.expectedSubscriptions = n
+	}
+}
+
+// WithChainID sets the chain stored transactions belong to, used to label metrics
+func WithChainID(chainID int) Option {
+	return func(o *options) {
+		o.chainID = chainID
+	}
 }
 
 // NewInMemoryStorage creates a new in-memory storage
-func NewInMemoryStorage() *InMemoryStorage {
+func NewInMemoryStorage(opts ...Option) *InMemoryStorage {
+	o := options{expectedSubscriptions: defaultExpectedSubscriptions, chainID: ethereum.MainnetChainID}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	return &InMemoryStorage{
 		transactions:        make(map[string][]ethereum.Transaction),
-		subscribedAddresses: make(map[string]struct{}),
+		txIndex:             make(map[string]map[txKey]int),
+		balances:            make(map[string][]ethereum.Balances),
+		discrepancies:       make(map[string][]ethereum.BalanceDiscrepancy),
+		events:              make(map[string][]ethereum.Event),
+		tenantSubscriptions: make(map[string]map[subscriptionKey]tenant.Subscription),
+		eventIndex:          make(map[string]map[txKey]int),
+		subscribedAddresses: addrset.New(addrset.WithBloomFilter(o.expectedSubscriptions)),
+		subscribedTopics:    ethereum.NewBloomIndex(),
+		observers:           newOperationObservers(strconv.Itoa(o.chainID)),
 	}
 }
 
-// SaveTransaction AddTransaction stores a transaction for an address
+// SaveTransaction AddTransaction stores a transaction for an address. Saving is idempotent:
+// a transaction already stored under the same hash and log index is replaced, so re-processed blocks
+// do not produce duplicates and a pending transaction becomes mined once the poller sees it in a block.
+// Mined transactions are never downgraded to pending
 func (s *InMemoryStorage) SaveTransaction(_ context.Context, address string, tx ethereum.Transaction) error {
+	defer observe(s.observers.saveTransaction)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveTransaction(address, tx)
+	return nil
+}
+
+func (s *InMemoryStorage) saveTransaction(address string, tx ethereum.Transaction) {
+	index, ok := s.txIndex[address]
+	if !ok {
+		index = make(map[txKey]int)
+		s.txIndex[address] = index
+	}
+
+	key := txKey{hash: tx.Hash, logIndex: tx.LogIndex}
+	if i, ok := index[key]; ok {
+		stored := s.transactions[address][i]
+		if stored.Status == ethereum.TransactionStatusMined && tx.Status == ethereum.TransactionStatusPending {
+			return
+		}
+		s.transactions[address][i] = tx
+		return
+	}
+
+	// transactions are kept in block order: the poller appends, backfilled history goes before later blocks
+	txs := s.transactions[address]
+	block := blockOf(tx)
+	i := len(txs)
+	for i > 0 && blockOf(txs[i-1]) > block {
+		i--
+	}
+	s.transactions[address] = slices.Insert(txs, i, tx)
+	for j := i + 1; j < len(s.transactions[address]); j++ {
+		moved := s.transactions[address][j]
+		index[txKey{hash: moved.Hash, logIndex: moved.LogIndex}] = j
+	}
+	index[key] = i
+}
+
+// blockOf is the block number of a transaction, pending transactions sort after every block
+func blockOf(tx ethereum.Transaction) int {
+	if tx.BlockNumber == "" {
+		return math.MaxInt
+	}
+	block, err := ethereum.ParseQuantity(tx.BlockNumber)
+	if err != nil {
+		return math.MaxInt
+	}
+	return block
+}
+
+// CommitBlock stores transactions and events of a processed block and updates the current block under a single lock,
+// so readers never observe a block partially saved
+func (s *InMemoryStorage) CommitBlock(_ context.Context, block int, txs []ethereum.AddressTx, events []ethereum.Event) error {
+	defer observe(s.observers.commitBlock)()
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.transactions[address] = append(s.transactions[address], tx)
+
+	for _, addressTx := range txs {
+		s.saveTransaction(addressTx.Address, addressTx.Tx)
+	}
+	for _, event := range events {
+		s.saveEvent(event)
+	}
+	s.currentBlock = block
 	return nil
 }
 
-// GetTransactions fetches transactions for a given address. Paging is not supported for simplicity
+// SetTransactionStatus updates status of a stored transaction.
+// Unknown and already mined transactions are left untouched
+func (s *InMemoryStorage) SetTransactionStatus(_ context.Context, address string, hash string, status ethereum.TransactionStatus) error {
+	defer observe(s.observers.setTransactionStatus)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i, ok := s.txIndex[address][txKey{hash: hash}]
+	if !ok {
+		return nil
+	}
+
+	if tx := &s.transactions[address][i]; tx.Status != ethereum.TransactionStatusMined {
+		tx.Status = status
+	}
+	return nil
+}
+
+// GetTransactions fetches all transactions for a given address in block order, GetTransactionsPage pages through them
 func (s *InMemoryStorage) GetTransactions(_ context.Context, address string) ([]ethereum.Transaction, error) {
+	defer observe(s.observers.getTransactions)()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.transactions[address], nil
+	// copy, so callers do not race with updates of stored transactions
+	return slices.Clone(s.transactions[address]), nil
+}
+
+// GetTransactionsPage fetches up to limit transactions of an address starting at offset in block order.
+// Transactions keep their positions when updated, so paging does not skip or repeat them, unless history
+// is backfilled in between
+func (s *InMemoryStorage) GetTransactionsPage(_ context.Context, address string, offset int, limit int) ([]ethereum.Transaction, error) {
+	defer observe(s.observers.getTransactionsPage)()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	txs := s.transactions[address]
+	if offset >= len(txs) {
+		return nil, nil
+	}
+	return slices.Clone(txs[offset:min(offset+limit, len(txs))]), nil
+}
+
+// SaveBalances stores a balance snapshot of the address. Snapshots are kept ordered by block,
+// a snapshot of an already stored block replaces it
+func (s *InMemoryStorage) SaveBalances(_ context.Context, balances ethereum.Balances) error {
+	defer observe(s.observers.saveBalances)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	balances.Tokens = maps.Clone(balances.Tokens)
+	snapshots := s.balances[balances.Address]
+	i, found := slices.BinarySearchFunc(snapshots, balances.Block, func(b ethereum.Balances, block int) int {
+		return b.Block - block
+	})
+	if found {
+		snapshots[i] = balances
+		return nil
+	}
+	s.balances[balances.Address] = slices.Insert(snapshots, i, balances)
+	return nil
+}
+
+// GetBalances fetches the latest balance snapshot of the address, false if there is none yet
+func (s *InMemoryStorage) GetBalances(_ context.Context, address string) (ethereum.Balances, bool, error) {
+	defer observe(s.observers.getBalances)()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snapshots := s.balances[address]
+	if len(snapshots) == 0 {
+		return ethereum.Balances{}, false, nil
+	}
+	balances := snapshots[len(snapshots)-1]
+	// copy, so callers do not race with updates of stored balances
+	balances.Tokens = maps.Clone(balances.Tokens)
+	return balances, true, nil
+}
+
+// GetBalanceSnapshots fetches all balance snapshots of the address ordered by block
+func (s *InMemoryStorage) GetBalanceSnapshots(_ context.Context, address string) ([]ethereum.Balances, error) {
+	defer observe(s.observers.getBalances)()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	// snapshots are never modified in place except replacement, shallow copy is enough
+	return slices.Clone(s.balances[address]), nil
+}
+
+// SaveDiscrepancy records a balance discrepancy of the address
+func (s *InMemoryStorage) SaveDiscrepancy(_ context.Context, discrepancy ethereum.BalanceDiscrepancy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discrepancies[discrepancy.Address] = append(s.discrepancies[discrepancy.Address], discrepancy)
+	return nil
+}
+
+// GetDiscrepancies fetches balance discrepancies detected for the address
+func (s *InMemoryStorage) GetDiscrepancies(_ context.Context, address string) ([]ethereum.BalanceDiscrepancy, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.discrepancies[address]), nil
 }
 
 // Subscribe adds an address to be observed
 func (s *InMemoryStorage) Subscribe(_ context.Context, address string) error {
+	defer observe(s.observers.subscribe)()
+	if err := s.subscribedAddresses.Add(address); err != nil {
+		return err
+	}
+	return s.addTopic(address)
+}
+
+// SubscribeTenant records an address subscription of a tenant and observes the address. Both happen under the lock
+// UnsubscribeTenant takes, so unsubscribing the last other tenant meanwhile never leaves the tenant subscribed to
+// an address which is not observed. Subscribing again keeps the original creation time
+func (s *InMemoryStorage) SubscribeTenant(_ context.Context, tenantID string, sub tenant.Subscription) error {
+	defer observe(s.observers.subscribe)()
+	if sub.Kind != tenant.SubscriptionKindAddress {
+		return fmt.Errorf("subscribe tenant to %s: only address subscriptions are observed", sub.Kind)
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.subscribedAddresses[address] = struct{}{}
+	if err := s.subscribedAddresses.Add(sub.Target); err != nil {
+		return err
+	}
+	if err := s.addTopic(sub.Target); err != nil {
+		return err
+	}
+	s.saveTenantSubscription(tenantID, sub)
 	return nil
 }
 
+// LoadSubscriptions subscribes the tenant to addresses in bulk, e.g. to hydrate a watchlist at startup.
+// Nothing is subscribed if any of the addresses is malformed
+func (s *InMemoryStorage) LoadSubscriptions(_ context.Context, tenantID string, addresses []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.subscribedAddresses.Load(addresses); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, address := range addresses {
+		if err := s.addTopic(address); err != nil {
+			return err
+		}
+		s.saveTenantSubscription(tenantID, tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: address, CreatedAt: now})
+	}
+	return nil
+}
+
+// UnsubscribeTenant removes the address subscription of a tenant and stops observing the address once no tenant
+// is subscribed to it. Stored transactions are kept, so subscribing again continues the history.
+// False if the tenant was not subscribed
+func (s *InMemoryStorage) UnsubscribeTenant(_ context.Context, tenantID string, address string) (bool, error) {
+	defer observe(s.observers.unsubscribe)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := subscriptionKey{kind: tenant.SubscriptionKindAddress, target: address}
+	if _, ok := s.tenantSubscriptions[tenantID][key]; !ok {
+		return false, nil
+	}
+	delete(s.tenantSubscriptions[tenantID], key)
+
+	for _, subs := range s.tenantSubscriptions {
+		if _, ok := subs[key]; ok {
+			return true, nil
+		}
+	}
+	s.subscribedTopics.Remove(strings.ToLower(address))
+	return true, s.subscribedAddresses.Remove(address)
+}
+
+// addTopic indexes bloom positions of the topic an address is logged as by indexed event parameters
+func (s *InMemoryStorage) addTopic(address string) error {
+	topic, err := ethereum.AddressTopic(address)
+	if err != nil {
+		return err
+	}
+	decoded, _ := ethereum.DecodeHex(topic)
+	s.subscribedTopics.Add(strings.ToLower(address), ethereum.BloomPositionsOf(decoded))
+	return nil
+}
+
+// MayContainSubscribedTopic tests a block logs bloom for the indexed address topic of any subscribed address
+func (s *InMemoryStorage) MayContainSubscribedTopic(_ context.Context, bloom ethereum.Bloom) (bool, error) {
+	return s.subscribedTopics.MayContainAny(bloom), nil
+}
+
 // IsSubscribed checks if an address is being observed
 func (s *InMemoryStorage) IsSubscribed(_ context.Context, address string) (bool, error) {
+	defer observe(s.observers.isSubscribed)()
+	return s.subscribedAddresses.Contains(address), nil
+}
+
+// SubscribedAddresses lists all observed addresses
+func (s *InMemoryStorage) SubscribedAddresses(_ context.Context) ([]string, error) {
+	return s.subscribedAddresses.Addresses(), nil
+}
+
+// SubscribeEvents adds an event filter. Filters are identified by id, subscribing an existing one is a no-op
+func (s *InMemoryStorage) SubscribeEvents(_ context.Context, filter ethereum.EventFilter) error {
+	defer observe(s.observers.subscribe)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.eventIndex[filter.ID]; ok {
+		return nil
+	}
+	s.eventFilters = append(s.eventFilters, filter)
+	s.eventIndex[filter.ID] = make(map[txKey]int)
+	return nil
+}
+
+// EventFilters lists subscribed event filters
+func (s *InMemoryStorage) EventFilters(_ context.Context) ([]ethereum.EventFilter, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	_, ok := s.subscribedAddresses[address]
+	return slices.Clone(s.eventFilters), nil
+}
+
+// saveEvent stores an event idempotently by transaction hash and log index, the same way as transactions
+func (s *InMemoryStorage) saveEvent(event ethereum.Event) {
+	index, ok := s.eventIndex[event.FilterID]
+	if !ok {
+		return
+	}
+
+	key := txKey{hash: event.TransactionHash, logIndex: event.LogIndex}
+	if i, ok := index[key]; ok {
+		s.events[event.FilterID][i] = event
+		return
+	}
+	index[key] = len(s.events[event.FilterID])
+	s.events[event.FilterID] = append(s.events[event.FilterID], event)
+}
+
+// GetEvents fetches up to limit events of a filter starting at offset in commit order
+func (s *InMemoryStorage) GetEvents(_ context.Context, filterID string, offset int, limit int) ([]ethereum.Event, error) {
+	defer observe(s.observers.getEvents)()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := s.events[filterID]
+	if offset >= len(events) {
+		return nil, nil
+	}
+	return slices.Clone(events[offset:min(offset+limit, len(events))]), nil
+}
+
+// SaveTenantSubscription records a subscription of a tenant. Subscribing again keeps the original creation time
+func (s *InMemoryStorage) SaveTenantSubscription(_ context.Context, tenantID string, sub tenant.Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saveTenantSubscription(tenantID, sub)
+	return nil
+}
+
+func (s *InMemoryStorage) saveTenantSubscription(tenantID string, sub tenant.Subscription) {
+	subs, ok := s.tenantSubscriptions[tenantID]
+	if !ok {
+		subs = make(map[subscriptionKey]tenant.Subscription)
+		s.tenantSubscriptions[tenantID] = subs
+	}
+	key := subscriptionKey{kind: sub.Kind, target: sub.Target}
+	if _, ok := subs[key]; !ok {
+		subs[key] = sub
+	}
+}
+
+// TenantSubscriptions lists subscriptions of a tenant ordered by creation time
+func (s *InMemoryStorage) TenantSubscriptions(_ context.Context, tenantID string) ([]tenant.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := slices.Collect(maps.Values(s.tenantSubscriptions[tenantID]))
+	slices.SortFunc(subs, func(a, b tenant.Subscription) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return subs, nil
+}
+
+// HasTenantSubscription checks if a tenant is subscribed to an address or an event filter
+func (s *InMemoryStorage) HasTenantSubscription(_ context.Context, tenantID string, kind tenant.SubscriptionKind, target string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.tenantSubscriptions[tenantID][subscriptionKey{kind: kind, target: target}]
 	return ok, nil
 }
 
 // SetCurrentBlock updates the current block
 func (s *InMemoryStorage) SetCurrentBlock(_ context.Context, block int) error {
+	defer observe(s.observers.setCurrentBlock)()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.currentBlock = block
@@ -65,7 +476,13 @@ func (s *InMemoryStorage) SetCurrentBlock(_ context.Context, block int) error {
 
 // GetCurrentBlock retrieves the current block
 func (s *InMemoryStorage) GetCurrentBlock(_ context.Context) (int, error) {
+	defer observe(s.observers.getCurrentBlock)()
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.currentBlock, nil
 }
+
+// Ping checks storage availability. In-memory storage is always available
+func (s *InMemoryStorage) Ping(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,322 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mkorolyov/go-eth-tx-parser/internal/ethereum"
+	"github.com/mkorolyov/go-eth-tx-parser/internal/tenant"
+)
+
+func TestInMemoryStorage_SaveTransaction(t *testing.T) {
+	ctx := context.Background()
+	address := "0xabc"
+
+	t.Run("saving the same transaction twice is idempotent", func(t *testing.T) {
+		s := NewInMemoryStorage()
+		tx := ethereum.Transaction{Hash: "0x1", Status: ethereum.TransactionStatusMined}
+
+		for range 2 {
+			if err := s.SaveTransaction(ctx, address, tx); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+		}
+
+		txs, _ := s.GetTransactions(ctx, address)
+		if len(txs) != 1 {
+			t.Fatalf("expected 1 transaction, got %d", len(txs))
+		}
+	})
+
+	t.Run("transfers with different log index are kept", func(t *testing.T) {
+		s := NewInMemoryStorage()
+
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1"})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1", LogIndex: "0x0"})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1", LogIndex: "0x1"})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1", LogIndex: "0x1"})
+
+		txs, _ := s.GetTransactions(ctx, address)
+		if len(txs) != 3 {
+			t.Fatalf("expected 3 transactions, got %d", len(txs))
+		}
+	})
+
+	t.Run("transactions are kept in block order", func(t *testing.T) {
+		s := NewInMemoryStorage()
+
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1", BlockNumber: "0x10"})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x2"})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x3", BlockNumber: "0x12"})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x4", BlockNumber: "0x11"})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x5", BlockNumber: "0x1"})
+		// updates find transactions at their shifted positions
+		_ = s.SetTransactionStatus(ctx, address, "0x2", ethereum.TransactionStatusDropped)
+
+		txs, _ := s.GetTransactions(ctx, address)
+		var hashes []string
+		for _, tx := range txs {
+			hashes = append(hashes, tx.Hash)
+		}
+		if expected := []string{"0x5", "0x1", "0x4", "0x3", "0x2"}; !slices.Equal(hashes, expected) {
+			t.Fatalf("expected %v, got %v", expected, hashes)
+		}
+		if txs[4].Status != ethereum.TransactionStatusDropped {
+			t.Fatalf("expected dropped pending transaction, got %+v", txs[4])
+		}
+	})
+
+	t.Run("pending transaction becomes mined", func(t *testing.T) {
+		s := NewInMemoryStorage()
+
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1", Status: ethereum.TransactionStatusPending})
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1", Status: ethereum.TransactionStatusMined})
+		// late mempool notification must not downgrade the mined transaction
+		_ = s.SaveTransaction(ctx, address, ethereum.Transaction{Hash: "0x1", Status: ethereum.TransactionStatusPending})
+		_ = s.SetTransactionStatus(ctx, address, "0x1", ethereum.TransactionStatusDropped)
+
+		txs, _ := s.GetTransactions(ctx, address)
+		if len(txs) != 1 || txs[0].Status != ethereum.TransactionStatusMined {
+			t.Fatalf("expected single mined transaction, got %+v", txs)
+		}
+	})
+}
+
+func TestInMemoryStorage_CommitBlock(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	txs := []ethereum.AddressTx{
+		{Address: "0xabc", Tx: ethereum.Transaction{Hash: "0x1"}},
+		{Address: "0xdef", Tx: ethereum.Transaction{Hash: "0x1"}},
+	}
+
+	// the same block committed twice, e.g. after a restart
+	for range 2 {
+		if err := s.CommitBlock(ctx, 100, txs, nil); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	for _, address := range []string{"0xabc", "0xdef"} {
+		stored, _ := s.GetTransactions(ctx, address)
+		if len(stored) != 1 {
+			t.Fatalf("expected 1 transaction for %s, got %d", address, len(stored))
+		}
+	}
+
+	block, _ := s.GetCurrentBlock(ctx)
+	if block != 100 {
+		t.Fatalf("expected current block 100, got %d", block)
+	}
+}
+
+func TestInMemoryStorage_GetTransactionsPage(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	for _, hash := range []string{"0x1", "0x2", "0x3"} {
+		_ = s.SaveTransaction(ctx, "0xabc", ethereum.Transaction{Hash: hash, Status: ethereum.TransactionStatusPending})
+	}
+	// updated transactions keep their position
+	_ = s.SaveTransaction(ctx, "0xabc", ethereum.Transaction{Hash: "0x1", Status: ethereum.TransactionStatusMined})
+
+	tests := []struct {
+		offset   int
+		limit    int
+		expected []string
+	}{
+		{offset: 0, limit: 2, expected: []string{"0x1", "0x2"}},
+		{offset: 2, limit: 2, expected: []string{"0x3"}},
+		{offset: 3, limit: 2, expected: nil},
+	}
+	for _, tt := range tests {
+		page, err := s.GetTransactionsPage(ctx, "0xabc", tt.offset, tt.limit)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		var hashes []string
+		for _, tx := range page {
+			hashes = append(hashes, tx.Hash)
+		}
+		if !slices.Equal(hashes, tt.expected) {
+			t.Fatalf("expected %v at offset %d, got %v", tt.expected, tt.offset, hashes)
+		}
+	}
+
+	page, _ := s.GetTransactionsPage(ctx, "0xabc", 0, 1)
+	if page[0].Status != ethereum.TransactionStatusMined {
+		t.Fatalf("expected updated transaction, got %+v", page[0])
+	}
+}
+
+func TestBalanceSnapshots(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	address := "0xaaaa"
+
+	for _, block := range []int{200, 100, 300, 200} {
+		_ = s.SaveBalances(ctx, ethereum.Balances{Address: address, Block: block, ETH: strconv.Itoa(block)})
+	}
+
+	snapshots, _ := s.GetBalanceSnapshots(ctx, address)
+	if len(snapshots) != 3 || snapshots[0].Block != 100 || snapshots[1].Block != 200 || snapshots[2].Block != 300 {
+		t.Fatalf("expected snapshots ordered by block without duplicates, got %+v", snapshots)
+	}
+
+	latest, ok, _ := s.GetBalances(ctx, address)
+	if !ok || latest.Block != 300 {
+		t.Fatalf("expected latest snapshot of block 300, got %+v", latest)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	filter := ethereum.EventFilter{ID: "f1", Topics: []string{"0x01"}}
+
+	_ = s.SubscribeEvents(ctx, filter)
+	_ = s.SubscribeEvents(ctx, filter)
+	filters, _ := s.EventFilters(ctx)
+	if len(filters) != 1 {
+		t.Fatalf("expected filter subscribed once, got %+v", filters)
+	}
+
+	events := []ethereum.Event{
+		{FilterID: "f1", Log: ethereum.Log{TransactionHash: "0x1", LogIndex: "0x0"}},
+		{FilterID: "f1", Log: ethereum.Log{TransactionHash: "0x1", LogIndex: "0x1"}},
+		{FilterID: "f1", Log: ethereum.Log{TransactionHash: "0x2", LogIndex: "0x2"}},
+		// not subscribed
+		{FilterID: "f2", Log: ethereum.Log{TransactionHash: "0x2", LogIndex: "0x2"}},
+	}
+	// reprocessed block does not duplicate events
+	_ = s.CommitBlock(ctx, 100, nil, events)
+	_ = s.CommitBlock(ctx, 100, nil, events)
+
+	page, _ := s.GetEvents(ctx, "f1", 0, 2)
+	if len(page) != 2 || page[1].LogIndex != "0x1" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	page, _ = s.GetEvents(ctx, "f1", 2, 2)
+	if len(page) != 1 || page[0].LogIndex != "0x2" {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+	if page, _ := s.GetEvents(ctx, "f2", 0, 2); len(page) != 0 {
+		t.Fatalf("expected no events of unknown filter, got %+v", page)
+	}
+}
+
+func TestTenantSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	s := NewInMemoryStorage()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	sub := tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: "0x1", CreatedAt: created}
+
+	_ = s.SaveTenantSubscription(ctx, "a", sub)
+	// subscribing again keeps the original creation time
+	_ = s.SaveTenantSubscription(ctx, "a", tenant.Subscription{Kind: sub.Kind, Target: sub.Target, CreatedAt: created.Add(time.Hour)})
+
+	subs, _ := s.TenantSubscriptions(ctx, "a")
+	if len(subs) != 1 || !subs[0].CreatedAt.Equal(created) {
+		t.Fatalf("expected subscription saved once, got %+v", subs)
+	}
+
+	if ok, _ := s.HasTenantSubscription(ctx, "a", tenant.SubscriptionKindAddress, "0x1"); !ok {
+		t.Fatal("expected tenant subscribed to address")
+	}
+	if ok, _ := s.HasTenantSubscription(ctx, "a", tenant.SubscriptionKindEvents, "0x1"); ok {
+		t.Fatal("expected subscription kinds to be distinct")
+	}
+	if ok, _ := s.HasTenantSubscription(ctx, "b", tenant.SubscriptionKindAddress, "0x1"); ok {
+		t.Fatal("expected subscription of another tenant to be invisible")
+	}
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		address := "0x1111111111111111111111111111111111111111"
+		_ = s.Subscribe(ctx, address)
+		for _, tenantID := range []string{"a", "b"} {
+			_ = s.SaveTenantSubscription(ctx, tenantID, tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: address, CreatedAt: created})
+		}
+
+		if ok, err := s.UnsubscribeTenant(ctx, "a", address); !ok || err != nil {
+			t.Fatalf("expected tenant unsubscribed, got %v, %v", ok, err)
+		}
+		if ok, _ := s.IsSubscribed(ctx, address); !ok {
+			t.Fatal("expected address observed while another tenant is subscribed")
+		}
+		if ok, _ := s.UnsubscribeTenant(ctx, "a", address); ok {
+			t.Fatal("expected unsubscribing twice to report not subscribed")
+		}
+
+		var bloom ethereum.Bloom
+		topic, _ := ethereum.AddressTopic(address)
+		decoded, _ := ethereum.DecodeHex(topic)
+		bloom.Add(decoded)
+		if ok, _ := s.MayContainSubscribedTopic(ctx, bloom); !ok {
+			t.Fatal("expected topic of the observed address in the bloom")
+		}
+
+		_, _ = s.UnsubscribeTenant(ctx, "b", address)
+		if ok, _ := s.IsSubscribed(ctx, address); ok {
+			t.Fatal("expected address not observed once no tenant is subscribed")
+		}
+		if ok, _ := s.MayContainSubscribedTopic(ctx, bloom); ok {
+			t.Fatal("expected topic of the unsubscribed address to be evicted")
+		}
+	})
+
+	t.Run("subscribe while the last other tenant unsubscribes", func(t *testing.T) {
+		address := "0x3333333333333333333333333333333333333333"
+		for range 100 {
+			s := NewInMemoryStorage()
+			_ = s.SubscribeTenant(ctx, "a", tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: address})
+
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_ = s.SubscribeTenant(ctx, "b", tenant.Subscription{Kind: tenant.SubscriptionKindAddress, Target: address})
+			}()
+			go func() {
+				defer wg.Done()
+				_, _ = s.UnsubscribeTenant(ctx, "a", address)
+			}()
+			wg.Wait()
+
+			if ok, _ := s.IsSubscribed(ctx, address); !ok {
+				t.Fatal("expected address observed while a tenant is subscribed")
+			}
+		}
+	})
+
+	t.Run("only address subscriptions are observed", func(t *testing.T) {
+		if err := s.SubscribeTenant(ctx, "a", tenant.Subscription{Kind: tenant.SubscriptionKindEvents, Target: "0x1"}); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+
+	t.Run("load", func(t *testing.T) {
+		s := NewInMemoryStorage(WithExpectedSubscriptions(2))
+		addresses := []string{"0x1111111111111111111111111111111111111111", "0x2222222222222222222222222222222222222222"}
+		if err := s.LoadSubscriptions(ctx, "a", addresses); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		for _, address := range addresses {
+			if ok, _ := s.IsSubscribed(ctx, address); !ok {
+				t.Fatalf("expected %s observed", address)
+			}
+			if ok, _ := s.HasTenantSubscription(ctx, "a", tenant.SubscriptionKindAddress, address); !ok {
+				t.Fatalf("expected tenant subscribed to %s", address)
+			}
+		}
+
+		if err := s.LoadSubscriptions(ctx, "b", []string{"0x3333333333333333333333333333333333333333", "0x4"}); err == nil {
+			t.Fatal("expected error for a malformed address")
+		}
+		if subs, _ := s.TenantSubscriptions(ctx, "b"); len(subs) != 0 {
+			t.Fatalf("expected nothing subscribed, got %+v", subs)
+		}
+	})
+}
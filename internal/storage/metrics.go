@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const metricsNamespace = "eth_tx_parser"
+
+var operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: metricsNamespace,
+	Subsystem: "storage",
+	Name:      "operation_duration_seconds",
+	Help:      "Latency of storage operations, including lock waiting time.",
+	Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5},
+}, []string{"chain_id", "operation"})
+
+// operationObservers are per operation observers of a chain resolved once, labels lookup is noticeable
+// on the subscription checks hot path
+type operationObservers struct {
+	saveTransaction      prometheus.Observer
+	setTransactionStatus prometheus.Observer
+	commitBlock          prometheus.Observer
+	getTransactions      prometheus.Observer
+	getTransactionsPage  prometheus.Observer
+	subscribe            prometheus.Observer
+	unsubscribe          prometheus.Observer
+	isSubscribed         prometheus.Observer
+	setCurrentBlock      prometheus.Observer
+	getCurrentBlock      prometheus.Observer
+	saveBalances         prometheus.Observer
+	getBalances          prometheus.Observer
+	getEvents            prometheus.Observer
+}
+
+func newOperationObservers(chainLabel string) operationObservers {
+	return operationObservers{
+		saveTransaction:      operationDuration.WithLabelValues(chainLabel, "save_transaction"),
+		setTransactionStatus: operationDuration.WithLabelValues(chainLabel, "set_transaction_status"),
+		commitBlock:          operationDuration.WithLabelValues(chainLabel, "commit_block"),
+		getTransactions:      operationDuration.WithLabelValues(chainLabel, "get_transactions"),
+		getTransactionsPage:  operationDuration.WithLabelValues(chainLabel, "get_transactions_page"),
+		subscribe:            operationDuration.WithLabelValues(chainLabel, "subscribe"),
+		unsubscribe:          operationDuration.WithLabelValues(chainLabel, "unsubscribe"),
+		isSubscribed:         operationDuration.WithLabelValues(chainLabel, "is_subscribed"),
+		setCurrentBlock:      operationDuration.WithLabelValues(chainLabel, "set_current_block"),
+		getCurrentBlock:      operationDuration.WithLabelValues(chainLabel, "get_current_block"),
+		saveBalances:         operationDuration.WithLabelValues(chainLabel, "save_balances"),
+		getBalances:          operationDuration.WithLabelValues(chainLabel, "get_balances"),
+		getEvents:            operationDuration.WithLabelValues(chainLabel, "get_events"),
+	}
+}
+
+// observe records duration of a storage operation, meant to be deferred as
+// defer observe(s.observers.saveTransaction)()
+func observe(operation prometheus.Observer) func() {
+	start := time.Now()
+	return func() {
+		operation.Observe(time.Since(start).Seconds())
+	}
+}
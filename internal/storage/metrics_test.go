@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestOperationDuration(t *testing.T) {
+	ctx := context.Background()
+	// metrics are global, so chains of the test are not used by other tests
+	optimism := NewInMemoryStorage(WithChainID(10))
+	polygon := NewInMemoryStorage(WithChainID(137))
+
+	if err := optimism.SetCurrentBlock(ctx, 1); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for range 2 {
+		if err := polygon.SetCurrentBlock(ctx, 1); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	observed := map[string]uint64{}
+	for _, family := range families {
+		if family.GetName() != "eth_tx_parser_storage_operation_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["operation"] == "set_current_block" {
+				observed[labels["chain_id"]] = metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	if observed["10"] != 1 || observed["137"] != 2 {
+		t.Fatalf("expected operations observed per chain, got %v", observed)
+	}
+}
@@ -0,0 +1,70 @@
+// Package tenant carries the authenticated tenant of a request and its subscriptions. Indexed chain data is shared,
+// tenants see data only of what they subscribed to.
+package tenant
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+)
+
+// Default is the tenant of requests when authentication is disabled
+const Default = "default"
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the tenant
+func NewContext(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext returns the tenant of the context, Default if there is none
+func FromContext(ctx context.Context) string {
+	if tenant, ok := Lookup(ctx); ok {
+		return tenant
+	}
+	return Default
+}
+
+// Lookup returns the tenant of the context, false if the request is not authenticated
+func Lookup(ctx context.Context) (string, bool) {
+	tenant, ok := ctx.Value(contextKey{}).(string)
+	return tenant, ok
+}
+
+// Keys maps API keys to tenants owning them
+type Keys map[[sha256.Size]byte]string
+
+// NewKeys indexes API keys mapped to tenants. Keys are looked up by digest, so lookup time does not depend
+// on how much of a key matches
+func NewKeys(keys map[string]string) Keys {
+	digests := make(Keys, len(keys))
+	for key, tenant := range keys {
+		digests[sha256.Sum256([]byte(key))] = tenant
+	}
+	return digests
+}
+
+// Tenant returns the tenant owning the key
+func (k Keys) Tenant(key string) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	tenant, ok := k[sha256.Sum256([]byte(key))]
+	return tenant, ok
+}
+
+type SubscriptionKind string
+
+const (
+	SubscriptionKindAddress SubscriptionKind = "address"
+	SubscriptionKindEvents  SubscriptionKind = "events"
+)
+
+// Subscription is a subscription of a tenant to an address or an event filter
+type Subscription struct {
+	Kind SubscriptionKind `json:"kind"`
+	// address or event filter id
+	Target    string    `json:"target"`
+	CreatedAt time.Time `json:"created_at"`
+}